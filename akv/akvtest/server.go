@@ -0,0 +1,230 @@
+// Package akvtest provides in-process fake of the Azure Key Vault secrets API for tests.
+//
+// Implemented subset: Get Secret, Microsoft Entra ID client credentials grant (client secret and
+// federated assertion), managed identity tokens of the instance metadata service and App Service,
+// bearer token authentication.
+package akvtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type version struct {
+	id          string
+	value       string
+	contentType string
+}
+
+// Server fake Key Vault server
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	secrets   map[string][]*version
+	tokens    map[string]bool
+	clients   map[string]string
+	federated map[string]string
+	requests  int
+	// IdentityHeader expected by the App Service managed identity endpoint "/msi/token"
+	IdentityHeader string
+}
+
+// NewServer starts fake Key Vault server. Caller must Close it
+func NewServer() *Server {
+	s := &Server{
+		secrets:   make(map[string][]*version),
+		tokens:    make(map[string]bool),
+		clients:   make(map[string]string),
+		federated: make(map[string]string),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Start fake Key Vault server closed on test cleanup
+func Start(t testing.TB) *Server {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Endpoint of the vaults for the client config
+func (s *Server) Endpoint() string {
+	return s.URL + "/{vault}"
+}
+
+// AddToken allows requests with the bearer token
+func (s *Server) AddToken(token string) *Server {
+	s.mu.Lock()
+	s.tokens[token] = true
+	s.mu.Unlock()
+	return s
+}
+
+// AddClient allows client credentials grant with the secret
+func (s *Server) AddClient(tenant, clientID, secret string) *Server {
+	s.mu.Lock()
+	s.clients[tenant+"/"+clientID] = secret
+	s.mu.Unlock()
+	return s
+}
+
+// AddFederatedToken allows client credentials grant with the federated assertion
+func (s *Server) AddFederatedToken(tenant, clientID, assertion string) *Server {
+	s.mu.Lock()
+	s.federated[tenant+"/"+clientID] = assertion
+	s.mu.Unlock()
+	return s
+}
+
+// Requests returns count of handled secret requests
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// SetSecret adds current version of the secret. Returns version ID
+func (s *Server) SetSecret(vault, name, value, contentType string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := &version{id: randomID(), value: value, contentType: contentType}
+	s.secrets[vault+"/"+name] = append(s.secrets[vault+"/"+name], v)
+	return v.id
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/metadata/identity/oauth2/token":
+		if r.Header.Get("Metadata") != "true" {
+			writeError(w, http.StatusBadRequest, "bad_request", "Required metadata header not specified")
+			return
+		}
+		s.identity(w, r)
+	case r.URL.Path == "/msi/token":
+		if s.IdentityHeader == "" || r.Header.Get("X-Identity-Header") != s.IdentityHeader {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "invalid identity header")
+			return
+		}
+		s.identity(w, r)
+	case len(parts) == 4 && parts[1] == "oauth2" && parts[3] == "token" && r.Method == http.MethodPost:
+		s.token(w, r, parts[0])
+	case len(parts) >= 3 && len(parts) <= 4 && parts[1] == "secrets" && r.Method == http.MethodGet:
+		s.requests++
+		s.secret(w, r, parts)
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", "not found")
+	}
+}
+
+// secret handles Get Secret of /{vault}/secrets/{name}[/{version}]. Requires lock
+func (s *Server) secret(w http.ResponseWriter, r *http.Request, parts []string) {
+	if !s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] {
+		writeError(w, http.StatusUnauthorized, "Unauthorized", "AKV10000: Request is missing a Bearer or PoP token.")
+		return
+	}
+	if r.URL.Query().Get("api-version") == "" {
+		writeError(w, http.StatusBadRequest, "BadParameter", "The 'api-version' query parameter is required.")
+		return
+	}
+	versions := s.secrets[parts[0]+"/"+parts[2]]
+	var v *version
+	if len(parts) == 3 && len(versions) > 0 {
+		v = versions[len(versions)-1]
+	}
+	for _, ver := range versions {
+		if len(parts) == 4 && ver.id == parts[3] {
+			v = ver
+		}
+	}
+	if v == nil {
+		writeError(w, http.StatusNotFound, "SecretNotFound",
+			"A secret with (name/id) "+strings.Join(parts[2:], "/")+" was not found in this key vault.")
+		return
+	}
+	out := map[string]interface{}{
+		"value":      v.value,
+		"id":         "https://" + parts[0] + ".vault.azure.net/secrets/" + parts[2] + "/" + v.id,
+		"attributes": map[string]interface{}{"enabled": true},
+	}
+	if v.contentType != "" {
+		out["contentType"] = v.contentType
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// token handles client credentials grant. Requires lock
+func (s *Server) token(w http.ResponseWriter, r *http.Request, tenant string) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	f := r.PostForm
+	id := tenant + "/" + f.Get("client_id")
+	ok := f.Get("grant_type") == "client_credentials" && strings.HasSuffix(f.Get("scope"), "/.default")
+	if secret, found := s.clients[id]; f.Get("client_secret") != "" {
+		ok = ok && found && secret == f.Get("client_secret")
+	} else {
+		assertion, found := s.federated[id]
+		ok = ok && found && assertion == f.Get("client_assertion") &&
+			f.Get("client_assertion_type") == "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	}
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	token := s.issue()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": token, "expires_in": 3599, "token_type": "Bearer"})
+}
+
+// identity handles managed identity token requests. Requires lock
+func (s *Server) identity(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("resource") == "" || r.URL.Query().Get("api-version") == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "resource and api-version are required")
+		return
+	}
+	token := s.issue()
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": token,
+		"expires_in":   "3599",
+		"expires_on":   strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10),
+		"resource":     r.URL.Query().Get("resource"),
+		"token_type":   "Bearer",
+	})
+}
+
+// issue new access token. Requires lock
+func (s *Server) issue() string {
+	token := "eyJ0." + randomID()
+	s.tokens[token] = true
+	return token
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, map[string]interface{}{"error": map[string]string{"code": code, "message": msg}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,119 @@
+package akv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// APIVersion of the Key Vault REST API
+const APIVersion = "7.4"
+
+type kvClient struct {
+	cfg    Config
+	http   *http.Client
+	tokens TokenSource
+}
+
+// apiError error response of the Key Vault API
+type apiError struct {
+	Status  int
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("key vault api error (status %d): %s: %s", e.Status, e.Code, e.Message)
+}
+
+// secretBundle of the Key Vault API
+type secretBundle struct {
+	Value       string `json:"value"`
+	ContentType string `json:"contentType"`
+}
+
+func newClient(cfg Config) (*kvClient, error) {
+	if cfg.VaultSuffix == "" {
+		cfg.VaultSuffix = DefaultVaultSuffix
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	tokens := cfg.TokenSource
+	switch {
+	case tokens != nil:
+	case cfg.ClientSecret != "":
+		tokens = ClientSecretCredential{TenantID: cfg.TenantID, ClientID: cfg.ClientID,
+			ClientSecret: cfg.ClientSecret, AuthorityHost: cfg.AuthorityHost}
+	case cfg.FederatedTokenFile != "":
+		tokens = WorkloadIdentityCredential{TenantID: cfg.TenantID, ClientID: cfg.ClientID,
+			TokenFile: cfg.FederatedTokenFile, AuthorityHost: cfg.AuthorityHost}
+	default:
+		tokens = ManagedIdentityCredential{ClientID: cfg.ClientID}
+	}
+	return &kvClient{
+		cfg:    cfg,
+		http:   &http.Client{Timeout: cfg.Timeout, Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}},
+		tokens: &cachedToken{TokenSource: tokens},
+	}, nil
+}
+
+// Close closes idle connections of the client
+func (c *kvClient) Close() {
+	c.http.CloseIdleConnections()
+}
+
+// vaultURL returns base URL of the vault
+func (c *kvClient) vaultURL(vault string) string {
+	if c.cfg.Endpoint != "" {
+		return strings.TrimRight(strings.Replace(c.cfg.Endpoint, "{vault}", vault, -1), "/")
+	}
+	return "https://" + vault + "." + c.cfg.VaultSuffix
+}
+
+// getSecret returns version of the secret, current version if version is empty
+func (c *kvClient) getSecret(ctx context.Context, vault, name, version string) (*secretBundle, error) {
+	path := "/secrets/" + url.PathEscape(name)
+	if version != "" {
+		path += "/" + url.PathEscape(version)
+	}
+	req, err := http.NewRequest(http.MethodGet, c.vaultURL(vault)+path+"?api-version="+APIVersion, nil)
+	if err != nil {
+		return nil, err
+	}
+	t, err := c.tokens.Token(ctx, "https://"+c.cfg.VaultSuffix)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.AccessToken)
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error apiError `json:"error"`
+		}
+		if json.Unmarshal(body, &e) != nil || e.Error.Code == "" {
+			e.Error.Message = strings.TrimSpace(string(body))
+		}
+		e.Error.Status = resp.StatusCode
+		return nil, &e.Error
+	}
+	var s secretBundle
+	if err := json.Unmarshal(body, &s); err != nil {
+		return nil, errors.Wrap(err, "invalid key vault response")
+	}
+	return &s, nil
+}
@@ -0,0 +1,81 @@
+package akv
+
+import (
+	"os"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/storeclient"
+)
+
+// Config of the Key Vault client
+type Config struct {
+	// TenantID, ClientID and ClientSecret of the service principal
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	// FederatedTokenFile with the token of the workload identity, e.g. AKS service account token
+	FederatedTokenFile string
+	// AuthorityHost of the Microsoft Entra ID, DefaultAuthorityHost if empty
+	AuthorityHost string
+	// TokenSource of the access tokens. If nil, client secret, workload identity or managed identity
+	// (ClientID of the user-assigned identity) is used depending on the set fields
+	TokenSource TokenSource
+	// VaultSuffix DNS suffix of the vaults, DefaultVaultSuffix if empty
+	VaultSuffix string
+	// Endpoint overrides URL of the vaults, e.g. "http://localhost:8443/{vault}".
+	// "{vault}" is replaced with the vault name
+	Endpoint string
+	// Timeout of a single request
+	Timeout time.Duration
+}
+
+// Defaults of the config
+const (
+	DefaultAuthorityHost = "https://login.microsoftonline.com"
+	DefaultVaultSuffix   = "vault.azure.net"
+	DefaultTimeout       = 10 * time.Second
+)
+
+//nolint:gochecknoglobals
+var clients storeclient.Holder
+
+// Init Key Vault client. Config from the environment is used if cfg is nil
+// or Init was not called before the first read
+func Init(cfg *Config) error {
+	if cfg == nil {
+		c := ConfigFromEnv()
+		cfg = &c
+	}
+	c, err := newClient(*cfg)
+	if err != nil {
+		return err
+	}
+	clients.Set(c)
+	return nil
+}
+
+func getClient() (*kvClient, error) {
+	c, err := clients.Get(func() error { return Init(nil) })
+	if err != nil {
+		return nil, err
+	}
+	return c.(*kvClient), nil
+}
+
+// ConfigFromEnv returns config from the environment variables used by the Azure SDKs:
+//
+//	AZURE_TENANT_ID, AZURE_CLIENT_ID, AZURE_CLIENT_SECRET - service principal
+//	AZURE_FEDERATED_TOKEN_FILE - workload identity token, set by the AKS workload identity webhook
+//	AZURE_AUTHORITY_HOST - authority host
+//	NOBLE_AKV_ENDPOINT - endpoint override
+func ConfigFromEnv() Config {
+	return Config{
+		TenantID:           os.Getenv("AZURE_TENANT_ID"),
+		ClientID:           os.Getenv("AZURE_CLIENT_ID"),
+		ClientSecret:       os.Getenv("AZURE_CLIENT_SECRET"),
+		FederatedTokenFile: os.Getenv("AZURE_FEDERATED_TOKEN_FILE"),
+		AuthorityHost:      os.Getenv("AZURE_AUTHORITY_HOST"),
+		Endpoint:           os.Getenv("NOBLE_AKV_ENDPOINT"),
+		Timeout:            DefaultTimeout,
+	}
+}
@@ -0,0 +1,215 @@
+package akv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultIMDSEndpoint of the Azure instance metadata service
+const DefaultIMDSEndpoint = "http://169.254.169.254"
+
+// expiryWindow tokens are refreshed before they expire
+const expiryWindow = time.Minute
+
+// Token OAuth 2.0 access token
+type Token struct {
+	AccessToken string
+	// Expiry time of the token, zero if token does not expire
+	Expiry time.Time
+}
+
+// TokenSource of the access tokens for the resource, e.g. "https://vault.azure.net"
+type TokenSource interface {
+	Token(ctx context.Context, resource string) (Token, error)
+}
+
+// StaticToken returns fixed access token
+type StaticToken string
+
+// Token implements TokenSource
+func (t StaticToken) Token(context.Context, string) (Token, error) {
+	return Token{AccessToken: string(t)}, nil
+}
+
+// ClientSecretCredential of the service principal
+type ClientSecretCredential struct {
+	TenantID      string
+	ClientID      string
+	ClientSecret  string
+	AuthorityHost string
+}
+
+// Token implements TokenSource
+func (c ClientSecretCredential) Token(ctx context.Context, resource string) (Token, error) {
+	return clientCredentials(ctx, c.AuthorityHost, c.TenantID, url.Values{
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+		"scope":         {resource + "/.default"},
+	})
+}
+
+// WorkloadIdentityCredential exchanges federated token for the access token of the application
+type WorkloadIdentityCredential struct {
+	TenantID      string
+	ClientID      string
+	TokenFile     string
+	AuthorityHost string
+}
+
+// Token implements TokenSource. Token file is read on every call as it is rotated by the kubelet
+func (c WorkloadIdentityCredential) Token(ctx context.Context, resource string) (Token, error) {
+	assertion, err := ioutil.ReadFile(c.TokenFile)
+	if err != nil {
+		return Token{}, err
+	}
+	return clientCredentials(ctx, c.AuthorityHost, c.TenantID, url.Values{
+		"client_id":             {c.ClientID},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {strings.TrimSpace(string(assertion))},
+		"scope":                 {resource + "/.default"},
+	})
+}
+
+// ManagedIdentityCredential of the Azure resource. App Service and Functions endpoint is used if
+// IDENTITY_ENDPOINT and IDENTITY_HEADER are set, instance metadata service otherwise
+type ManagedIdentityCredential struct {
+	// ClientID of the user-assigned identity, system-assigned identity if empty
+	ClientID string
+	// Endpoint of the instance metadata service, DefaultIMDSEndpoint if empty
+	Endpoint string
+}
+
+// Token implements TokenSource
+func (c ManagedIdentityCredential) Token(ctx context.Context, resource string) (Token, error) {
+	q := url.Values{"resource": {resource}}
+	if c.ClientID != "" {
+		q.Set("client_id", c.ClientID)
+	}
+	var req *http.Request
+	var err error
+	if endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER"); endpoint != "" && header != "" {
+		q.Set("api-version", "2019-08-01")
+		if req, err = http.NewRequest(http.MethodGet, endpoint+"?"+q.Encode(), nil); err != nil {
+			return Token{}, err
+		}
+		req.Header.Set("X-Identity-Header", header)
+	} else {
+		endpoint := c.Endpoint
+		if endpoint == "" {
+			endpoint = DefaultIMDSEndpoint
+		}
+		q.Set("api-version", "2018-02-01")
+		req, err = http.NewRequest(http.MethodGet,
+			strings.TrimRight(endpoint, "/")+"/metadata/identity/oauth2/token?"+q.Encode(), nil)
+		if err != nil {
+			return Token{}, err
+		}
+		req.Header.Set("Metadata", "true")
+	}
+	body, err := doHTTP(ctx, req)
+	if err != nil {
+		return Token{}, errors.Wrap(err, "managed identity token")
+	}
+	return decodeToken(body)
+}
+
+// clientCredentials requests token with the client credentials grant
+func clientCredentials(ctx context.Context, authority, tenant string, form url.Values) (Token, error) {
+	if tenant == "" || form.Get("client_id") == "" {
+		return Token{}, errors.New("azure tenant and client id are required")
+	}
+	if authority == "" {
+		authority = DefaultAuthorityHost
+	}
+	form.Set("grant_type", "client_credentials")
+	req, err := http.NewRequest(http.MethodPost,
+		strings.TrimRight(authority, "/")+"/"+url.PathEscape(tenant)+"/oauth2/v2.0/token",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := doHTTP(ctx, req)
+	if err != nil {
+		return Token{}, errors.Wrap(err, "azure token")
+	}
+	return decodeToken(body)
+}
+
+// decodeToken of the token endpoints. Managed identity endpoints return numbers as strings
+func decodeToken(body []byte) (Token, error) {
+	var out struct {
+		AccessToken string          `json:"access_token"`
+		ExpiresIn   json.RawMessage `json:"expires_in"`
+		ExpiresOn   json.RawMessage `json:"expires_on"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil || out.AccessToken == "" {
+		return Token{}, errors.New("invalid token response")
+	}
+	t := Token{AccessToken: out.AccessToken}
+	if on := number(out.ExpiresOn); on > 0 {
+		t.Expiry = time.Unix(on, 0)
+	} else if in := number(out.ExpiresIn); in > 0 {
+		t.Expiry = time.Now().Add(time.Duration(in) * time.Second)
+	}
+	return t, nil
+}
+
+// number of the JSON number or string, zero if invalid
+func number(raw json.RawMessage) int64 {
+	n, _ := strconv.ParseInt(strings.Trim(string(raw), `"`), 10, 64)
+	return n
+}
+
+// cachedToken keeps tokens of the resources until they expire
+type cachedToken struct {
+	TokenSource
+	mu     sync.Mutex
+	tokens map[string]Token
+}
+
+// Token implements TokenSource
+func (c *cachedToken) Token(ctx context.Context, resource string) (Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t, ok := c.tokens[resource]; ok && (t.Expiry.IsZero() || time.Until(t.Expiry) > expiryWindow) {
+		return t, nil
+	}
+	t, err := c.TokenSource.Token(ctx, resource)
+	if err != nil {
+		return Token{}, err
+	}
+	if c.tokens == nil {
+		c.tokens = make(map[string]Token)
+	}
+	c.tokens[resource] = t
+	return t, nil
+}
+
+// doHTTP sends request and returns body of the 200 response
+func doHTTP(ctx context.Context, req *http.Request) ([]byte, error) {
+	resp, err := (&http.Client{Timeout: DefaultTimeout}).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
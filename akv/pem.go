@@ -0,0 +1,111 @@
+package akv
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+
+	"github.com/pkg/errors"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Content types of the certificate secrets
+const (
+	ContentTypePKCS12 = "application/x-pkcs12"
+	ContentTypePEM    = "application/x-pem-file"
+)
+
+// Parts of the certificate
+const (
+	PartAll  = "all"
+	PartKey  = "key"
+	PartCert = "cert"
+)
+
+// toPEM converts secret of the certificate to PEM: private key followed by the certificates
+func toPEM(s *secretBundle, part string) (string, error) {
+	var blocks []*pem.Block
+	switch {
+	case s.ContentType == ContentTypePKCS12:
+		var err error
+		if blocks, err = pkcs12PEM(s.Value); err != nil {
+			return "", errors.Wrap(err, "invalid pkcs12 value")
+		}
+	case s.ContentType == ContentTypePEM || strings.HasPrefix(strings.TrimSpace(s.Value), "-----BEGIN"):
+		rest := []byte(s.Value)
+		for {
+			var b *pem.Block
+			if b, rest = pem.Decode(rest); b == nil {
+				break
+			}
+			blocks = append(blocks, b)
+		}
+	default:
+		return "", errors.New("secret is not a certificate: content type " + s.ContentType)
+	}
+
+	var keys, certs bytes.Buffer
+	for _, b := range blocks {
+		switch {
+		case strings.HasSuffix(b.Type, "PRIVATE KEY"):
+			der, err := pkcs8(b.Bytes)
+			if err != nil {
+				return "", err
+			}
+			_ = pem.Encode(&keys, &pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		case b.Type == "CERTIFICATE":
+			_ = pem.Encode(&certs, &pem.Block{Type: b.Type, Bytes: b.Bytes})
+		}
+	}
+	var res string
+	switch part {
+	case PartKey:
+		res = keys.String()
+	case PartCert:
+		res = certs.String()
+	default:
+		res = keys.String() + certs.String()
+	}
+	if res == "" {
+		return "", errors.New("certificate has no " + part + " blocks")
+	}
+	return res, nil
+}
+
+// pkcs12PEM decodes base64 PFX without password to the PEM blocks of the private key and the certificate chain
+func pkcs12PEM(value string) ([]*pem.Block, error) {
+	der, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	key, cert, chain, err := pkcs12.DecodeChain(der, "")
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	blocks := []*pem.Block{{Type: "PRIVATE KEY", Bytes: keyDER}}
+	for _, c := range append([]*x509.Certificate{cert}, chain...) {
+		blocks = append(blocks, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+	}
+	return blocks, nil
+}
+
+// pkcs8 returns PKCS #8 form of the PKCS #8, PKCS #1 or SEC 1 private key
+func pkcs8(der []byte) ([]byte, error) {
+	if _, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return der, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return x509.MarshalPKCS8PrivateKey(key)
+	}
+	key, err := x509.ParseECPrivateKey(der)
+	if err != nil {
+		return nil, errors.New("unsupported private key")
+	}
+	return x509.MarshalPKCS8PrivateKey(key)
+}
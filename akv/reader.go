@@ -0,0 +1,93 @@
+// Package akv Noble Azure Key Vault reader
+package akv
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/jsonpath"
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("akv", &KeyReader{})
+}
+
+//nolint:gochecknoglobals
+var errKeyFormat = errors.New("incorrect key format. " +
+	"use <vault>/<secret>[/<version>][?format=pem[&part=key|cert]][#<field>]")
+
+// KeyReader type implements noble.SecretStorage
+//
+// Key format: <vault>/<secret>[/<version>][?format=pem[&part=all|key|cert]][#<field>]
+//
+//	akv:my-vault/db-password - current version of the secret
+//	akv:my-vault/db-password/1f2e3d4c5b6a - pinned version
+//	akv:my-vault/db-creds#password - field of the JSON value
+//	akv:my-vault/api-tls?format=pem - private key and certificate chain of the certificate
+//	akv:my-vault/api-tls?format=pem&part=cert - certificate chain only
+//
+// Certificates are read through their secret (PKCS #12 or PEM content type)
+type KeyReader struct {
+}
+
+// Read secret value from Azure Key Vault
+func (r *KeyReader) Read(key string) (string, error) {
+	c, err := getClient()
+	if err != nil {
+		return "", err
+	}
+	var field string
+	if i := strings.Index(key, "#"); i >= 0 {
+		key, field = key[:i], key[i+1:]
+	}
+	query := url.Values{}
+	if i := strings.Index(key, "?"); i >= 0 {
+		if query, err = url.ParseQuery(key[i+1:]); err != nil {
+			return "", errKeyFormat
+		}
+		key = key[:i]
+	}
+	format, part := query.Get("format"), query.Get("part")
+	for k := range query {
+		if k != "format" && k != "part" {
+			return "", errKeyFormat
+		}
+	}
+	if format != "" && format != "pem" || part != "" && (format == "" ||
+		part != PartAll && part != PartKey && part != PartCert) {
+		return "", errKeyFormat
+	}
+	parts := strings.Split(key, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return "", errKeyFormat
+	}
+	var version string
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+
+	s, err := c.getSecret(context.Background(), parts[0], parts[1], version)
+	if err != nil {
+		return "", errors.Wrap(err, "akv secret "+key)
+	}
+	val := s.Value
+	if format == "pem" {
+		if val, err = toPEM(s, part); err != nil {
+			return "", errors.Wrap(err, "akv secret "+key)
+		}
+	}
+	if field == "" {
+		return val, nil
+	}
+	val, err = jsonpath.Extract([]byte(val), field)
+	return val, errors.Wrap(err, "akv secret "+key)
+}
+
+// Clone returns new empty instance of KeyReader
+func (r *KeyReader) Clone() noble.SecretStorage {
+	return &KeyReader{}
+}
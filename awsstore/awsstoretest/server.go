@@ -0,0 +1,520 @@
+// Package awsstoretest provides in-process fake of the AWS Secrets Manager, SSM Parameter Store,
+// S3 and STS APIs for tests.
+//
+// Implemented subset: GetSecretValue, GetParameter, path-style GetObject with versions, conditional
+// requests and SSE-C, AssumeRole, AssumeRoleWithWebIdentity, IAM Identity Center GetRoleCredentials,
+// Signature Version 4 verification of the added credentials.
+package awsstoretest
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/sigv4"
+)
+
+// Staging labels of the secret versions
+const (
+	StageCurrent  = "AWSCURRENT"
+	StagePrevious = "AWSPREVIOUS"
+)
+
+type secretVersion struct {
+	id     string
+	value  *string
+	binary []byte
+	stages []string
+}
+
+type parameterVersion struct {
+	version int64
+	value   string
+	secure  bool
+	labels  []string
+}
+
+type objectVersion struct {
+	id          string
+	data        []byte
+	etag        string
+	customerKey []byte
+}
+
+type account struct {
+	secret string
+	token  string
+}
+
+// Server fake AWS API server
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	accounts    map[string]account
+	identities  map[string]string
+	roles       map[string]bool
+	ssoTokens   map[string]string
+	secrets     map[string][]*secretVersion
+	parameters  map[string][]*parameterVersion
+	objects     map[string][]*objectVersion
+	requests    int
+	credentials int
+	// Region expected in the credential scope, any if empty
+	Region string
+}
+
+// NewServer starts fake API server. Caller must Close it
+func NewServer() *Server {
+	s := &Server{
+		accounts:   make(map[string]account),
+		identities: make(map[string]string),
+		roles:      make(map[string]bool),
+		ssoTokens:  make(map[string]string),
+		secrets:    make(map[string][]*secretVersion),
+		parameters: make(map[string][]*parameterVersion),
+		objects:    make(map[string][]*objectVersion),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Start fake API server closed on test cleanup
+func Start(t testing.TB) *Server {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// AddCredentials enables authentication: only requests signed with one of the added keys are allowed
+func (s *Server) AddCredentials(accessKeyID, secretAccessKey string) *Server {
+	s.mu.Lock()
+	s.accounts[accessKeyID] = account{secret: secretAccessKey}
+	s.mu.Unlock()
+	return s
+}
+
+// AddWebIdentity allows AssumeRoleWithWebIdentity of the role with the token.
+// Issued temporary credentials are added to the server
+func (s *Server) AddWebIdentity(token, roleARN string) *Server {
+	s.mu.Lock()
+	s.identities[token] = roleARN
+	s.mu.Unlock()
+	return s
+}
+
+// AddRole allows AssumeRole of the role for requests signed with the added credentials.
+// Issued temporary credentials are added to the server
+func (s *Server) AddRole(roleARN string) *Server {
+	s.mu.Lock()
+	s.roles[roleARN] = true
+	s.mu.Unlock()
+	return s
+}
+
+// AddSSOToken allows GetRoleCredentials of the account role with the access token of "aws sso login".
+// Issued temporary credentials are added to the server
+func (s *Server) AddSSOToken(token, accountID, roleName string) *Server {
+	s.mu.Lock()
+	s.ssoTokens[token] = accountID + "/" + roleName
+	s.mu.Unlock()
+	return s
+}
+
+// Requests returns count of handled requests
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// PutSecret adds new version of the secret string and moves AWSCURRENT label to it. Returns version ID
+func (s *Server) PutSecret(id, value string) string {
+	return s.put(id, &secretVersion{value: &value})
+}
+
+// PutSecretBinary adds new version of the binary secret and moves AWSCURRENT label to it. Returns version ID
+func (s *Server) PutSecretBinary(id string, value []byte) string {
+	return s.put(id, &secretVersion{binary: value})
+}
+
+func (s *Server) put(id string, v *secretVersion) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v.id = randomID()
+	v.stages = []string{StageCurrent}
+	for _, prev := range s.secrets[id] {
+		current := hasLabel(prev.stages, StageCurrent)
+		prev.stages = removeLabel(removeLabel(prev.stages, StagePrevious), StageCurrent)
+		if current {
+			prev.stages = append(prev.stages, StagePrevious)
+		}
+	}
+	s.secrets[id] = append(s.secrets[id], v)
+	return v.id
+}
+
+// PutParameter adds new version of the parameter. Returns version number
+func (s *Server) PutParameter(name, value string, secure bool) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := &parameterVersion{version: int64(len(s.parameters[name]) + 1), value: value, secure: secure}
+	s.parameters[name] = append(s.parameters[name], v)
+	return v.version
+}
+
+// LabelParameter moves label to the parameter version
+func (s *Server) LabelParameter(name string, version int64, label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range s.parameters[name] {
+		v.labels = removeLabel(v.labels, label)
+		if v.version == version {
+			v.labels = append(v.labels, label)
+		}
+	}
+}
+
+// PutObject adds new version of the object. Returns version ID
+func (s *Server) PutObject(bucket, key string, data []byte) string {
+	return s.PutObjectSSEC(bucket, key, data, nil)
+}
+
+// PutObjectSSEC adds new version of the object encrypted with the customer key. Returns version ID
+func (s *Server) PutObjectSSEC(bucket, key string, data, customerKey []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum := md5.Sum(append(append([]byte{}, data...), customerKey...)) //nolint:gosec
+	v := &objectVersion{id: randomID(), data: data, etag: `"` + hex.EncodeToString(sum[:]) + `"`,
+		customerKey: customerKey}
+	s.objects[bucket+"/"+key] = append(s.objects[bucket+"/"+key], v)
+	return v.id
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if r.Method == http.MethodGet && r.URL.Path == "/federation/credentials" {
+		s.sso(w, r)
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path != "/" {
+		s.getObject(w, r, body)
+		return
+	}
+	if r.Method != http.MethodPost || r.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, "UnknownOperationException", "")
+		return
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		s.sts(w, r, body)
+		return
+	}
+	target := r.Header.Get("X-Amz-Target")
+	service := map[string]string{
+		"secretsmanager.GetSecretValue": "secretsmanager",
+		"AmazonSSM.GetParameter":        "ssm",
+	}[target]
+	if service == "" {
+		writeError(w, http.StatusBadRequest, "UnknownOperationException", target)
+		return
+	}
+	if !s.verify(r, body, service) {
+		writeError(w, http.StatusForbidden, "UnrecognizedClientException", "The security token included in the request is invalid.")
+		return
+	}
+	var in map[string]interface{}
+	if err := json.Unmarshal(body, &in); err != nil {
+		writeError(w, http.StatusBadRequest, "SerializationException", err.Error())
+		return
+	}
+	if service == "secretsmanager" {
+		s.getSecretValue(w, in)
+		return
+	}
+	s.getParameter(w, in)
+}
+
+// verify Signature Version 4 of the request. Requires lock
+func (s *Server) verify(r *http.Request, body []byte, service string) bool {
+	if len(s.accounts) == 0 {
+		return true
+	}
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), sigv4.Algorithm+" ")
+	fields := make(map[string]string)
+	for _, f := range strings.Split(auth, ", ") {
+		if kv := strings.SplitN(f, "=", 2); len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	cred := strings.SplitN(fields["Credential"], "/", 2)
+	if len(cred) != 2 {
+		return false
+	}
+	acc, ok := s.accounts[cred[0]]
+	now, err := time.Parse(sigv4.TimeFormat, r.Header.Get("X-Amz-Date"))
+	if !ok || err != nil || acc.token != r.Header.Get("X-Amz-Security-Token") {
+		return false
+	}
+	scope := strings.Split(cred[1], "/")
+	if len(scope) != 4 || scope[2] != service || s.Region != "" && scope[1] != s.Region ||
+		scope[0] != now.Format(sigv4.ShortFormat) {
+		return false
+	}
+	payloadHash := sigv4.HashHex(body)
+	if service == "s3" && r.Header.Get("X-Amz-Content-Sha256") != payloadHash {
+		return false
+	}
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	sig := sigv4.Signature(r, signed, payloadHash, acc.secret, cred[1], now)
+	return sig == fields["Signature"]
+}
+
+// getSecretValue handles secretsmanager.GetSecretValue. Requires lock
+func (s *Server) getSecretValue(w http.ResponseWriter, in map[string]interface{}) {
+	id, _ := in["SecretId"].(string)
+	versionID, _ := in["VersionId"].(string)
+	stage, _ := in["VersionStage"].(string)
+	if versionID == "" && stage == "" {
+		stage = StageCurrent
+	}
+	versions, ok := s.secrets[id]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "ResourceNotFoundException", "Secrets Manager can't find the specified secret.")
+		return
+	}
+	for _, v := range versions {
+		if (versionID == "" || v.id == versionID) && (stage == "" || hasLabel(v.stages, stage)) {
+			out := map[string]interface{}{"Name": id, "VersionId": v.id, "VersionStages": v.stages}
+			if v.value != nil {
+				out["SecretString"] = *v.value
+			} else {
+				out["SecretBinary"] = v.binary
+			}
+			writeJSON(w, http.StatusOK, out)
+			return
+		}
+	}
+	writeError(w, http.StatusBadRequest, "ResourceNotFoundException",
+		"Secrets Manager can't find the specified secret value for staging label: "+stage)
+}
+
+// getParameter handles AmazonSSM.GetParameter. Requires lock
+func (s *Server) getParameter(w http.ResponseWriter, in map[string]interface{}) {
+	name, _ := in["Name"].(string)
+	decrypt, _ := in["WithDecryption"].(bool)
+	selector := ""
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name, selector = name[:i], name[i+1:]
+	}
+	versions, ok := s.parameters[name]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "ParameterNotFound", "")
+		return
+	}
+	var p *parameterVersion
+	for _, v := range versions {
+		if selector == "" || strconv.FormatInt(v.version, 10) == selector || hasLabel(v.labels, selector) {
+			p = v
+		}
+	}
+	if p == nil {
+		writeError(w, http.StatusBadRequest, "ParameterVersionNotFound", "")
+		return
+	}
+	typ, value := "String", p.value
+	if p.secure {
+		typ = "SecureString"
+		if !decrypt {
+			value = base64.StdEncoding.EncodeToString(append([]byte("encrypted:"), p.value...))
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"Parameter": map[string]interface{}{
+		"Name": name, "Type": typ, "Value": value, "Version": p.version, "Selector": selector,
+	}})
+}
+
+// getObject handles path-style GetObject. Requires lock
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, body []byte) {
+	if !s.verify(r, body, "s3") {
+		writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+	versions := s.objects[strings.TrimPrefix(r.URL.Path, "/")]
+	var v *objectVersion
+	if id := r.URL.Query().Get("versionId"); id != "" {
+		for _, ver := range versions {
+			if ver.id == id {
+				v = ver
+			}
+		}
+	} else if len(versions) > 0 {
+		v = versions[len(versions)-1]
+	}
+	if v == nil {
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	key, _ := base64.StdEncoding.DecodeString(r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key"))
+	sum := md5.Sum(key) //nolint:gosec
+	switch {
+	case v.customerKey == nil && len(key) > 0:
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest")
+		return
+	case v.customerKey != nil && (r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "AES256" ||
+		r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5") != base64.StdEncoding.EncodeToString(sum[:])):
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest")
+		return
+	case v.customerKey != nil && !bytes.Equal(v.customerKey, key):
+		writeS3Error(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+	w.Header().Set("ETag", v.etag)
+	w.Header().Set("X-Amz-Version-Id", v.id)
+	if r.Header.Get("If-None-Match") == v.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(v.data)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<Error><Code>" + code + "</Code></Error>"))
+}
+
+// sts handles AssumeRole and AssumeRoleWithWebIdentity. Requires lock
+func (s *Server) sts(w http.ResponseWriter, r *http.Request, body []byte) {
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("Version") != "2011-06-15" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	action, role := r.PostForm.Get("Action"), r.PostForm.Get("RoleArn")
+	var allowed bool
+	switch action {
+	case "AssumeRoleWithWebIdentity":
+		allowed = s.identities[r.PostForm.Get("WebIdentityToken")] == role
+	case "AssumeRole":
+		allowed = s.roles[role] && len(s.accounts) > 0 && s.verify(r, body, "sts")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !allowed || role == "" || r.PostForm.Get("RoleSessionName") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`<ErrorResponse><Error><Code>AccessDenied</Code></Error></ErrorResponse>`))
+		return
+	}
+	id, acc := s.issue()
+	type credentials struct {
+		AccessKeyID     string `xml:"AccessKeyId"`
+		SecretAccessKey string
+		SessionToken    string
+		Expiration      string
+	}
+	type result struct {
+		Credentials     credentials
+		AssumedRoleUser struct {
+			Arn           string
+			AssumedRoleID string `xml:"AssumedRoleId"`
+		}
+	}
+	type metadata struct {
+		RequestID string `xml:"RequestId"`
+	}
+	out := struct {
+		XMLName          xml.Name
+		Xmlns            string `xml:"xmlns,attr"`
+		Result           result
+		ResponseMetadata metadata
+	}{XMLName: xml.Name{Local: action + "Response"}, Xmlns: "https://sts.amazonaws.com/doc/2011-06-15/"}
+	out.Result.Credentials = credentials{AccessKeyID: id, SecretAccessKey: acc.secret, SessionToken: acc.token,
+		Expiration: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)}
+	out.Result.AssumedRoleUser.Arn = role + "/" + r.PostForm.Get("RoleSessionName")
+	out.Result.AssumedRoleUser.AssumedRoleID = "AROA" + id + ":" + r.PostForm.Get("RoleSessionName")
+	out.ResponseMetadata.RequestID = randomID()
+	w.Header().Set("Content-Type", "text/xml")
+	data, _ := xml.Marshal(out)
+	// result element is named by the action
+	data = bytes.Replace(data, []byte("<Result>"), []byte("<"+action+"Result>"), 1)
+	data = bytes.Replace(data, []byte("</Result>"), []byte("</"+action+"Result>"), 1)
+	_, _ = w.Write(data)
+}
+
+// sso handles GetRoleCredentials of the IAM Identity Center portal. Requires lock
+func (s *Server) sso(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if s.ssoTokens[r.Header.Get("X-Amz-Sso_bearer_token")] != q.Get("account_id")+"/"+q.Get("role_name") {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Session token not found or invalid"})
+		return
+	}
+	id, acc := s.issue()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"roleCredentials": map[string]interface{}{
+		"accessKeyId":     id,
+		"secretAccessKey": acc.secret,
+		"sessionToken":    acc.token,
+		"expiration":      time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond),
+	}})
+}
+
+// issue temporary credentials. Requires lock
+func (s *Server) issue() (string, account) {
+	s.credentials++
+	id := "ASIA" + strconv.Itoa(s.credentials)
+	acc := account{secret: randomID(), token: randomID()}
+	s.accounts[id] = acc
+	return id, acc
+}
+
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+func removeLabel(labels []string, label string) []string {
+	res := labels[:0]
+	for _, l := range labels {
+		if l != label {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, map[string]string{"__type": code, "message": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
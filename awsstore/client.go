@@ -0,0 +1,137 @@
+package awsstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/sigv4"
+	"github.com/pkg/errors"
+)
+
+// Signing names of the services
+const (
+	serviceSecretsManager = "secretsmanager"
+	serviceSSM            = "ssm"
+	serviceS3             = "s3"
+	serviceSTS            = "sts"
+)
+
+type awsClient struct {
+	cfg   Config
+	http  *http.Client
+	creds Provider
+
+	// objects cache of the S3 objects by URL and customer key
+	objMu   sync.Mutex
+	objects map[string]*object
+}
+
+// apiError error response of the AWS JSON API
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("aws api error (status %d): %s: %s", e.Status, e.Code, e.Message)
+}
+
+func newClient(cfg Config) (*awsClient, error) {
+	if cfg.Region == "" && cfg.customEndpoint(serviceS3) != "" {
+		cfg.Region = DefaultS3Region
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("aws region is not set")
+	}
+	if cfg.Profile == "" {
+		cfg.Profile = DefaultProfile
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	creds := cfg.Credentials
+	if creds == nil {
+		creds = DefaultChain(cfg)
+	}
+	return &awsClient{
+		cfg:     cfg,
+		http:    &http.Client{Timeout: cfg.Timeout, Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}},
+		creds:   &cachedProvider{Provider: creds},
+		objects: make(map[string]*object),
+	}, nil
+}
+
+// Close closes idle connections of the client
+func (c *awsClient) Close() {
+	c.http.CloseIdleConnections()
+}
+
+// sign request with the current credentials
+func (c *awsClient) sign(ctx context.Context, req *http.Request, body []byte, service string) error {
+	creds, err := c.creds.Retrieve(ctx)
+	if err != nil {
+		return err
+	}
+	sigv4.Sign(req, body, sigv4.Key{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	}, c.cfg.Region, service, time.Now())
+	return nil
+}
+
+// call action of the JSON 1.1 API, e.g. "secretsmanager.GetSecretValue", and decode response to out
+func (c *awsClient) call(ctx context.Context, service, target string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.cfg.serviceEndpoint(service)+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	if err := c.sign(ctx, req, body, service); err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Type     string `json:"__type"`
+			Message  string `json:"message"`
+			MessageU string `json:"Message"`
+		}
+		_ = json.Unmarshal(data, &e)
+		apiErr := &apiError{Status: resp.StatusCode, Code: e.Type, Message: e.Message}
+		// "com.amazonaws.secretsmanager#ResourceNotFoundException"
+		if i := strings.LastIndex(apiErr.Code, "#"); i >= 0 {
+			apiErr.Code = apiErr.Code[i+1:]
+		}
+		if apiErr.Message == "" {
+			apiErr.Message = e.MessageU
+		}
+		if apiErr.Code == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return apiErr
+	}
+	return errors.Wrap(json.Unmarshal(data, out), "invalid aws response")
+}
@@ -0,0 +1,127 @@
+package awsstore
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/storeclient"
+)
+
+// Config of the AWS client
+type Config struct {
+	// Region of the services, e.g. "eu-west-1". DefaultS3Region if empty and S3 endpoint is overridden
+	Region string
+	// Endpoint overrides URL of all services, e.g. "http://localhost:4566" for LocalStack
+	Endpoint string
+	// S3Endpoint overrides URL of the S3 only, e.g. "http://minio:9000" for MinIO with AWS Secrets Manager and SSM.
+	// Takes precedence over Endpoint
+	S3Endpoint string
+	// Profile of the shared credentials and config files
+	Profile string
+	// Credentials provider. DefaultChain of the Profile if nil
+	Credentials Provider
+	// Timeout of a single request
+	Timeout time.Duration
+	// S3PathStyle addresses buckets by path instead of virtual host. Always used with endpoint override,
+	// e.g. for MinIO
+	S3PathStyle bool
+	// SSECustomerKeys of the buckets: objects are read with the server-side encryption customer key (SSE-C).
+	// Value is 32 bytes AES-256 key, raw or base64 encoded
+	SSECustomerKeys map[string]noble.Secret
+}
+
+// Defaults of the config
+const (
+	DefaultProfile = "default"
+	DefaultTimeout = 10 * time.Second
+	// DefaultS3Region signs requests to the S3 compatible storage when region is not set, as MinIO expects
+	DefaultS3Region = "us-east-1"
+)
+
+//nolint:gochecknoglobals
+var clients storeclient.Holder
+
+// Init AWS client. Config from the environment is used if cfg is nil
+// or Init was not called before the first read
+func Init(cfg *Config) error {
+	if cfg == nil {
+		c := ConfigFromEnv()
+		cfg = &c
+	}
+	c, err := newClient(*cfg)
+	if err != nil {
+		return err
+	}
+	clients.Set(c)
+	return nil
+}
+
+func getClient() (*awsClient, error) {
+	c, err := clients.Get(func() error { return Init(nil) })
+	if err != nil {
+		return nil, err
+	}
+	return c.(*awsClient), nil
+}
+
+// ConfigFromEnv returns config from the environment variables used by the AWS CLI:
+//
+//	AWS_PROFILE - profile of the shared files (DefaultProfile by default)
+//	AWS_REGION or AWS_DEFAULT_REGION - region, "region" of the profile in the shared config file otherwise
+//	AWS_ENDPOINT_URL - endpoint of all services
+//
+// Service specific AWS_ENDPOINT_URL_SECRETS_MANAGER, AWS_ENDPOINT_URL_SSM, AWS_ENDPOINT_URL_S3, AWS_ENDPOINT_URL_STS
+// and AWS_ENDPOINT_URL_SSO are used by the client when Endpoint is empty
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Profile:  DefaultProfile,
+		Region:   os.Getenv("AWS_REGION"),
+		Endpoint: os.Getenv("AWS_ENDPOINT_URL"),
+		Timeout:  DefaultTimeout,
+	}
+	if p := os.Getenv("AWS_PROFILE"); p != "" {
+		cfg.Profile = p
+	}
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if cfg.Region == "" {
+		cfg.Region = sharedConfig(cfg.Profile)["region"]
+	}
+	return cfg
+}
+
+// serviceEndpoint returns URL of the service by the signing name
+func (cfg Config) serviceEndpoint(service string) string {
+	if e := cfg.customEndpoint(service); e != "" {
+		return e
+	}
+	return "https://" + service + "." + cfg.Region + cfg.domain()
+}
+
+// customEndpoint returns overridden URL of the service, empty if not set
+func (cfg Config) customEndpoint(service string) string {
+	if service == serviceS3 && cfg.S3Endpoint != "" {
+		return strings.TrimRight(cfg.S3Endpoint, "/")
+	}
+	if cfg.Endpoint != "" {
+		return strings.TrimRight(cfg.Endpoint, "/")
+	}
+	env := map[string]string{
+		serviceSecretsManager: "AWS_ENDPOINT_URL_SECRETS_MANAGER",
+		serviceSSM:            "AWS_ENDPOINT_URL_SSM",
+		serviceS3:             "AWS_ENDPOINT_URL_S3",
+		serviceSTS:            "AWS_ENDPOINT_URL_STS",
+		serviceSSO:            "AWS_ENDPOINT_URL_SSO",
+	}
+	return strings.TrimRight(os.Getenv(env[service]), "/")
+}
+
+func (cfg Config) domain() string {
+	if strings.HasPrefix(cfg.Region, "cn-") {
+		return ".amazonaws.com.cn"
+	}
+	return ".amazonaws.com"
+}
@@ -0,0 +1,551 @@
+package awsstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/sigv4"
+	"github.com/pkg/errors"
+)
+
+// Credentials of the AWS account
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Expires time of the temporary credentials, zero if credentials do not expire
+	Expires time.Time
+}
+
+// Provider of the credentials
+type Provider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// ErrNoCredentials returned by a provider when its source is not configured
+var ErrNoCredentials = errors.New("aws credentials not found")
+
+// expiryWindow credentials are refreshed before they expire
+const expiryWindow = time.Minute
+
+// defaultSTSRegion signs STS requests when region is not configured
+const defaultSTSRegion = "us-east-1"
+
+// StaticProvider returns fixed credentials
+type StaticProvider Credentials
+
+// Retrieve implements Provider
+func (p StaticProvider) Retrieve(context.Context) (Credentials, error) {
+	if p.AccessKeyID == "" || p.SecretAccessKey == "" {
+		return Credentials{}, ErrNoCredentials
+	}
+	return Credentials(p), nil
+}
+
+// EnvProvider reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables
+type EnvProvider struct{}
+
+// Retrieve implements Provider
+func (EnvProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	return StaticProvider{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}.Retrieve(ctx)
+}
+
+// SharedFileProvider reads profile of the shared credentials file (AWS_SHARED_CREDENTIALS_FILE
+// or ~/.aws/credentials by default) and of the shared config file. Profiles with role_arn assume the role
+// with credentials of source_profile, credential_source (Environment, Ec2InstanceMetadata or EcsContainer)
+// or web_identity_token_file. Profiles with sso_account_id and sso_role_name use SSOProvider
+type SharedFileProvider struct {
+	Filename string
+	Profile  string
+	// Config of the STS and SSO clients: region, endpoint and timeout
+	Config Config
+}
+
+// maxSourceProfiles limits chain of the source profiles
+const maxSourceProfiles = 8
+
+// Retrieve implements Provider
+func (p SharedFileProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	name := p.Filename
+	if name == "" {
+		name = sharedFile("AWS_SHARED_CREDENTIALS_FILE", "credentials")
+	}
+	profile := p.Profile
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	sections, err := parseINI(name)
+	if err != nil && !os.IsNotExist(err) {
+		return Credentials{}, err
+	}
+	return p.retrieve(ctx, sections, profile, 0)
+}
+
+// retrieve credentials of the profile, values of the credentials file take precedence over the config file
+func (p SharedFileProvider) retrieve(ctx context.Context, sections map[string]map[string]string, profile string,
+	depth int) (Credentials, error) {
+	if depth > maxSourceProfiles {
+		return Credentials{}, errors.New("aws profile " + profile + ": too many source profiles")
+	}
+	values := make(map[string]string)
+	for k, v := range sharedConfig(profile) {
+		values[k] = v
+	}
+	for k, v := range sections[profile] {
+		values[k] = v
+	}
+	static := StaticProvider{
+		AccessKeyID:     values["aws_access_key_id"],
+		SecretAccessKey: values["aws_secret_access_key"],
+		SessionToken:    values["aws_session_token"],
+	}
+	cfg := p.Config
+	if cfg.Region == "" {
+		cfg.Region = values["region"]
+	}
+	role := values["role_arn"]
+	switch {
+	case role != "" && values["web_identity_token_file"] != "":
+		return WebIdentityProvider{RoleARN: role, TokenFile: values["web_identity_token_file"],
+			SessionName: values["role_session_name"], Config: cfg}.Retrieve(ctx)
+	case role != "":
+		var source Provider
+		switch src := values["source_profile"]; {
+		case src == profile:
+			// role is assumed with static credentials of the profile itself
+			source = static
+		case src != "":
+			c, err := p.retrieve(ctx, sections, src, depth+1)
+			if err != nil {
+				return Credentials{}, errors.Wrap(err, "source_profile "+src)
+			}
+			source = StaticProvider(c)
+		case values["credential_source"] == "Environment":
+			source = EnvProvider{}
+		case values["credential_source"] == "Ec2InstanceMetadata":
+			source = IMDSProvider{}
+		case values["credential_source"] == "EcsContainer":
+			source = ContainerProvider{}
+		default:
+			return Credentials{}, errors.New("aws profile " + profile +
+				": role_arn requires source_profile, credential_source or web_identity_token_file")
+		}
+		return AssumeRoleProvider{RoleARN: role, SessionName: values["role_session_name"],
+			ExternalID: values["external_id"], Source: source, Config: cfg}.Retrieve(ctx)
+	case values["sso_account_id"] != "":
+		sso := SSOProvider{
+			StartURL:  values["sso_start_url"],
+			Region:    values["sso_region"],
+			AccountID: values["sso_account_id"],
+			RoleName:  values["sso_role_name"],
+			Session:   values["sso_session"],
+			Config:    p.Config,
+		}
+		if sso.Session != "" {
+			session := sharedConfigSection("sso-session " + sso.Session)
+			sso.StartURL, sso.Region = session["sso_start_url"], session["sso_region"]
+		}
+		return sso.Retrieve(ctx)
+	}
+	return static.Retrieve(ctx)
+}
+
+// WebIdentityProvider exchanges OIDC token (e.g. EKS service account token) for the role credentials
+// with STS AssumeRoleWithWebIdentity. Empty fields are read from AWS_ROLE_ARN,
+// AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_SESSION_NAME environment variables
+type WebIdentityProvider struct {
+	RoleARN     string
+	TokenFile   string
+	SessionName string
+	// Config of the STS client: region, endpoint and timeout
+	Config Config
+}
+
+// Retrieve implements Provider
+func (p WebIdentityProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	role, tokenFile, session := p.RoleARN, p.TokenFile, p.SessionName
+	if role == "" {
+		role = os.Getenv("AWS_ROLE_ARN")
+	}
+	if tokenFile == "" {
+		tokenFile = os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	}
+	if session == "" {
+		session = os.Getenv("AWS_ROLE_SESSION_NAME")
+	}
+	if session == "" {
+		session = "noble-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	if role == "" || tokenFile == "" {
+		return Credentials{}, ErrNoCredentials
+	}
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return assumeRole(ctx, p.Config, url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"RoleArn":          {role},
+		"RoleSessionName":  {session},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}, nil)
+}
+
+// AssumeRoleProvider assumes the role with STS AssumeRole signed by the Source credentials,
+// like profiles with role_arn and source_profile or credential_source
+type AssumeRoleProvider struct {
+	RoleARN     string
+	SessionName string
+	ExternalID  string
+	Source      Provider
+	// Config of the STS client: region, endpoint and timeout
+	Config Config
+}
+
+// Retrieve implements Provider
+func (p AssumeRoleProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	if p.RoleARN == "" || p.Source == nil {
+		return Credentials{}, ErrNoCredentials
+	}
+	src, err := p.Source.Retrieve(ctx)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "source credentials of "+p.RoleARN)
+	}
+	session := p.SessionName
+	if session == "" {
+		session = "noble-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	form := url.Values{
+		"Action":          {"AssumeRole"},
+		"RoleArn":         {p.RoleARN},
+		"RoleSessionName": {session},
+	}
+	if p.ExternalID != "" {
+		form.Set("ExternalId", p.ExternalID)
+	}
+	return assumeRole(ctx, p.Config, form, &src)
+}
+
+// assumeRole calls STS action of the form, signed with the source credentials if not nil,
+// and returns issued credentials
+func assumeRole(ctx context.Context, cfg Config, form url.Values, src *Credentials) (Credentials, error) {
+	if cfg.Region == "" {
+		cfg.Region = defaultSTSRegion
+	}
+	form.Set("Version", "2011-06-15")
+	body := []byte(form.Encode())
+	req, err := http.NewRequest(http.MethodPost, cfg.serviceEndpoint(serviceSTS)+"/", bytes.NewReader(body))
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if src != nil {
+		sigv4.Sign(req, body, sigv4.Key{
+			AccessKeyID:     src.AccessKeyID,
+			SecretAccessKey: src.SecretAccessKey,
+			SessionToken:    src.SessionToken,
+		}, cfg.Region, serviceSTS, time.Now())
+	}
+	type result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"Credentials"`
+	}
+	var out struct {
+		AssumeRole  result `xml:"AssumeRoleResult"`
+		WebIdentity result `xml:"AssumeRoleWithWebIdentityResult"`
+	}
+	resp, err := doHTTP(ctx, timeoutClient(cfg.Timeout), req)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "sts "+form.Get("Action"))
+	}
+	if err := xml.Unmarshal(resp, &out); err != nil {
+		return Credentials{}, errors.Wrap(err, "invalid sts response")
+	}
+	c := out.AssumeRole.Credentials
+	if c.AccessKeyID == "" {
+		c = out.WebIdentity.Credentials
+	}
+	if c.AccessKeyID == "" {
+		return Credentials{}, errors.New("sts " + form.Get("Action") + ": no credentials in response")
+	}
+	return Credentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.SessionToken,
+		Expires: c.Expiration}, nil
+}
+
+// ContainerProvider reads credentials of the ECS task role or EKS Pod Identity from the endpoint set by
+// AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or AWS_CONTAINER_CREDENTIALS_FULL_URI. Authorization token
+// is read from AWS_CONTAINER_AUTHORIZATION_TOKEN or AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE
+type ContainerProvider struct {
+	// Host of the relative URI, ContainerHost if empty
+	Host string
+}
+
+// ContainerHost of the ECS credentials endpoint for relative URI
+const ContainerHost = "http://169.254.170.2"
+
+// Retrieve implements Provider
+func (p ContainerProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	u := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); rel != "" {
+		host := p.Host
+		if host == "" {
+			host = ContainerHost
+		}
+		u = strings.TrimRight(host, "/") + rel
+	}
+	if u == "" {
+		return Credentials{}, ErrNoCredentials
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if f := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); f != "" {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return Credentials{}, err
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	body, err := doHTTP(ctx, timeoutClient(5*time.Second), req)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "container credentials")
+	}
+	return decodeRoleCredentials(body)
+}
+
+// IMDSProvider reads credentials of the EC2 instance profile from the instance metadata service (IMDSv2).
+// Endpoint is read from AWS_EC2_METADATA_SERVICE_ENDPOINT if empty.
+// Provider is disabled by AWS_EC2_METADATA_DISABLED=true
+type IMDSProvider struct {
+	Endpoint string
+}
+
+// DefaultIMDSEndpoint of the instance metadata service
+const DefaultIMDSEndpoint = "http://169.254.169.254"
+
+// Retrieve implements Provider
+func (p IMDSProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	if disabled, _ := strconv.ParseBool(os.Getenv("AWS_EC2_METADATA_DISABLED")); disabled {
+		return Credentials{}, ErrNoCredentials
+	}
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT")
+	}
+	if endpoint == "" {
+		endpoint = DefaultIMDSEndpoint
+	}
+	endpoint = strings.TrimRight(endpoint, "/")
+	hc := timeoutClient(time.Second)
+
+	req, err := http.NewRequest(http.MethodPut, endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600")
+	token, err := doHTTP(ctx, hc, req)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "instance metadata token")
+	}
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequest(http.MethodGet, endpoint+"/latest/meta-data/iam/security-credentials/"+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Aws-Ec2-Metadata-Token", string(token))
+		return doHTTP(ctx, hc, req)
+	}
+	roles, err := get("")
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "instance profile")
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return Credentials{}, ErrNoCredentials
+	}
+	body, err := get(role)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "instance profile "+role)
+	}
+	return decodeRoleCredentials(body)
+}
+
+// ChainProvider returns credentials of the first provider which has them
+type ChainProvider []Provider
+
+// Retrieve implements Provider
+func (p ChainProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	var errs []string
+	for _, provider := range p {
+		c, err := provider.Retrieve(ctx)
+		if err == nil {
+			return c, nil
+		}
+		if err != ErrNoCredentials {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) == 0 {
+		return Credentials{}, ErrNoCredentials
+	}
+	return Credentials{}, errors.Wrap(ErrNoCredentials, strings.Join(errs, "; "))
+}
+
+// DefaultChain of the providers: environment, shared files of the profile (including assumed roles and SSO),
+// web identity, container and instance metadata
+func DefaultChain(cfg Config) Provider {
+	return ChainProvider{
+		EnvProvider{},
+		SharedFileProvider{Profile: cfg.Profile, Config: cfg},
+		WebIdentityProvider{Config: cfg},
+		ContainerProvider{},
+		IMDSProvider{},
+	}
+}
+
+// cachedProvider keeps credentials until they expire
+type cachedProvider struct {
+	Provider
+	mu    sync.Mutex
+	creds *Credentials
+}
+
+// Retrieve implements Provider
+func (p *cachedProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.creds != nil && (p.creds.Expires.IsZero() || time.Until(p.creds.Expires) > expiryWindow) {
+		return *p.creds, nil
+	}
+	c, err := p.Provider.Retrieve(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	p.creds = &c
+	return c, nil
+}
+
+// decodeRoleCredentials of the container and instance metadata response
+func decodeRoleCredentials(body []byte) (Credentials, error) {
+	var out struct {
+		// Code of the instance metadata response, "Success" if credentials are valid
+		Code            string    `json:"Code"`
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return Credentials{}, errors.Wrap(err, "invalid credentials response")
+	}
+	if out.Code != "" && out.Code != "Success" {
+		return Credentials{}, errors.New("credentials response code " + out.Code)
+	}
+	return Credentials{AccessKeyID: out.AccessKeyID, SecretAccessKey: out.SecretAccessKey, SessionToken: out.Token,
+		Expires: out.Expiration}, nil
+}
+
+func timeoutClient(timeout time.Duration) *http.Client {
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// doHTTP sends request and returns body of the 200 response
+func doHTTP(ctx context.Context, hc *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := hc.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// sharedFile returns path from the environment variable or ~/.aws/<name>
+func sharedFile(env, name string) string {
+	if f := os.Getenv(env); f != "" {
+		return f
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", name)
+}
+
+// sharedConfig returns values of the profile from the shared config file (AWS_CONFIG_FILE or ~/.aws/config)
+func sharedConfig(profile string) map[string]string {
+	if profile != DefaultProfile {
+		profile = "profile " + profile
+	}
+	return sharedConfigSection(profile)
+}
+
+// sharedConfigSection returns values of the section of the shared config file
+func sharedConfigSection(name string) map[string]string {
+	sections, _ := parseINI(sharedFile("AWS_CONFIG_FILE", "config"))
+	return sections[name]
+}
+
+// parseINI returns key-value pairs of the sections. Nested values are not supported
+func parseINI(name string) (map[string]map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	sections := make(map[string]map[string]string)
+	var cur map[string]string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[' && line[len(line)-1] == ']':
+			name := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			if cur = sections[name]; cur == nil {
+				cur = make(map[string]string)
+				sections[name] = cur
+			}
+		case cur != nil:
+			if i := strings.Index(line, "="); i > 0 {
+				cur[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+			}
+		}
+	}
+	return sections, sc.Err()
+}
@@ -0,0 +1,162 @@
+// Package awsstore Noble AWS Secrets Manager, SSM Parameter Store and S3 readers
+package awsstore
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/jsonpath"
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("awssm", &SecretReader{})
+	noble.Register("ssm", &ParameterReader{})
+	noble.Register("s3", &ObjectReader{})
+}
+
+// SecretReader type implements noble.SecretStorage for AWS Secrets Manager
+//
+// Key format: <secret-id>[?version=<version-id>][&stage=<label>][#<field>]
+//
+//	awssm:prod/db - current value of the secret
+//	awssm:prod/db#password - field of the JSON value
+//	awssm:prod/db?stage=AWSPREVIOUS - value of the staging label
+//	awssm:arn:aws:secretsmanager:eu-west-1:123456789012:secret:prod/db-AbCdEf - secret by ARN
+//
+// Binary secrets are returned as is
+type SecretReader struct {
+}
+
+// Read secret value from AWS Secrets Manager
+func (r *SecretReader) Read(key string) (string, error) {
+	c, err := getClient()
+	if err != nil {
+		return "", err
+	}
+	id, query, field, err := parseKey(key, "version", "stage")
+	if err != nil {
+		return "", errors.Wrap(err, "use <secret-id>[?version=<id>][&stage=<label>][#<field>]")
+	}
+	in := map[string]string{"SecretId": id}
+	if v := query.Get("version"); v != "" {
+		in["VersionId"] = v
+	}
+	if v := query.Get("stage"); v != "" {
+		in["VersionStage"] = v
+	}
+	var out struct {
+		SecretString *string `json:"SecretString"`
+		SecretBinary []byte  `json:"SecretBinary"`
+	}
+	err = c.call(context.Background(), serviceSecretsManager, "secretsmanager.GetSecretValue", in, &out)
+	if err != nil {
+		return "", errors.Wrap(err, "awssm secret "+id)
+	}
+	val := string(out.SecretBinary)
+	if out.SecretString != nil {
+		val = *out.SecretString
+	}
+	return extract(val, field, "awssm secret "+id)
+}
+
+// Clone returns new empty instance of SecretReader
+func (r *SecretReader) Clone() noble.SecretStorage {
+	return &SecretReader{}
+}
+
+// ParameterReader type implements noble.SecretStorage for AWS SSM Parameter Store
+//
+// Key format: <name>[?decrypt=true][&version=<n>|&label=<label>][#<field>]
+//
+//	ssm:/prod/db/password?decrypt=true - value of the SecureString parameter
+//	ssm:/prod/db/password?decrypt=true&version=3 - value of the parameter version
+//	ssm:/prod/db/config?label=stable#user - field of the JSON value of the labeled version
+type ParameterReader struct {
+}
+
+// Read parameter value from AWS SSM Parameter Store
+func (r *ParameterReader) Read(key string) (string, error) {
+	c, err := getClient()
+	if err != nil {
+		return "", err
+	}
+	name, query, field, err := parseKey(key, "decrypt", "version", "label")
+	if err == nil && query.Get("version") != "" && query.Get("label") != "" {
+		err = errors.New("version and label can not be used together")
+	}
+	var decrypt bool
+	if err == nil && query.Get("decrypt") != "" {
+		decrypt, err = strconv.ParseBool(query.Get("decrypt"))
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "use <name>[?decrypt=true][&version=<n>|&label=<label>][#<field>]")
+	}
+	selector := name
+	if v := query.Get("version") + query.Get("label"); v != "" {
+		selector += ":" + v
+	}
+	var out struct {
+		Parameter struct {
+			Type  string `json:"Type"`
+			Value string `json:"Value"`
+		} `json:"Parameter"`
+	}
+	in := map[string]interface{}{"Name": selector, "WithDecryption": decrypt}
+	if err := c.call(context.Background(), serviceSSM, "AmazonSSM.GetParameter", in, &out); err != nil {
+		return "", errors.Wrap(err, "ssm parameter "+name)
+	}
+	if out.Parameter.Type == "SecureString" && !decrypt {
+		return "", errors.New("ssm parameter " + name + " is SecureString, use ?decrypt=true")
+	}
+	return extract(out.Parameter.Value, field, "ssm parameter "+name)
+}
+
+// Clone returns new empty instance of ParameterReader
+func (r *ParameterReader) Clone() noble.SecretStorage {
+	return &ParameterReader{}
+}
+
+// parseKey splits key into name, query with allowed parameters and JSON field selector
+func parseKey(s string, params ...string) (name string, query url.Values, field string, err error) {
+	if i := strings.Index(s, "#"); i >= 0 {
+		s, field = s[:i], s[i+1:]
+	}
+	query = url.Values{}
+	if i := strings.Index(s, "?"); i >= 0 {
+		if query, err = url.ParseQuery(s[i+1:]); err != nil {
+			return "", nil, "", err
+		}
+		s = s[:i]
+	}
+	for k := range query {
+		if !contains(params, k) {
+			return "", nil, "", errors.New("unknown parameter " + k)
+		}
+	}
+	if s == "" {
+		return "", nil, "", errors.New("empty name")
+	}
+	return s, query, field, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func extract(val, field, name string) (string, error) {
+	if field == "" {
+		return val, nil
+	}
+	val, err := jsonpath.Extract([]byte(val), field)
+	return val, errors.Wrap(err, name)
+}
@@ -0,0 +1,524 @@
+package awsstore
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/awsstore/awsstoretest"
+	"github.com/lancer-kit/noble/nobletest"
+)
+
+func initClient(t *testing.T, cfg *Config) {
+	nobletest.InitClient(t, func() error { return Init(cfg) }, clients.Reset)
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "awsstore")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+func TestSecretReader_Read(t *testing.T) {
+	srv := awsstoretest.Start(t)
+	srv.Region = "eu-west-1"
+	srv.AddCredentials("AKID", "secret")
+	v1 := srv.PutSecret("prod/db", `{"user":"admin","password":"p1"}`)
+	srv.PutSecret("prod/db", `{"user":"admin","password":"p2"}`)
+	srv.PutSecretBinary("prod/key", []byte{'k', 0, 1})
+	initClient(t, &Config{Region: "eu-west-1", Endpoint: srv.URL,
+		Credentials: StaticProvider{AccessKeyID: "AKID", SecretAccessKey: "secret"}})
+
+	r := SecretReader{}
+	v, err := r.Read("prod/db#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "p2", v)
+	v, err = r.Read("prod/db?stage=AWSPREVIOUS#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "p1", v)
+	v, err = r.Read("prod/db?version=" + v1 + "#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "p1", v)
+	v, err = r.Read("prod/db?version=" + v1 + "&stage=AWSCURRENT")
+	assert.Error(t, err)
+	assert.Empty(t, v)
+	v, err = r.Read("prod/key")
+	assert.NoError(t, err)
+	assert.Equal(t, "k\x00\x01", v)
+
+	_, err = r.Read("prod/db#none")
+	assert.Error(t, err)
+	_, err = r.Read("prod/none")
+	assert.Contains(t, err.Error(), "ResourceNotFoundException")
+	_, err = r.Read("prod/db?label=x")
+	assert.Error(t, err)
+
+	var c nobletest.Config
+	assert.NoError(t, yaml.Unmarshal([]byte(`secret: "awssm:prod/db#user"`), &c))
+	assert.NoError(t, c.Secret.InternalError())
+	assert.Equal(t, "admin", c.Secret.Get())
+
+	initClient(t, &Config{Region: "eu-west-1", Endpoint: srv.URL,
+		Credentials: StaticProvider{AccessKeyID: "AKID", SecretAccessKey: "wrong"}})
+	_, err = r.Read("prod/db")
+	assert.Contains(t, err.Error(), "UnrecognizedClientException")
+	initClient(t, &Config{Region: "us-east-1", Endpoint: srv.URL,
+		Credentials: StaticProvider{AccessKeyID: "AKID", SecretAccessKey: "secret"}})
+	_, err = r.Read("prod/db")
+	assert.Error(t, err)
+}
+
+func TestParameterReader_Read(t *testing.T) {
+	srv := awsstoretest.Start(t)
+	srv.PutParameter("/prod/db/password", "p1", true)
+	srv.PutParameter("/prod/db/password", "p2", true)
+	srv.PutParameter("/prod/db/config", `{"user":"admin"}`, false)
+	srv.LabelParameter("/prod/db/password", 1, "stable")
+	initClient(t, &Config{Region: "eu-west-1", Endpoint: srv.URL,
+		Credentials: StaticProvider{AccessKeyID: "AKID", SecretAccessKey: "secret"}})
+
+	r := ParameterReader{}
+	v, err := r.Read("/prod/db/password?decrypt=true")
+	assert.NoError(t, err)
+	assert.Equal(t, "p2", v)
+	v, err = r.Read("/prod/db/password?decrypt=true&version=1")
+	assert.NoError(t, err)
+	assert.Equal(t, "p1", v)
+	v, err = r.Read("/prod/db/password?decrypt=true&label=stable")
+	assert.NoError(t, err)
+	assert.Equal(t, "p1", v)
+	v, err = r.Read("/prod/db/config#user")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", v)
+
+	_, err = r.Read("/prod/db/password")
+	assert.Contains(t, err.Error(), "decrypt")
+	_, err = r.Read("/prod/db/password?decrypt=true&version=1&label=stable")
+	assert.Error(t, err)
+	_, err = r.Read("/prod/db/password?decrypt=yes")
+	assert.Error(t, err)
+	_, err = r.Read("/prod/db/password?decrypt=true&version=5")
+	assert.Contains(t, err.Error(), "ParameterVersionNotFound")
+	_, err = r.Read("/prod/none")
+	assert.Contains(t, err.Error(), "ParameterNotFound")
+}
+
+func TestObjectReader_Read(t *testing.T) {
+	srv := awsstoretest.Start(t)
+	srv.AddCredentials("minio", "minio-secret")
+	v1 := srv.PutObject("secrets", "prod/bundle.json", []byte(`{"db":{"password":"p1"}}`))
+	srv.PutObject("secrets", "prod/bundle.json", []byte(`{"db":{"password":"p2"}}`))
+	srv.PutObject("secrets", "dir/key with spaces+plus.txt", []byte("spaces\n"))
+	customerKey := []byte("0123456789abcdef0123456789abcdef")
+	srv.PutObjectSSEC("encrypted", "bundle", []byte("sealed"), customerKey)
+	initClient(t, &Config{Region: "us-east-1", Endpoint: srv.URL,
+		Credentials:     StaticProvider{AccessKeyID: "minio", SecretAccessKey: "minio-secret"},
+		SSECustomerKeys: map[string]noble.Secret{"encrypted": noble.Secret{}.New("raw:" + string(customerKey))}})
+
+	r := ObjectReader{}
+	for key, expected := range map[string]string{
+		"secrets/prod/bundle.json":                                `{"db":{"password":"p2"}}`,
+		"secrets/prod/bundle.json#db.password":                    "p2",
+		"secrets/prod/bundle.json?version=" + v1 + "#db.password": "p1",
+		"secrets/dir/key with spaces+plus.txt":                    "spaces\n",
+		"encrypted/bundle":                                        "sealed",
+	} {
+		v, err := r.Read(key)
+		assert.NoError(t, err, key)
+		assert.Equal(t, expected, v, key)
+		// second read is revalidated by ETag
+		v, err = r.Read(key)
+		assert.NoError(t, err, key)
+		assert.Equal(t, expected, v, key)
+	}
+	srv.PutObject("secrets", "prod/bundle.json", []byte(`{"db":{"password":"p3"}}`))
+	v, err := r.Read("secrets/prod/bundle.json#db.password")
+	assert.NoError(t, err)
+	assert.Equal(t, "p3", v)
+
+	for _, key := range []string{"secrets/none", "secrets", "secrets/prod/bundle.json#none", "none/key"} {
+		_, err := r.Read(key)
+		assert.Error(t, err, key)
+	}
+	_, err = r.Read("secrets/none")
+	assert.Contains(t, err.Error(), "NoSuchKey")
+
+	initClient(t, &Config{Region: "us-east-1", Endpoint: srv.URL,
+		Credentials: StaticProvider{AccessKeyID: "minio", SecretAccessKey: "minio-secret"}})
+	_, err = r.Read("encrypted/bundle")
+	assert.Error(t, err)
+	initClient(t, &Config{Region: "us-east-1", Endpoint: srv.URL,
+		Credentials:     StaticProvider{AccessKeyID: "minio", SecretAccessKey: "minio-secret"},
+		SSECustomerKeys: map[string]noble.Secret{"encrypted": noble.Secret{}.New("raw:short")}})
+	_, err = r.Read("encrypted/bundle")
+	assert.Contains(t, err.Error(), "sse-c")
+}
+
+func TestObjectURL(t *testing.T) {
+	c, err := newClient(Config{Region: "eu-west-1", Credentials: StaticProvider{}})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://app.s3.eu-west-1.amazonaws.com/a/b%20c", c.objectURL("app", "a/b c").String())
+	assert.Equal(t, "https://s3.eu-west-1.amazonaws.com/app.example/a", c.objectURL("app.example", "a").String())
+	c.cfg.Endpoint = "http://minio:9000/"
+	assert.Equal(t, "http://minio:9000/app/a", c.objectURL("app", "a").String())
+	c.cfg.S3Endpoint = "https://storage.example/s3/"
+	assert.Equal(t, "https://storage.example/s3/app/a", c.objectURL("app", "a").String())
+	assert.Equal(t, "http://minio:9000", c.cfg.serviceEndpoint(serviceSecretsManager))
+
+	_, err = newClient(Config{Credentials: StaticProvider{}})
+	assert.Error(t, err)
+	c, err = newClient(Config{S3Endpoint: "http://minio:9000", Credentials: StaticProvider{}})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultS3Region, c.cfg.Region)
+	assert.Equal(t, "http://minio:9000/app/a", c.objectURL("app", "a").String())
+	assert.Equal(t, "https://secretsmanager.us-east-1.amazonaws.com", c.cfg.serviceEndpoint(serviceSecretsManager))
+}
+
+func TestObjectReader_S3Endpoint(t *testing.T) {
+	minio := awsstoretest.Start(t)
+	minio.AddCredentials("minio", "minio-secret")
+	minio.PutObject("secrets", "bundle.json", []byte(`{"token":"t1"}`))
+	aws := awsstoretest.Start(t)
+	aws.AddCredentials("minio", "minio-secret")
+	aws.PutSecret("prod/db", "secret")
+	initClient(t, &Config{Endpoint: aws.URL, S3Endpoint: minio.URL,
+		Credentials: StaticProvider{AccessKeyID: "minio", SecretAccessKey: "minio-secret"}})
+
+	v, err := (&ObjectReader{}).Read("secrets/bundle.json#token")
+	assert.NoError(t, err)
+	assert.Equal(t, "t1", v)
+	v, err = (&SecretReader{}).Read("prod/db")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+	assert.Equal(t, 1, minio.Requests())
+	assert.Equal(t, 1, aws.Requests())
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	dir := tempDir(t)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "credentials"), []byte(`
+[default]
+aws_access_key_id = AKID-default
+aws_secret_access_key = secret-default
+
+[dev]
+aws_access_key_id=AKID-dev
+aws_secret_access_key=secret-dev
+aws_session_token=token-dev
+`), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "config"), []byte(`
+# comment
+[default]
+region = us-east-1
+
+[profile dev]
+region = eu-central-1
+
+[profile cfg]
+aws_access_key_id = AKID-cfg
+aws_secret_access_key = secret-cfg
+`), 0600))
+	nobletest.Setenv(t, map[string]string{
+		"AWS_SHARED_CREDENTIALS_FILE": filepath.Join(dir, "credentials"),
+		"AWS_CONFIG_FILE":             filepath.Join(dir, "config"),
+		"AWS_PROFILE":                 "dev",
+		"AWS_REGION":                  "",
+		"AWS_DEFAULT_REGION":          "",
+		"AWS_ACCESS_KEY_ID":           "",
+		"AWS_ENDPOINT_URL":            "",
+	})
+
+	cfg := ConfigFromEnv()
+	assert.Equal(t, Config{Region: "eu-central-1", Profile: "dev", Timeout: DefaultTimeout}, cfg)
+	assert.Equal(t, "https://secretsmanager.eu-central-1.amazonaws.com", cfg.serviceEndpoint(serviceSecretsManager))
+	assert.Equal(t, "https://ssm.cn-north-1.amazonaws.com.cn", Config{Region: "cn-north-1"}.serviceEndpoint(serviceSSM))
+	nobletest.Setenv(t, map[string]string{"AWS_ENDPOINT_URL_SSM": "http://localhost:4566/"})
+	assert.Equal(t, "http://localhost:4566", cfg.serviceEndpoint(serviceSSM))
+
+	ctx := context.Background()
+	c, err := SharedFileProvider{Profile: "dev"}.Retrieve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{AccessKeyID: "AKID-dev", SecretAccessKey: "secret-dev", SessionToken: "token-dev"}, c)
+	c, err = SharedFileProvider{}.Retrieve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "AKID-default", c.AccessKeyID)
+	c, err = SharedFileProvider{Profile: "cfg"}.Retrieve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "AKID-cfg", c.AccessKeyID)
+	_, err = SharedFileProvider{Profile: "none"}.Retrieve(ctx)
+	assert.Equal(t, ErrNoCredentials, err)
+
+	// environment takes precedence
+	nobletest.Setenv(t, map[string]string{"AWS_ACCESS_KEY_ID": "AKID-env", "AWS_SECRET_ACCESS_KEY": "secret-env"})
+	c, err = DefaultChain(cfg).Retrieve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "AKID-env", c.AccessKeyID)
+}
+
+func TestWebIdentityProvider(t *testing.T) {
+	srv := awsstoretest.Start(t)
+	srv.AddWebIdentity("oidc-token", "arn:aws:iam::123456789012:role/app")
+	srv.PutSecret("prod/db", "secret")
+
+	dir := tempDir(t)
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("oidc-token\n"), 0600))
+	nobletest.Setenv(t, map[string]string{
+		"AWS_ROLE_ARN":                "arn:aws:iam::123456789012:role/app",
+		"AWS_WEB_IDENTITY_TOKEN_FILE": tokenFile,
+		"AWS_ACCESS_KEY_ID":           "",
+		"AWS_SHARED_CREDENTIALS_FILE": filepath.Join(dir, "none"),
+		"AWS_CONFIG_FILE":             filepath.Join(dir, "none"),
+	})
+	initClient(t, &Config{Region: "eu-west-1", Endpoint: srv.URL})
+
+	// issued credentials are cached and accepted by the server
+	for i := 0; i < 3; i++ {
+		v, err := (&SecretReader{}).Read("prod/db")
+		assert.NoError(t, err)
+		assert.Equal(t, "secret", v)
+	}
+	assert.Equal(t, 4, srv.Requests())
+
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("other"), 0600))
+	_, err := WebIdentityProvider{Config: Config{Endpoint: srv.URL}}.Retrieve(context.Background())
+	assert.Error(t, err)
+}
+
+func TestSharedFileProvider_AssumeRole(t *testing.T) {
+	srv := awsstoretest.Start(t)
+	srv.AddCredentials("AKID-base", "secret-base")
+	srv.AddRole("arn:aws:iam::123456789012:role/app").AddRole("arn:aws:iam::123456789012:role/admin")
+	srv.PutSecret("prod/db", "secret")
+
+	dir := tempDir(t)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "credentials"), []byte(`
+[base]
+aws_access_key_id = AKID-base
+aws_secret_access_key = secret-base
+`), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "config"), []byte(`
+[profile app]
+role_arn = arn:aws:iam::123456789012:role/app
+source_profile = base
+role_session_name = app-session
+
+[profile admin]
+role_arn = arn:aws:iam::123456789012:role/admin
+source_profile = app
+
+[profile self]
+role_arn = arn:aws:iam::123456789012:role/app
+source_profile = self
+aws_access_key_id = AKID-base
+aws_secret_access_key = secret-base
+
+[profile env]
+role_arn = arn:aws:iam::123456789012:role/app
+credential_source = Environment
+
+[profile denied]
+role_arn = arn:aws:iam::123456789012:role/other
+source_profile = base
+
+[profile loop]
+role_arn = arn:aws:iam::123456789012:role/app
+source_profile = loop2
+
+[profile loop2]
+role_arn = arn:aws:iam::123456789012:role/app
+source_profile = loop
+
+[profile nosource]
+role_arn = arn:aws:iam::123456789012:role/app
+`), 0600))
+	nobletest.Setenv(t, map[string]string{
+		"AWS_SHARED_CREDENTIALS_FILE": filepath.Join(dir, "credentials"),
+		"AWS_CONFIG_FILE":             filepath.Join(dir, "config"),
+		"AWS_ACCESS_KEY_ID":           "",
+		"AWS_ROLE_ARN":                "",
+	})
+	cfg := Config{Region: "eu-west-1", Endpoint: srv.URL}
+	ctx := context.Background()
+
+	for _, profile := range []string{"app", "admin", "self"} {
+		c, err := SharedFileProvider{Profile: profile, Config: cfg}.Retrieve(ctx)
+		assert.NoError(t, err, profile)
+		assert.Regexp(t, "^ASIA", c.AccessKeyID, profile)
+		assert.NotEmpty(t, c.SessionToken, profile)
+		assert.True(t, c.Expires.After(time.Now()), profile)
+	}
+
+	// chained role credentials are accepted by the server
+	cfg.Profile = "admin"
+	initClient(t, &cfg)
+	v, err := (&SecretReader{}).Read("prod/db")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+
+	_, err = SharedFileProvider{Profile: "env", Config: cfg}.Retrieve(ctx)
+	assert.Equal(t, ErrNoCredentials, errors.Cause(err))
+	nobletest.Setenv(t, map[string]string{"AWS_ACCESS_KEY_ID": "AKID-base", "AWS_SECRET_ACCESS_KEY": "secret-base"})
+	c, err := SharedFileProvider{Profile: "env", Config: cfg}.Retrieve(ctx)
+	assert.NoError(t, err)
+	assert.Regexp(t, "^ASIA", c.AccessKeyID)
+
+	for _, profile := range []string{"denied", "loop", "nosource"} {
+		_, err = SharedFileProvider{Profile: profile, Config: cfg}.Retrieve(ctx)
+		assert.Error(t, err, profile)
+	}
+}
+
+func TestSharedFileProvider_SSO(t *testing.T) {
+	srv := awsstoretest.Start(t)
+	srv.AddSSOToken("session-token", "111122223333", "ReadOnly")
+	srv.AddSSOToken("legacy-token", "111122223333", "Admin")
+
+	dir := tempDir(t)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "config"), []byte(`
+[profile sso]
+sso_session = corp
+sso_account_id = 111122223333
+sso_role_name = ReadOnly
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = eu-west-1
+sso_registration_scopes = sso:account:access
+
+[profile legacy]
+sso_start_url = https://legacy.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 111122223333
+sso_role_name = Admin
+
+[profile expired]
+sso_start_url = https://expired.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 111122223333
+sso_role_name = Admin
+
+[profile nologin]
+sso_start_url = https://nologin.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 111122223333
+sso_role_name = Admin
+`), 0600))
+	// cache file name is SHA-1 of the session name or of the start URL of legacy profiles
+	cache := filepath.Join(dir, ".aws", "sso", "cache")
+	assert.NoError(t, os.MkdirAll(cache, 0700))
+	for name, token := range map[string]string{
+		"ee0bfd2552fbd840c02cc48b6e823320543c450f": `{"startUrl": "https://corp.awsapps.com/start", "region": "eu-west-1",
+			"accessToken": "session-token", "expiresAt": "2099-01-01T00:00:00Z", "clientId": "id", "clientSecret": "s"}`,
+		"79e435d7a515078e81c9dffc35f38d5687ebd3a7": `{"startUrl": "https://legacy.awsapps.com/start", "region": "us-east-1",
+			"accessToken": "legacy-token", "expiresAt": "2099-01-01T00:00:00Z"}`,
+		"95e39c87473b75ba8788a1d65e131ceb669bae8a": `{"startUrl": "https://expired.awsapps.com/start", "region": "us-east-1",
+			"accessToken": "legacy-token", "expiresAt": "2020-01-01T00:00:00Z"}`,
+	} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(cache, name+".json"), []byte(token), 0600))
+	}
+	nobletest.Setenv(t, map[string]string{
+		"HOME":                        dir,
+		"AWS_SHARED_CREDENTIALS_FILE": filepath.Join(dir, "none"),
+		"AWS_CONFIG_FILE":             filepath.Join(dir, "config"),
+	})
+	cfg := Config{Endpoint: srv.URL}
+	ctx := context.Background()
+
+	for _, profile := range []string{"sso", "legacy"} {
+		c, err := SharedFileProvider{Profile: profile, Config: cfg}.Retrieve(ctx)
+		assert.NoError(t, err, profile)
+		assert.Regexp(t, "^ASIA", c.AccessKeyID, profile)
+		assert.NotEmpty(t, c.SessionToken, profile)
+		assert.True(t, c.Expires.After(time.Now()), profile)
+	}
+	_, err := SharedFileProvider{Profile: "expired", Config: cfg}.Retrieve(ctx)
+	assert.EqualError(t, err, `sso token expired, run "aws sso login"`)
+	_, err = SharedFileProvider{Profile: "nologin", Config: cfg}.Retrieve(ctx)
+	assert.Error(t, err)
+	_, err = SSOProvider{StartURL: "https://legacy.awsapps.com/start", Region: "us-east-1", AccountID: "111122223333",
+		RoleName: "ReadOnly", Config: cfg}.Retrieve(ctx)
+	assert.Error(t, err)
+}
+
+func TestContainerAndIMDSProvider(t *testing.T) {
+	// response formats of the ECS task metadata and of the EC2 instance metadata
+	ecs := `{"AccessKeyId":"ASIA1","Expiration":"2030-01-01T00:00:00Z","RoleArn":"arn:aws:iam::123456789012:role/task",
+		"SecretAccessKey":"secret","Token":"token"}`
+	imds := `{"Code":"Success","LastUpdated":"2029-12-31T18:00:00Z","Type":"AWS-HMAC","AccessKeyId":"ASIA2",
+		"SecretAccessKey":"secret","Token":"token","Expiration":"2030-01-01T00:00:00Z"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/credentials/task" && r.Header.Get("Authorization") == "auth":
+			_, _ = w.Write([]byte(ecs))
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			if r.Header.Get("X-Aws-Ec2-Metadata-Token-Ttl-Seconds") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte("imds-token"))
+		case r.Header.Get("X-Aws-Ec2-Metadata-Token") != "imds-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			_, _ = w.Write([]byte("app-role"))
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/app-role":
+			_, _ = w.Write([]byte(imds))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	nobletest.Setenv(t, map[string]string{
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI":     "",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": "",
+	})
+	_, err := ContainerProvider{}.Retrieve(ctx)
+	assert.Equal(t, ErrNoCredentials, err)
+	nobletest.Setenv(t, map[string]string{
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI": srv.URL + "/v2/credentials/task",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN":  "auth",
+	})
+	c, err := ContainerProvider{}.Retrieve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "ASIA1", c.AccessKeyID)
+	assert.Equal(t, "token", c.SessionToken)
+	assert.Equal(t, 2030, c.Expires.Year())
+
+	// relative URI of ECS takes precedence, token file of EKS Pod Identity over the token
+	tokenFile := filepath.Join(tempDir(t), "token")
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("auth\n"), 0600))
+	nobletest.Setenv(t, map[string]string{
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": "/v2/credentials/task",
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI":     "http://localhost:1/none",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN":      "other",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE": tokenFile,
+	})
+	c, err = ContainerProvider{Host: srv.URL}.Retrieve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "ASIA1", c.AccessKeyID)
+
+	c, err = IMDSProvider{Endpoint: srv.URL}.Retrieve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{AccessKeyID: "ASIA2", SecretAccessKey: "secret", SessionToken: "token",
+		Expires: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}, c)
+	_, err = decodeRoleCredentials([]byte(`{"Code":"AssumeRoleUnauthorizedAccess"}`))
+	assert.Error(t, err)
+	nobletest.Setenv(t, map[string]string{"AWS_EC2_METADATA_DISABLED": "true"})
+	_, err = IMDSProvider{Endpoint: srv.URL}.Retrieve(ctx)
+	assert.Equal(t, ErrNoCredentials, err)
+}
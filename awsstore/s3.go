@@ -0,0 +1,158 @@
+package awsstore
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/sigv4"
+	"github.com/pkg/errors"
+)
+
+// ObjectReader type implements noble.SecretStorage for S3 compatible object storages
+//
+// Key format: <bucket>/<key>[?version=<version-id>][#<field>]
+//
+//	s3:app-secrets/prod/bundle.json - whole object
+//	s3:app-secrets/prod/bundle.json#db.password - field of the JSON object
+//	s3:app-secrets/prod/token?version=3HL4kqtJlcpXroDTDmJ - object version
+//
+// Objects are cached in memory and revalidated by ETag on every read.
+// Buckets of Config.SSECustomerKeys are read with the SSE-C key
+type ObjectReader struct {
+}
+
+// object cache entry
+type object struct {
+	etag string
+	data []byte
+}
+
+// Read object from S3
+func (r *ObjectReader) Read(key string) (string, error) {
+	c, err := getClient()
+	if err != nil {
+		return "", err
+	}
+	path, query, field, err := parseKey(key, "version")
+	if err != nil {
+		return "", errors.Wrap(err, "use <bucket>/<key>[?version=<id>][#<field>]")
+	}
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", errors.New("incorrect key format. use <bucket>/<key>[?version=<id>][#<field>]")
+	}
+	data, err := c.getObject(context.Background(), parts[0], parts[1], query.Get("version"))
+	if err != nil {
+		return "", errors.Wrap(err, "s3 object "+path)
+	}
+	return extract(string(data), field, "s3 object "+path)
+}
+
+// Clone returns new empty instance of ObjectReader
+func (r *ObjectReader) Clone() noble.SecretStorage {
+	return &ObjectReader{}
+}
+
+// objectURL returns URL of the object, path-style for custom S3 endpoints and buckets with dots
+func (c *awsClient) objectURL(bucket, key string) *url.URL {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = sigv4.Escape(s)
+	}
+	rawPath := "/" + strings.Join(segments, "/")
+	u := &url.URL{Scheme: "https", Host: bucket + ".s3." + c.cfg.Region + c.cfg.domain(), Path: "/" + key, RawPath: rawPath}
+	if e := c.cfg.customEndpoint(serviceS3); e != "" || c.cfg.S3PathStyle || strings.Contains(bucket, ".") {
+		if e == "" {
+			e = "https://s3." + c.cfg.Region + c.cfg.domain()
+		}
+		base, _ := url.Parse(e)
+		u.Scheme, u.Host = base.Scheme, base.Host
+		prefix := strings.TrimRight(base.Path, "/") + "/" + bucket
+		u.Path, u.RawPath = prefix+u.Path, prefix+rawPath
+	}
+	return u
+}
+
+// getObject returns object from the cache if its ETag is not changed or from the response
+func (c *awsClient) getObject(ctx context.Context, bucket, key, version string) ([]byte, error) {
+	u := c.objectURL(bucket, key)
+	if version != "" {
+		u.RawQuery = "versionId=" + sigv4.Escape(version)
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := u.String()
+	if sk, ok := c.cfg.SSECustomerKeys[bucket]; ok {
+		customerKey, err := sseCustomerKey(sk.Get())
+		if err != nil {
+			return nil, err
+		}
+		sum := md5.Sum(customerKey) //nolint:gosec
+		req.Header.Set("X-Amz-Server-Side-Encryption-Customer-Algorithm", "AES256")
+		req.Header.Set("X-Amz-Server-Side-Encryption-Customer-Key", base64.StdEncoding.EncodeToString(customerKey))
+		req.Header.Set("X-Amz-Server-Side-Encryption-Customer-Key-Md5", base64.StdEncoding.EncodeToString(sum[:]))
+		cacheKey += "#" + base64.StdEncoding.EncodeToString(sum[:])
+	}
+	c.objMu.Lock()
+	cached := c.objects[cacheKey]
+	c.objMu.Unlock()
+	if cached != nil {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	if err := c.sign(ctx, req, nil, serviceS3); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return cached.data, nil
+	case resp.StatusCode != http.StatusOK:
+		var e struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		_ = xml.Unmarshal(body, &e)
+		if e.Code == "" {
+			e.Code = http.StatusText(resp.StatusCode)
+		}
+		return nil, &apiError{Status: resp.StatusCode, Code: e.Code, Message: e.Message}
+	}
+	c.objMu.Lock()
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		c.objects[cacheKey] = &object{etag: etag, data: body}
+	} else {
+		delete(c.objects, cacheKey)
+	}
+	c.objMu.Unlock()
+	return body, nil
+}
+
+// sseCustomerKey decodes 256-bit key, raw or base64 encoded
+func sseCustomerKey(s string) ([]byte, error) {
+	if len(s) == 32 {
+		return []byte(s), nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("sse-c key must be 32 bytes, raw or base64 encoded")
+	}
+	return key, nil
+}
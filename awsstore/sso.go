@@ -0,0 +1,107 @@
+package awsstore
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// serviceSSO endpoint prefix of the IAM Identity Center portal API
+const serviceSSO = "portal.sso"
+
+// SSOProvider reads role credentials of the IAM Identity Center (SSO) account with the access token
+// cached by "aws sso login" in ~/.aws/sso/cache
+type SSOProvider struct {
+	// StartURL of the access portal, cache key of the legacy profiles without Session
+	StartURL string
+	// Region of the IAM Identity Center
+	Region    string
+	AccountID string
+	RoleName  string
+	// Session name of the [sso-session] section, cache key if set
+	Session string
+	// Config of the portal client: endpoint and timeout. Region is ignored
+	Config Config
+}
+
+// Retrieve implements Provider
+func (p SSOProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	if p.AccountID == "" || p.RoleName == "" || p.Region == "" {
+		return Credentials{}, ErrNoCredentials
+	}
+	token, err := p.cachedToken()
+	if err != nil {
+		return Credentials{}, err
+	}
+	cfg := p.Config
+	cfg.Region = p.Region
+	u := cfg.serviceEndpoint(serviceSSO) + "/federation/credentials?" + url.Values{
+		"account_id": {p.AccountID},
+		"role_name":  {p.RoleName},
+	}.Encode()
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("X-Amz-Sso_bearer_token", token)
+	body, err := doHTTP(ctx, timeoutClient(cfg.Timeout), req)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "sso GetRoleCredentials")
+	}
+	var out struct {
+		RoleCredentials struct {
+			AccessKeyID     string `json:"accessKeyId"`
+			SecretAccessKey string `json:"secretAccessKey"`
+			SessionToken    string `json:"sessionToken"`
+			// Expiration in milliseconds since epoch
+			Expiration int64 `json:"expiration"`
+		} `json:"roleCredentials"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return Credentials{}, errors.Wrap(err, "invalid sso response")
+	}
+	c := out.RoleCredentials
+	return Credentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.SessionToken,
+		Expires: time.Unix(0, c.Expiration*int64(time.Millisecond))}, nil
+}
+
+// cachedToken returns access token of the cache file named by SHA-1 of the session name or start URL
+func (p SSOProvider) cachedToken() (string, error) {
+	key := p.Session
+	if key == "" {
+		key = p.StartURL
+	}
+	if key == "" {
+		return "", ErrNoCredentials
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(key)) //nolint:gosec
+	name := filepath.Join(home, ".aws", "sso", "cache", hex.EncodeToString(sum[:])+".json")
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", errors.Wrap(err, "sso token cache, run \"aws sso login\"")
+	}
+	var cache struct {
+		AccessToken string    `json:"accessToken"`
+		ExpiresAt   time.Time `json:"expiresAt"`
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return "", errors.Wrap(err, "invalid sso token cache "+name)
+	}
+	if cache.AccessToken == "" || time.Now().After(cache.ExpiresAt) {
+		return "", errors.New("sso token expired, run \"aws sso login\"")
+	}
+	return cache.AccessToken, nil
+}
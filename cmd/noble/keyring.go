@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lancer-kit/noble/keyring"
+	"github.com/urfave/cli"
+)
+
+func keyringCommand() cli.Command {
+	return cli.Command{
+		Name:  "keyring",
+		Usage: "manage keys of the Linux kernel keyring used by keyring: storage",
+		Subcommands: []cli.Command{
+			{
+				Name:      "add",
+				Usage:     "add key, value is read from stdin if omitted",
+				ArgsUsage: "[<keyring>/]<description> [<value>]",
+				Flags: []cli.Flag{
+					cli.DurationFlag{
+						Name:  "timeout,t",
+						Usage: "expire key after timeout, e.g. 24h",
+					},
+				},
+				Action: keyringAdd,
+			},
+			{
+				Name:      "rm",
+				Usage:     "remove key",
+				ArgsUsage: "[<keyring>/]<description>",
+				Action:    keyringRemove,
+			},
+		},
+	}
+}
+
+func keyringAdd(c *cli.Context) error {
+	if c.NArg() < 1 || c.NArg() > 2 {
+		return cli.NewExitError("usage: noble keyring add [<keyring>/]<description> [<value>]", 2)
+	}
+	ring, desc, err := parseKey(c.Args().Get(0))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	value := c.Args().Get(1)
+	if c.NArg() == 1 {
+		// keep the value out of the shell history
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+	if value == "" {
+		return cli.NewExitError("empty value", 2)
+	}
+	if err := keyring.Add(ring, desc, value, c.Duration("timeout")); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
+
+func keyringRemove(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return cli.NewExitError("usage: noble keyring rm [<keyring>/]<description>", 2)
+	}
+	ring, desc, err := parseKey(c.Args().Get(0))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	if err := keyring.Remove(ring, desc); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
+
+// parseKey of the command argument. Process and thread keyrings are rejected:
+// they belong to the noble process and are destroyed when it exits
+func parseKey(key string) (ring, desc string, err error) {
+	ring, desc, err = keyring.ParseKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	if ring == keyring.Process || ring == keyring.Thread {
+		return "", "", fmt.Errorf("keyring %s is destroyed when noble exits, use %s or %s", ring, keyring.User, keyring.Session)
+	}
+	return ring, desc, nil
+}
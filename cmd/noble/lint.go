@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lancer-kit/noble/lint"
+	"github.com/urfave/cli"
+)
+
+func lintCommand() cli.Command {
+	return cli.Command{
+		Name:      "lint",
+		Usage:     "scan YAML/JSON config files for plain-text credentials",
+		ArgsUsage: "<file> [<file>...]",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format,f",
+				Usage: "report format: text or github (GitHub Actions annotations)",
+				Value: lint.FormatText,
+			},
+			cli.StringSliceFlag{
+				Name:  "key,k",
+				Usage: "additional credential-like key name fragment",
+			},
+			cli.StringSliceFlag{
+				Name:  "storage,s",
+				Usage: "additional noble storage prefix considered safe",
+			},
+			cli.Float64Flag{
+				Name:  "entropy,e",
+				Usage: "minimal entropy (bits/char) of the reported value, 0 to disable",
+				Value: lint.DefaultRules.MinEntropy,
+			},
+			cli.IntFlag{
+				Name:  "length,l",
+				Usage: "minimal length of the value for entropy check",
+				Value: lint.DefaultRules.MinEntropyLength,
+			},
+		},
+		Action: lintFiles,
+	}
+}
+
+func lintFiles(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return cli.NewExitError("no files to scan", 2)
+	}
+	format := c.String("format")
+	if format != lint.FormatText && format != lint.FormatGitHub {
+		return cli.NewExitError("unknown format: "+format, 2)
+	}
+	rules := lint.DefaultRules
+	rules.KeyNames = append(append([]string{}, rules.KeyNames...), c.StringSlice("key")...)
+	rules.Storages = append(append([]string{}, rules.Storages...), c.StringSlice("storage")...)
+	rules.MinEntropy = c.Float64("entropy")
+	rules.MinEntropyLength = c.Int("length")
+
+	var total int
+	for _, name := range c.Args() {
+		findings, err := rules.ScanFile(name)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+		if err := lint.Report(os.Stdout, format, findings); err != nil {
+			return err
+		}
+		total += len(findings)
+	}
+	if total > 0 {
+		return cli.NewExitError(fmt.Sprintf("%d plain-text secret(s) found", total), 1)
+	}
+	return nil
+}
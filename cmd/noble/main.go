@@ -0,0 +1,26 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/urfave/cli"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "noble"
+	app.Usage = "Command line tool for noble secrets in config files"
+	app.Version = "0.1.0"
+	app.Commands = getCommands()
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func getCommands() []cli.Command {
+	return []cli.Command{
+		lintCommand(),
+		keyringCommand(),
+	}
+}
@@ -0,0 +1,123 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lancer-kit/noble/internal/tlsconf"
+)
+
+type consulClient struct {
+	cfg  Config
+	http *http.Client
+	// watch client without timeout for blocking queries
+	watch *http.Client
+
+	wmu      sync.Mutex
+	watchers map[string]*watcher
+}
+
+// apiError non-200 response of the Consul HTTP API
+type apiError struct {
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	if e.Status == http.StatusNotFound {
+		return "consul key not found"
+	}
+	return fmt.Sprintf("consul api error (status %d): %s", e.Status, e.Body)
+}
+
+func hasScheme(addr string) bool {
+	return strings.Contains(addr, "://")
+}
+
+func readTrimmed(name string) (string, error) {
+	b, err := ioutil.ReadFile(name)
+	return strings.TrimSpace(string(b)), err
+}
+
+func newClient(cfg Config) (*consulClient, error) {
+	if cfg.Address == "" {
+		cfg.Address = DefaultAddress
+	}
+	if !hasScheme(cfg.Address) {
+		cfg.Address = "http://" + cfg.Address
+	}
+	cfg.Address = strings.TrimRight(cfg.Address, "/")
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.WaitTime == 0 {
+		cfg.WaitTime = DefaultWaitTime
+	}
+	tlsCfg, err := tlsconf.Files{
+		CAFile:             cfg.CAFile,
+		CertFile:           cfg.CertFile,
+		KeyFile:            cfg.KeyFile,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}.Load()
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{TLSClientConfig: tlsCfg, Proxy: http.ProxyFromEnvironment}
+	return &consulClient{
+		cfg:      cfg,
+		http:     &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		watch:    &http.Client{Transport: transport},
+		watchers: make(map[string]*watcher),
+	}, nil
+}
+
+// Close stops watchers and closes idle connections of the client
+func (c *consulClient) Close() {
+	c.stopWatchers()
+	c.http.CloseIdleConnections()
+}
+
+// get KV key and returns response body and X-Consul-Index
+func (c *consulClient) get(ctx context.Context, hc *http.Client, key string, query url.Values) ([]byte, uint64, error) {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	if q.Get("dc") == "" && c.cfg.Datacenter != "" {
+		q.Set("dc", c.cfg.Datacenter)
+	}
+	if q.Get("ns") == "" && c.cfg.Namespace != "" {
+		q.Set("ns", c.cfg.Namespace)
+	}
+	u := c.cfg.Address + "/v1/kv/" + strings.TrimLeft(key, "/")
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.cfg.Token != "" {
+		req.Header.Set("X-Consul-Token", c.cfg.Token)
+	}
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	index, _ := strconv.ParseUint(res.Header.Get("X-Consul-Index"), 10, 64)
+	if res.StatusCode != http.StatusOK {
+		return nil, index, &apiError{Status: res.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	return data, index, nil
+}
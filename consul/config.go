@@ -0,0 +1,105 @@
+package consul
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/storeclient"
+)
+
+// Config of the Consul client
+type Config struct {
+	// Address of the Consul agent, e.g. "127.0.0.1:8500" or "https://consul.service:8501"
+	Address string
+	// Token ACL token sent with every request
+	Token string
+	// Datacenter to read from. Agent's datacenter if empty
+	Datacenter string
+	// Namespace to read from (Consul Enterprise). Token's namespace if empty
+	Namespace string
+	// CertFile and KeyFile client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// CAFile trusted CA bundle for server certificates
+	CAFile             string
+	InsecureSkipVerify bool
+	// Timeout of a single request. Blocking queries use WaitTime instead
+	Timeout time.Duration
+	// WaitTime of the blocking queries used by watchers
+	WaitTime time.Duration
+	// Watch every key read by KeyReader: values are served from the local cache
+	// which is updated by blocking queries. See Watch and Subscribe
+	Watch bool
+}
+
+// Defaults of the config
+const (
+	DefaultAddress  = "127.0.0.1:8500"
+	DefaultTimeout  = 5 * time.Second
+	DefaultWaitTime = 5 * time.Minute
+)
+
+//nolint:gochecknoglobals
+var clients storeclient.Holder
+
+// Init Consul client. Config from CONSUL_* environment variables used if cfg is nil
+// or Init was not called before the first read
+func Init(cfg *Config) error {
+	if cfg == nil {
+		c := ConfigFromEnv()
+		cfg = &c
+	}
+	c, err := newClient(*cfg)
+	if err != nil {
+		return err
+	}
+	clients.Set(c)
+	return nil
+}
+
+func getClient() (*consulClient, error) {
+	c, err := clients.Get(func() error { return Init(nil) })
+	if err != nil {
+		return nil, err
+	}
+	return c.(*consulClient), nil
+}
+
+// ConfigFromEnv returns config from the environment variables used by the consul CLI:
+//
+//	CONSUL_HTTP_ADDR - agent address (DefaultAddress by default)
+//	CONSUL_HTTP_TOKEN - ACL token, or CONSUL_HTTP_TOKEN_FILE - file with the token
+//	CONSUL_HTTP_SSL - "true" to use https scheme for address without scheme
+//	CONSUL_HTTP_SSL_VERIFY - "false" to skip server certificate verification
+//	CONSUL_CACERT, CONSUL_CLIENT_CERT, CONSUL_CLIENT_KEY - TLS files
+//	CONSUL_NAMESPACE - namespace
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Address:   DefaultAddress,
+		Token:     os.Getenv("CONSUL_HTTP_TOKEN"),
+		Namespace: os.Getenv("CONSUL_NAMESPACE"),
+		CAFile:    os.Getenv("CONSUL_CACERT"),
+		CertFile:  os.Getenv("CONSUL_CLIENT_CERT"),
+		KeyFile:   os.Getenv("CONSUL_CLIENT_KEY"),
+		Timeout:   DefaultTimeout,
+		WaitTime:  DefaultWaitTime,
+	}
+	if a := os.Getenv("CONSUL_HTTP_ADDR"); a != "" {
+		cfg.Address = a
+	}
+	if ssl, _ := strconv.ParseBool(os.Getenv("CONSUL_HTTP_SSL")); ssl && !hasScheme(cfg.Address) {
+		cfg.Address = "https://" + cfg.Address
+	}
+	if v, err := strconv.ParseBool(os.Getenv("CONSUL_HTTP_SSL_VERIFY")); err == nil {
+		cfg.InsecureSkipVerify = !v
+	}
+	if cfg.Token == "" {
+		if f := os.Getenv("CONSUL_HTTP_TOKEN_FILE"); f != "" {
+			if b, err := readTrimmed(f); err == nil {
+				cfg.Token = b
+			}
+		}
+	}
+	return cfg
+}
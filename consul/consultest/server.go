@@ -0,0 +1,317 @@
+// Package consultest provides in-process fake of the Consul KV HTTP API for tests.
+//
+// Implemented subset: GET (with raw, recurse and blocking queries), PUT and DELETE of /v1/kv,
+// ACL tokens, datacenters and namespaces.
+package consultest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// DefaultDatacenter of the agent
+const DefaultDatacenter = "dc1"
+
+// DefaultNamespace used when request has no namespace
+const DefaultNamespace = "default"
+
+type entry struct {
+	value       []byte
+	createIndex uint64
+	modifyIndex uint64
+}
+
+// KVPair of the API response
+type KVPair struct {
+	Key         string `json:"Key"`
+	Value       []byte `json:"Value"`
+	Flags       uint64 `json:"Flags"`
+	CreateIndex uint64 `json:"CreateIndex"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+	LockIndex   uint64 `json:"LockIndex"`
+}
+
+// Server fake Consul agent
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	index    uint64
+	dcs      map[string]map[string]*entry
+	tokens   map[string]bool
+	requests int
+	changed  chan struct{}
+	noIndex  bool
+}
+
+// NewServer starts fake Consul agent. Caller must Close it
+func NewServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSServer starts fake Consul agent with TLS
+func NewTLSServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+// Start fake Consul agent closed on test cleanup
+func Start(t testing.TB) *Server {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newServer() *Server {
+	return &Server{
+		index:   1,
+		dcs:     map[string]map[string]*entry{DefaultDatacenter: {}},
+		changed: make(chan struct{}),
+	}
+}
+
+// AddToken enables ACL: only requests with one of the added tokens are allowed
+func (s *Server) AddToken(token string) *Server {
+	s.mu.Lock()
+	if s.tokens == nil {
+		s.tokens = make(map[string]bool)
+	}
+	s.tokens[token] = true
+	s.mu.Unlock()
+	return s
+}
+
+// AddDatacenter makes datacenter known to the agent
+func (s *Server) AddDatacenter(dc string) *Server {
+	s.mu.Lock()
+	if _, ok := s.dcs[dc]; !ok {
+		s.dcs[dc] = make(map[string]*entry)
+	}
+	s.mu.Unlock()
+	return s
+}
+
+// Requests returns count of handled requests
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// OmitIndex makes agent respond without X-Consul-Index header, as some proxies do
+func (s *Server) OmitIndex(omit bool) *Server {
+	s.mu.Lock()
+	s.noIndex = omit
+	s.mu.Unlock()
+	return s
+}
+
+// Index returns current Raft index
+func (s *Server) Index() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index
+}
+
+// Set value of the key in the default datacenter and namespace. Returns modify index
+func (s *Server) Set(key, value string) uint64 {
+	return s.SetIn(DefaultDatacenter, DefaultNamespace, key, value)
+}
+
+// SetIn sets value of the key in datacenter and namespace, adding datacenter if needed
+func (s *Server) SetIn(dc, ns, key, value string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.dcs[dc]; !ok {
+		s.dcs[dc] = make(map[string]*entry)
+	}
+	return s.set(dc, ns, key, []byte(value))
+}
+
+// Delete key in the default datacenter and namespace
+func (s *Server) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delete(DefaultDatacenter, DefaultNamespace, key, false)
+}
+
+func storeKey(ns, key string) string {
+	return ns + "\x00" + strings.TrimLeft(key, "/")
+}
+
+func (s *Server) set(dc, ns, key string, value []byte) uint64 {
+	s.index++
+	k := storeKey(ns, key)
+	e, ok := s.dcs[dc][k]
+	if !ok {
+		e = &entry{createIndex: s.index}
+		s.dcs[dc][k] = e
+	}
+	e.value = value
+	e.modifyIndex = s.index
+	s.notify()
+	return s.index
+}
+
+func (s *Server) delete(dc, ns, key string, recurse bool) {
+	prefix := storeKey(ns, key)
+	deleted := false
+	for k := range s.dcs[dc] {
+		if k == prefix || (recurse && strings.HasPrefix(k, prefix)) {
+			delete(s.dcs[dc], k)
+			deleted = true
+		}
+	}
+	if deleted {
+		s.index++
+		s.notify()
+	}
+}
+
+// notify wakes up blocking queries. Requires lock
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	dc := q.Get("dc")
+	if dc == "" {
+		dc = DefaultDatacenter
+	}
+	ns := q.Get("ns")
+	if ns == "" {
+		ns = DefaultNamespace
+	}
+
+	s.mu.Lock()
+	s.requests++
+	if !strings.HasPrefix(r.URL.Path, "/v1/kv/") {
+		s.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	if s.tokens != nil {
+		token := r.Header.Get("X-Consul-Token")
+		if token == "" {
+			token = q.Get("token")
+		}
+		if !s.tokens[token] {
+			s.mu.Unlock()
+			http.Error(w, "Permission denied", http.StatusForbidden)
+			return
+		}
+	}
+	if _, ok := s.dcs[dc]; !ok {
+		s.mu.Unlock()
+		http.Error(w, "No path to datacenter", http.StatusInternalServerError)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.get(w, r, dc, ns, key)
+		return
+	case http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		s.set(dc, ns, key, body)
+		s.writeJSON(w, http.StatusOK, true)
+	case http.MethodDelete:
+		_, recurse := q["recurse"]
+		s.delete(dc, ns, key, recurse)
+		s.writeJSON(w, http.StatusOK, true)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+	s.mu.Unlock()
+}
+
+// get responds with the key or prefix. Blocks until index is exceeded or wait elapses
+// if index is set. Requires lock, releases it
+func (s *Server) get(w http.ResponseWriter, r *http.Request, dc, ns, key string) {
+	q := r.URL.Query()
+	_, recurse := q["recurse"]
+	_, raw := q["raw"]
+	index, _ := strconv.ParseUint(q.Get("index"), 10, 64)
+	wait := 5 * time.Minute
+	if d, err := time.ParseDuration(q.Get("wait")); err == nil {
+		wait = d
+	}
+	timeout := time.After(wait)
+	for index > 0 && s.index <= index {
+		ch := s.changed
+		s.mu.Unlock()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timeout:
+			s.mu.Lock()
+			index = 0
+			continue
+		case <-ch:
+		}
+		s.mu.Lock()
+	}
+	defer s.mu.Unlock()
+
+	pairs := s.pairs(dc, ns, key, recurse)
+	if len(pairs) == 0 {
+		s.setIndex(w)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if raw && !recurse {
+		s.setIndex(w)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(pairs[0].Value)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, pairs)
+}
+
+// pairs returns sorted entries of the key or the prefix. Requires lock
+func (s *Server) pairs(dc, ns, key string, recurse bool) []KVPair {
+	prefix := storeKey(ns, key)
+	var res []KVPair
+	for k, e := range s.dcs[dc] {
+		if k != prefix && (!recurse || !strings.HasPrefix(k, prefix)) {
+			continue
+		}
+		res = append(res, KVPair{
+			Key:         k[len(ns)+1:],
+			Value:       e.value,
+			CreateIndex: e.createIndex,
+			ModifyIndex: e.modifyIndex,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res
+}
+
+// setIndex header of the response. Requires lock
+func (s *Server) setIndex(w http.ResponseWriter) {
+	if !s.noIndex {
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(s.index, 10))
+	}
+}
+
+// writeJSON with X-Consul-Index header. Requires lock
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	s.setIndex(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,139 @@
+// Package consul Noble Consul KV reader
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/jsonpath"
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("consul", &KeyReader{})
+}
+
+// KeyReader type implements noble.SecretStorage
+//
+// Key format: <key>[?raw][&recurse][&dc=<datacenter>][&ns=<namespace>][#<field>]
+//
+//	consul:app/db/password - value of the key
+//	consul:app/db/password?dc=eu-west - value of the key from another datacenter
+//	consul:app/db/?recurse - JSON object of all keys with the prefix, relative to the prefix
+//	consul:app/db#password - field of the JSON value (or of the recurse object)
+type KeyReader struct {
+}
+
+type kvPair struct {
+	Key         string `json:"Key"`
+	Value       []byte `json:"Value"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+}
+
+// Read key value from Consul KV
+func (r *KeyReader) Read(key string) (string, error) {
+	c, err := getClient()
+	if err != nil {
+		return "", err
+	}
+	key, query, field, err := parseKey(key)
+	if err != nil {
+		return "", err
+	}
+	body, err := c.read(key, query)
+	if err != nil {
+		return "", errors.Wrap(err, "consul key "+key)
+	}
+	val, err := decode(body, key, query)
+	if err != nil || field == "" {
+		return val, err
+	}
+	val, err = jsonpath.Extract([]byte(val), field)
+	return val, errors.Wrap(err, "consul key "+key)
+}
+
+// parseKey splits key into Consul key, API query and JSON field selector
+func parseKey(s string) (key string, query url.Values, field string, err error) {
+	if i := strings.Index(s, "#"); i >= 0 {
+		s, field = s[:i], s[i+1:]
+	}
+	query = url.Values{}
+	if i := strings.Index(s, "?"); i >= 0 {
+		q, err := url.ParseQuery(s[i+1:])
+		if err != nil {
+			return "", nil, "", errors.Wrap(err, "incorrect key format. use <key>[?raw][&recurse][&dc=<dc>][#<field>]")
+		}
+		for _, k := range []string{"raw", "recurse"} {
+			if _, ok := q[k]; ok {
+				query.Set(k, "")
+			}
+		}
+		for _, k := range []string{"dc", "ns"} {
+			if v := q.Get(k); v != "" {
+				query.Set(k, v)
+			}
+		}
+		s = s[:i]
+	}
+	if _, ok := query["recurse"]; ok {
+		query.Del("raw")
+	}
+	return s, query, field, nil
+}
+
+// decode response body of the KV API
+func decode(body []byte, key string, query url.Values) (string, error) {
+	if _, ok := query["raw"]; ok {
+		return string(body), nil
+	}
+	var pairs []kvPair
+	if err := json.Unmarshal(body, &pairs); err != nil {
+		return "", errors.Wrap(err, "invalid consul kv response")
+	}
+	if _, ok := query["recurse"]; !ok {
+		if len(pairs) == 0 {
+			return "", errors.New("consul key not found: " + key)
+		}
+		return string(pairs[0].Value), nil
+	}
+	m := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		if strings.HasSuffix(p.Key, "/") && len(p.Value) == 0 {
+			// folder
+			continue
+		}
+		m[strings.TrimPrefix(p.Key, strings.TrimLeft(key, "/"))] = string(p.Value)
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+// read returns response body from the watcher cache or from the API
+func (c *consulClient) read(key string, query url.Values) ([]byte, error) {
+	name := watchName(key, query)
+	c.wmu.Lock()
+	w, ok := c.watchers[name]
+	c.wmu.Unlock()
+	if !ok && !c.cfg.Watch {
+		body, _, err := c.get(context.Background(), c.http, key, query)
+		return body, err
+	}
+	if !ok {
+		var err error
+		if w, err = c.watcher(key, query); err != nil {
+			return nil, err
+		}
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.body, w.err
+}
+
+// Clone returns new empty instance of KeyReader
+func (r *KeyReader) Clone() noble.SecretStorage {
+	return &KeyReader{}
+}
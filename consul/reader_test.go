@@ -0,0 +1,239 @@
+package consul
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lancer-kit/noble/consul/consultest"
+	"github.com/lancer-kit/noble/nobletest"
+)
+
+func initClient(t *testing.T, cfg *Config) {
+	nobletest.InitClient(t, func() error { return Init(cfg) }, clients.Reset)
+}
+
+func TestKeyReader_Read(t *testing.T) {
+	srv := consultest.Start(t)
+	srv.Set("app/db/password", "secret")
+	srv.Set("app/db/user", "admin")
+	srv.Set("app/db/", "")
+	srv.Set("app/api", `{"keys":{"stripe":"sk_test"}}`)
+	initClient(t, &Config{Address: srv.URL})
+
+	r := KeyReader{}
+	v, err := r.Read("app/db/password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+	v, err = r.Read("app/db/password?raw")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+	_, err = r.Read("app/none")
+	assert.Error(t, err)
+
+	v, err = r.Read("app/db/?recurse")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"password":"secret","user":"admin"}`, v)
+	v, err = r.Read("app/db/?recurse#user")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", v)
+	v, err = r.Read("app/api#keys.stripe")
+	assert.NoError(t, err)
+	assert.Equal(t, "sk_test", v)
+	_, err = r.Read("app/api#keys.none")
+	assert.Error(t, err)
+
+	var c nobletest.Config
+	assert.NoError(t, yaml.Unmarshal([]byte(`secret: "consul:app/db/user"`), &c))
+	assert.NoError(t, c.Secret.InternalError())
+	assert.Equal(t, "admin", c.Secret.Get())
+}
+
+func TestKeyReader_DatacenterNamespace(t *testing.T) {
+	srv := consultest.Start(t)
+	srv.Set("key", "dc1")
+	srv.SetIn("eu-west", consultest.DefaultNamespace, "key", "eu-west")
+	srv.SetIn(consultest.DefaultDatacenter, "team-a", "key", "team-a")
+	initClient(t, &Config{Address: srv.URL})
+
+	r := KeyReader{}
+	v, err := r.Read("key?dc=eu-west")
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-west", v)
+	v, err = r.Read("key?ns=team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", v)
+	_, err = r.Read("key?dc=none")
+	assert.Error(t, err)
+
+	initClient(t, &Config{Address: srv.URL, Datacenter: "eu-west"})
+	v, err = r.Read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-west", v)
+	v, err = r.Read("key?dc=dc1")
+	assert.NoError(t, err)
+	assert.Equal(t, "dc1", v)
+}
+
+func TestInit_TokenTLS(t *testing.T) {
+	srv := consultest.NewTLSServer()
+	defer srv.Close()
+	srv.AddToken("token").Set("key", "value")
+
+	initClient(t, &Config{Address: srv.URL, Token: "token"})
+	_, err := (&KeyReader{}).Read("key")
+	assert.Error(t, err, "untrusted certificate")
+
+	initClient(t, &Config{Address: srv.URL, InsecureSkipVerify: true, Token: "x"})
+	_, err = (&KeyReader{}).Read("key")
+	assert.Error(t, err)
+
+	initClient(t, &Config{Address: srv.URL, InsecureSkipVerify: true, Token: "token"})
+	v, err := (&KeyReader{}).Read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", v)
+
+	assert.Error(t, Init(&Config{CertFile: "none.pem", KeyFile: "none-key.pem"}))
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consul")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("file-token\n"), 0600))
+	env := map[string]string{
+		"CONSUL_HTTP_ADDR":       "consul.service:8501",
+		"CONSUL_HTTP_TOKEN_FILE": tokenFile,
+		"CONSUL_HTTP_SSL":        "true",
+		"CONSUL_HTTP_SSL_VERIFY": "false",
+		"CONSUL_CACERT":          "/etc/consul/ca.pem",
+		"CONSUL_CLIENT_CERT":     "/etc/consul/client.pem",
+		"CONSUL_CLIENT_KEY":      "/etc/consul/client-key.pem",
+		"CONSUL_NAMESPACE":       "team-a",
+	}
+	nobletest.Setenv(t, env)
+	assert.Equal(t, Config{
+		Address:            "https://consul.service:8501",
+		Token:              "file-token",
+		Namespace:          "team-a",
+		CertFile:           "/etc/consul/client.pem",
+		KeyFile:            "/etc/consul/client-key.pem",
+		CAFile:             "/etc/consul/ca.pem",
+		InsecureSkipVerify: true,
+		Timeout:            DefaultTimeout,
+		WaitTime:           DefaultWaitTime,
+	}, ConfigFromEnv())
+}
+
+func TestWatch(t *testing.T) {
+	srv := consultest.Start(t)
+	srv.Set("app/db/password", "secret")
+	srv.Set("app/db/user", "admin")
+	srv.Set("app/api", "key-1")
+	initClient(t, &Config{Address: srv.URL, Watch: true})
+	defer Close()
+
+	events := make(chan [2]string, 10)
+	defer Subscribe(func(key, value string) { events <- [2]string{key, value} })()
+
+	r := KeyReader{}
+	v, err := r.Read("app/api")
+	assert.NoError(t, err)
+	assert.Equal(t, "key-1", v)
+	assert.NoError(t, Watch("app/db/?recurse"))
+	v, err = r.Read("app/db/?recurse#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+
+	// served from memory
+	requests := srv.Requests()
+	for i := 0; i < 3; i++ {
+		_, _ = r.Read("app/api")
+	}
+	assert.Equal(t, requests, srv.Requests())
+
+	srv.Set("app/api", "key-2")
+	assert.Equal(t, [2]string{"app/api", "key-2"}, waitEvent(t, events))
+	v, err = r.Read("app/api")
+	assert.NoError(t, err)
+	assert.Equal(t, "key-2", v)
+
+	srv.Set("app/db/password", "changed")
+	ev := waitEvent(t, events)
+	assert.Equal(t, "app/db/?recurse", ev[0])
+	assert.JSONEq(t, `{"password":"changed","user":"admin"}`, ev[1])
+
+	srv.Delete("app/api")
+	assert.Equal(t, [2]string{"app/api", ""}, waitEvent(t, events))
+	_, err = r.Read("app/api")
+	assert.Error(t, err)
+}
+
+func TestWatch_WaitTime(t *testing.T) {
+	srv := consultest.Start(t)
+	srv.Set("key", "1")
+	initClient(t, &Config{Address: srv.URL, WaitTime: 50 * time.Millisecond})
+	defer Close()
+	events := make(chan [2]string, 10)
+	defer Subscribe(func(key, value string) { events <- [2]string{key, value} })()
+
+	assert.NoError(t, Watch("key"))
+	time.Sleep(200 * time.Millisecond)
+	assert.Empty(t, events, "no event on blocking query timeout")
+	srv.Set("key", "2")
+	assert.Equal(t, [2]string{"key", "2"}, waitEvent(t, events))
+}
+
+func TestWatch_Metadata(t *testing.T) {
+	srv := consultest.Start(t)
+	srv.Set("app/db/password", "secret")
+	initClient(t, &Config{Address: srv.URL})
+	defer Close()
+	events := make(chan [2]string, 10)
+	defer Subscribe(func(key, value string) { events <- [2]string{key, value} })()
+
+	assert.NoError(t, Watch("app/db/?recurse"))
+	// ModifyIndex changes, value does not
+	srv.Set("app/db/password", "secret")
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, events)
+	srv.Set("app/db/user", "admin")
+	ev := waitEvent(t, events)
+	assert.JSONEq(t, `{"password":"secret","user":"admin"}`, ev[1])
+	assert.Empty(t, events)
+}
+
+func TestWatch_MissingIndex(t *testing.T) {
+	defer func(min time.Duration) { WatchRetryMin = min }(WatchRetryMin)
+	WatchRetryMin = 50 * time.Millisecond
+	srv := consultest.Start(t)
+	srv.Set("key", "1")
+	srv.Set("other", "x")
+	srv.OmitIndex(true)
+	initClient(t, &Config{Address: srv.URL})
+	defer Close()
+
+	assert.NoError(t, Watch("key"))
+	requests := srv.Requests()
+	time.Sleep(300 * time.Millisecond)
+	assert.True(t, srv.Requests()-requests < 10, "blocking queries are rate-limited: %d", srv.Requests()-requests)
+	v, err := (&KeyReader{}).Read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", v)
+}
+
+func waitEvent(t *testing.T, events chan [2]string) [2]string {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watch event")
+	}
+	return [2]string{}
+}
@@ -0,0 +1,198 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/watch"
+)
+
+// Delay bounds between failed blocking queries
+//
+//nolint:gochecknoglobals
+var (
+	WatchRetryMin = 500 * time.Millisecond
+	WatchRetryMax = 30 * time.Second
+)
+
+// Subscriber receives watched key (with query) and its new value, empty string if deleted
+type Subscriber = watch.Subscriber
+
+//nolint:gochecknoglobals
+var subs watch.Registry
+
+// Subscribe fn to changes of the watched keys. Returns function to unsubscribe
+func Subscribe(fn Subscriber) func() {
+	return subs.Subscribe(fn)
+}
+
+// Watch reads key (with optional "?recurse", "dc" and "ns" query) into the local cache
+// and keeps it current using blocking queries. KeyReader serves watched keys from memory
+func Watch(key string) error {
+	c, err := getClient()
+	if err != nil {
+		return err
+	}
+	key, query, _, err := parseKey(key)
+	if err != nil {
+		return err
+	}
+	_, err = c.watcher(key, query)
+	return err
+}
+
+// Close stops all watchers of the current client. Keys are read directly after Close
+func Close() {
+	if c := clients.Current(); c != nil {
+		c.(*consulClient).stopWatchers()
+	}
+}
+
+type watcher struct {
+	c     *consulClient
+	key   string
+	query url.Values
+	name  string
+
+	mu     sync.RWMutex
+	body   []byte
+	value  string // decoded body, compared to detect changes
+	err    error
+	index  uint64
+	loaded bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// watchName returns key with sorted query, passed to subscribers
+func watchName(key string, query url.Values) string {
+	if len(query) == 0 {
+		return key
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v := query.Get(k); v != "" {
+			parts = append(parts, k+"="+v)
+			continue
+		}
+		parts = append(parts, k)
+	}
+	return key + "?" + strings.Join(parts, "&")
+}
+
+// watcher returns running watcher of the key or starts new one after the initial read
+func (c *consulClient) watcher(key string, query url.Values) (*watcher, error) {
+	name := watchName(key, query)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if w, ok := c.watchers[name]; ok {
+		return w, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &watcher{c: c, key: key, query: query, name: name, cancel: cancel, done: make(chan struct{})}
+	if err := w.poll(ctx, c.http, 0); err != nil {
+		cancel()
+		return nil, err
+	}
+	c.watchers[name] = w
+	go w.run(ctx)
+	return w, nil
+}
+
+func (c *consulClient) stopWatchers() {
+	c.wmu.Lock()
+	list := c.watchers
+	c.watchers = make(map[string]*watcher)
+	c.wmu.Unlock()
+	for _, w := range list {
+		w.cancel()
+		<-w.done
+	}
+}
+
+func (w *watcher) run(ctx context.Context) {
+	defer close(w.done)
+	b := watch.Backoff{Min: &WatchRetryMin, Max: &WatchRetryMax}
+	for ctx.Err() == nil {
+		w.mu.RLock()
+		index := w.index
+		w.mu.RUnlock()
+		start := time.Now()
+		err := w.poll(ctx, w.c.watch, index)
+		w.mu.RLock()
+		// blocking query returned immediately without new index: rate-limit as failure
+		immediate := index > 0 && w.index == index && time.Since(start) < WatchRetryMin
+		w.mu.RUnlock()
+		if err == nil && !immediate {
+			b.Reset()
+			continue
+		}
+		if !b.Wait(ctx) {
+			return
+		}
+	}
+}
+
+// poll runs blocking query (plain read if index is zero) and updates the cache.
+// Missing key is cached as error
+func (w *watcher) poll(ctx context.Context, hc *http.Client, index uint64) error {
+	q := url.Values{}
+	for k, v := range w.query {
+		q[k] = v
+	}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", strconv.FormatInt(int64(w.c.cfg.WaitTime/time.Millisecond), 10)+"ms")
+	}
+	// Consul adds up to wait/16 jitter
+	wctx, cancel := context.WithTimeout(ctx, w.c.cfg.WaitTime+w.c.cfg.WaitTime/16+w.c.cfg.Timeout)
+	defer cancel()
+	body, newIndex, err := w.c.get(wctx, hc, w.key, q)
+	if e, ok := err.(*apiError); ok && e.Status == http.StatusNotFound {
+		w.update(nil, err, newIndex)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	w.update(body, nil, newIndex)
+	return nil
+}
+
+func (w *watcher) update(body []byte, err error, index uint64) {
+	var val string
+	if body != nil {
+		val, _ = decode(body, w.key, w.query)
+	}
+	w.mu.Lock()
+	first := !w.loaded
+	w.loaded = true
+	// metadata of the pairs (ModifyIndex, LockIndex) is ignored
+	changed := w.value != val || (w.err == nil) != (err == nil)
+	w.body, w.value, w.err = body, val, err
+	if index < 1 {
+		// missing or zero index would make blocking query return immediately
+		index = 1
+	}
+	if index < w.index {
+		// index went backwards (e.g. snapshot restore): next query is a plain read
+		index = 0
+	}
+	w.index = index
+	w.mu.Unlock()
+	if changed && !first {
+		subs.Notify(w.name, val)
+	}
+}
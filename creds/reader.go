@@ -0,0 +1,68 @@
+// Package creds Noble reader of the systemd service credentials
+package creds
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/fileperm"
+	"github.com/pkg/errors"
+)
+
+// DirectoryEnv environment variable set by systemd for units with credentials
+const DirectoryEnv = "CREDENTIALS_DIRECTORY"
+
+// ErrNoDirectory returned when the process has no credentials directory
+var ErrNoDirectory = errors.New(DirectoryEnv + " is not set: unit has no credentials, " + //nolint:gochecknoglobals
+	"add LoadCredential=, LoadCredentialEncrypted=, SetCredential= or SetCredentialEncrypted= to the unit")
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("creds", &Reader{})
+}
+
+// Reader type implements noble.SecretStorage
+//
+// Key format: <name>
+//
+//	creds:db_password - whole content of $CREDENTIALS_DIRECTORY/db_password
+//
+// Credential must be a regular file owned by the current user (or root)
+// and not accessible by others. Group permissions are allowed as ACL mask of the credentials shared by systemd
+type Reader struct {
+}
+
+// Read credential file
+func (r *Reader) Read(name string) (string, error) {
+	dir := os.Getenv(DirectoryEnv)
+	if dir == "" {
+		return "", ErrNoDirectory
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", errors.New("incorrect credential name: " + name)
+	}
+	file := filepath.Join(dir, name)
+	fi, err := os.Lstat(file)
+	if err != nil {
+		return "", errors.Wrap(err, "credential "+name)
+	}
+	if !fi.Mode().IsRegular() {
+		return "", errors.New("credential " + name + " is not a regular file")
+	}
+	if err := fileperm.Check(fi, true); err != nil {
+		return "", errors.Wrap(err, "credential "+name)
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", errors.Wrap(err, "credential "+name)
+	}
+	return string(b), nil
+}
+
+// Clone returns new empty instance of Reader
+func (r *Reader) Clone() noble.SecretStorage {
+	return &Reader{}
+}
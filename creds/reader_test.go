@@ -0,0 +1,49 @@
+package creds
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lancer-kit/noble/nobletest"
+)
+
+func TestReader_Read(t *testing.T) {
+	r := Reader{}
+	nobletest.Setenv(t, map[string]string{DirectoryEnv: ""})
+	_, err := r.Read("db_password")
+	assert.Equal(t, ErrNoDirectory, err)
+
+	dir, err := ioutil.TempDir("", "creds")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	nobletest.Setenv(t, map[string]string{DirectoryEnv: dir})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "db_password"), []byte("secret\n"), 0400))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "open"), []byte("secret"), 0644))
+	// shared with the service user by ACL, mask is shown as group permissions
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "acl"), []byte("shared"), 0400))
+	assert.NoError(t, os.Chmod(filepath.Join(dir, "acl"), 0440))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0700))
+	assert.NoError(t, os.Symlink(filepath.Join(dir, "db_password"), filepath.Join(dir, "link")))
+
+	v, err := r.Read("db_password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret\n", v, "whole content")
+	v, err = r.Read("acl")
+	assert.NoError(t, err)
+	assert.Equal(t, "shared", v)
+
+	for _, name := range []string{"open", "sub", "link", "none", "../db_password", "sub/x", ""} {
+		_, err = r.Read(name)
+		assert.Error(t, err, name)
+	}
+
+	var c nobletest.Config
+	assert.NoError(t, yaml.Unmarshal([]byte(`secret: "creds:db_password"`), &c))
+	assert.NoError(t, c.Secret.InternalError())
+	assert.Equal(t, "secret\n", c.Secret.Get())
+}
@@ -0,0 +1,62 @@
+// Package docker Noble reader of the Docker Swarm and Compose secrets
+package docker
+
+import (
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/jsonpath"
+	"github.com/pkg/errors"
+)
+
+// DefaultRoot directory of the mounted secrets
+const DefaultRoot = "/run/secrets"
+
+// Root directory of the secrets, change it before the first read
+//
+//nolint:gochecknoglobals
+var Root = DefaultRoot
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("docker", &Reader{})
+}
+
+// Reader type implements noble.SecretStorage
+//
+// Key format: <name>[#<field>], relative to Root
+//
+//	docker:db_password - value of /run/secrets/db_password, trailing newlines are trimmed
+//	docker:app_config#db.password - field of the JSON value
+type Reader struct {
+}
+
+// Read secret file
+func (r *Reader) Read(key string) (string, error) {
+	var field string
+	if i := strings.Index(key, "#"); i >= 0 {
+		key, field = key[:i], key[i+1:]
+	}
+	key = path.Clean("/" + key)[1:]
+	if key == "" {
+		return "", errors.New("incorrect key format. use <name>[#<field>]")
+	}
+	b, err := ioutil.ReadFile(filepath.Join(Root, filepath.FromSlash(key)))
+	if err != nil {
+		return "", errors.Wrap(err, "docker secret "+key)
+	}
+	val := strings.TrimRight(string(b), "\r\n")
+	if field == "" {
+		return val, nil
+	}
+	val, err = jsonpath.Extract([]byte(val), field)
+	return val, errors.Wrap(err, "docker secret "+key)
+}
+
+// Clone returns new empty instance of Reader
+func (r *Reader) Clone() noble.SecretStorage {
+	return &Reader{}
+}
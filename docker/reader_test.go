@@ -0,0 +1,43 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lancer-kit/noble/nobletest"
+)
+
+func TestReader_Read(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	prev := Root
+	Root = dir
+	defer func() { Root = prev }()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "db_password"), []byte("secret\n"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "app_config"), []byte(`{"db":{"user":"admin"}}`), 0600))
+
+	r := Reader{}
+	v, err := r.Read("db_password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+	v, err = r.Read("app_config#db.user")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", v)
+	_, err = r.Read("app_config#db.none")
+	assert.Error(t, err)
+	_, err = r.Read("none")
+	assert.Error(t, err)
+	_, err = r.Read("")
+	assert.Error(t, err)
+
+	var c nobletest.Config
+	assert.NoError(t, yaml.Unmarshal([]byte(`secret: "postgres://admin:{{docker:db_password}}@db/app"`), &c))
+	assert.NoError(t, c.Secret.InternalError())
+	assert.Equal(t, "postgres://admin:secret@db/app", c.Secret.Get())
+}
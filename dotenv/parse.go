@@ -0,0 +1,274 @@
+package dotenv
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Parse dotenv file content. Supported syntax:
+//
+//	# comment
+//	export KEY=value        # "export" prefix and inline comments
+//	KEY='literal $value'    # single quotes: no escapes and no expansion
+//	KEY="line\nnext ${USER}" # double quotes: \n \r \t \\ \" \$ escapes and expansion, may span lines
+//	KEY=${HOST:-localhost}:$PORT
+//
+// Variables are expanded from the keys defined above in the file, then from the process environment.
+// ${VAR:-default} uses default if VAR is unset or empty, ${VAR-default} if VAR is unset
+func Parse(data string) (map[string]string, error) {
+	p := &parser{src: strings.ReplaceAll(data, "\r\n", "\n"), line: 1, vars: make(map[string]string)}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return p.vars, nil
+		}
+		if err := p.assignment(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+type parser struct {
+	src  string
+	pos  int
+	line int
+	vars map[string]string
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) next() byte {
+	c := p.src[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("dotenv line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// skipBlank skips whitespace, empty lines and comment lines
+func (p *parser) skipBlank() {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == '#':
+			p.skipLine()
+		case isSpace(c) || c == '\n':
+			p.next()
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) skipLine() {
+	for !p.eof() && p.next() != '\n' {
+	}
+}
+
+func (p *parser) skipSpaces() {
+	for !p.eof() && isSpace(p.peek()) {
+		p.next()
+	}
+}
+
+func (p *parser) assignment() error {
+	key := p.name()
+	if key == "export" && isSpace(p.peek()) {
+		p.skipSpaces()
+		key = p.name()
+	}
+	if key == "" {
+		return p.errorf("invalid variable name")
+	}
+	p.skipSpaces()
+	if p.eof() || p.next() != '=' {
+		return p.errorf("expected '=' after %s", key)
+	}
+	p.skipSpaces()
+	var val string
+	var err error
+	switch p.peek() {
+	case '\'':
+		val, err = p.singleQuoted()
+	case '"':
+		val, err = p.doubleQuoted()
+	default:
+		val, err = p.unquoted()
+	}
+	if err != nil {
+		return err
+	}
+	p.vars[key] = val
+	return nil
+}
+
+func (p *parser) name() string {
+	start := p.pos
+	for !p.eof() && isNameChar(p.peek(), p.pos == start) {
+		p.next()
+	}
+	return p.src[start:p.pos]
+}
+
+// rest checks that only spaces and comment follow the quoted value
+func (p *parser) rest() error {
+	p.skipSpaces()
+	switch p.peek() {
+	case 0, '\n':
+	case '#':
+		p.skipLine()
+	default:
+		return p.errorf("unexpected character %q after quoted value", p.peek())
+	}
+	return nil
+}
+
+func (p *parser) singleQuoted() (string, error) {
+	p.next()
+	start := p.pos
+	for !p.eof() {
+		if p.peek() == '\'' {
+			val := p.src[start:p.pos]
+			p.next()
+			return val, p.rest()
+		}
+		p.next()
+	}
+	return "", p.errorf("unterminated single-quoted value")
+}
+
+func (p *parser) doubleQuoted() (string, error) {
+	p.next()
+	var b strings.Builder
+	for !p.eof() {
+		switch c := p.next(); c {
+		case '"':
+			return b.String(), p.rest()
+		case '\\':
+			if p.eof() {
+				return "", p.errorf("unterminated double-quoted value")
+			}
+			b.WriteString(unescape(p.next()))
+		case '$':
+			v, err := p.expand()
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated double-quoted value")
+}
+
+func (p *parser) unquoted() (string, error) {
+	var b strings.Builder
+	for !p.eof() && p.peek() != '\n' {
+		c := p.next()
+		switch {
+		case c == '#' && (b.Len() == 0 || isSpace(b.String()[b.Len()-1])):
+			p.skipLine()
+			return strings.TrimRight(b.String(), " \t"), nil
+		case c == '\\' && !p.eof() && p.peek() == '$':
+			b.WriteByte(p.next())
+		case c == '$':
+			v, err := p.expand()
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return strings.TrimRight(b.String(), " \t"), nil
+}
+
+// expand variable reference after '$'
+func (p *parser) expand() (string, error) {
+	if p.peek() != '{' {
+		name := p.name()
+		if name == "" {
+			return "$", nil
+		}
+		return p.lookup(name), nil
+	}
+	p.next()
+	name := p.name()
+	if name == "" {
+		return "", p.errorf("invalid variable name in ${}")
+	}
+	emptyDefault := false
+	if strings.HasPrefix(p.src[p.pos:], ":-") {
+		p.pos += 2
+		emptyDefault = true
+	} else if p.peek() == '-' {
+		p.next()
+	} else if p.peek() == '}' {
+		p.next()
+		return p.lookup(name), nil
+	} else {
+		return "", p.errorf("unsupported expansion of %s", name)
+	}
+	start := p.pos
+	for !p.eof() && p.peek() != '}' && p.peek() != '\n' {
+		p.next()
+	}
+	if p.peek() != '}' {
+		return "", p.errorf("unterminated ${%s", name)
+	}
+	def := p.src[start:p.pos]
+	p.next()
+	v, ok := p.vars[name]
+	if !ok {
+		v, ok = os.LookupEnv(name)
+	}
+	if !ok || (emptyDefault && v == "") {
+		return def, nil
+	}
+	return v, nil
+}
+
+func (p *parser) lookup(name string) string {
+	if v, ok := p.vars[name]; ok {
+		return v
+	}
+	return os.Getenv(name)
+}
+
+func unescape(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	case '"', '\\', '$':
+		return string(c)
+	}
+	return "\\" + string(c)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
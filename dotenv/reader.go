@@ -0,0 +1,101 @@
+// Package dotenv Noble reader of the .env files
+package dotenv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lancer-kit/noble"
+	"github.com/pkg/errors"
+)
+
+// DefaultFile used for keys without file, relative to the working directory
+//
+//nolint:gochecknoglobals
+var DefaultFile = ".env"
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("dotenv", &Reader{})
+}
+
+// Reader type implements noble.SecretStorage
+//
+// Key format: [<file>#]<name>
+//
+//	dotenv:.env#DB_PASS - variable DB_PASS of the .env file
+//	dotenv:/etc/app/prod.env#DB_PASS
+//	dotenv:DB_PASS - variable of the DefaultFile
+type Reader struct {
+}
+
+// Read variable from the dotenv file
+func (r *Reader) Read(key string) (string, error) {
+	file, name := DefaultFile, key
+	if i := strings.LastIndex(key, "#"); i >= 0 {
+		file, name = key[:i], key[i+1:]
+	}
+	if file == "" || name == "" {
+		return "", errors.New("incorrect key format. use [<file>#]<name>")
+	}
+	vars, err := load(file)
+	if err != nil {
+		return "", err
+	}
+	val, ok := vars[name]
+	if !ok {
+		return "", errors.New("dotenv variable " + name + " not found in " + file)
+	}
+	return val, nil
+}
+
+// Clone returns new empty instance of Reader
+func (r *Reader) Clone() noble.SecretStorage {
+	return &Reader{}
+}
+
+type parsed struct {
+	modTime time.Time
+	size    int64
+	vars    map[string]string
+}
+
+//nolint:gochecknoglobals
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]*parsed)
+)
+
+// load parsed file from the cache, parsing it again if modification time or size changed
+func load(file string) (map[string]string, error) {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "dotenv")
+	}
+	cacheMu.Lock()
+	p, ok := cache[path]
+	cacheMu.Unlock()
+	if ok && p.modTime.Equal(fi.ModTime()) && p.size == fi.Size() {
+		return p.vars, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "dotenv")
+	}
+	vars, err := Parse(string(data))
+	if err != nil {
+		return nil, errors.Wrap(err, file)
+	}
+	cacheMu.Lock()
+	cache[path] = &parsed{modTime: fi.ModTime(), size: fi.Size(), vars: vars}
+	cacheMu.Unlock()
+	return vars, nil
+}
@@ -0,0 +1,149 @@
+package etcdr2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lancer-kit/noble/internal/tlsconf"
+)
+
+type etcdClient struct {
+	cfg  Config
+	http *http.Client
+	// watch client without timeout for long-poll requests
+	watch *http.Client
+
+	mu   sync.Mutex
+	next int // index of the endpoint to try first
+
+	wmu      sync.Mutex
+	watchers map[string]*watcher
+}
+
+// v2Error error response of the keys API. Not retried on other endpoints
+type v2Error struct {
+	Status    int    `json:"-"`
+	ErrorCode int    `json:"errorCode"`
+	Message   string `json:"message"`
+	Cause     string `json:"cause"`
+}
+
+func (e *v2Error) Error() string {
+	if e.ErrorCode == 0 {
+		return fmt.Sprintf("invalid etcd api v2 status code: %d", e.Status)
+	}
+	return "etcd api v2 error " + e.Message + ": " + e.Cause
+}
+
+func newClient(cfg Config) (*etcdClient, error) {
+	if len(cfg.Endpoints) == 0 {
+		cfg.Endpoints = []string{EtcdConnectionString}
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	endpoints := make([]string, 0, len(cfg.Endpoints))
+	for _, e := range cfg.Endpoints {
+		e = strings.TrimRight(strings.TrimSpace(e), "/")
+		if !strings.Contains(e, "://") {
+			e = "http://" + e
+		}
+		endpoints = append(endpoints, e)
+	}
+	cfg.Endpoints = endpoints
+	tlsCfg, err := tlsconf.Files{
+		CAFile:             cfg.CAFile,
+		CertFile:           cfg.CertFile,
+		KeyFile:            cfg.KeyFile,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}.Load()
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{TLSClientConfig: tlsCfg, Proxy: http.ProxyFromEnvironment}
+	return &etcdClient{
+		cfg:      cfg,
+		http:     &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		watch:    &http.Client{Transport: transport},
+		watchers: make(map[string]*watcher),
+	}, nil
+}
+
+// Close stops watchers and closes idle connections of the client
+func (c *etcdClient) Close() {
+	c.stopWatchers()
+	c.http.CloseIdleConnections()
+}
+
+// get key from the first available endpoint and decode response into rsp
+func (c *etcdClient) get(key string, query url.Values, rsp interface{}) error {
+	_, err := c.getWith(context.Background(), c.http, key, query, rsp)
+	return err
+}
+
+// getWith gets key using http client hc and returns X-Etcd-Index of the response
+func (c *etcdClient) getWith(ctx context.Context, hc *http.Client, key string, query url.Values,
+	rsp interface{}) (uint64, error) {
+	c.mu.Lock()
+	start := c.next
+	c.mu.Unlock()
+
+	var lastErr error
+	for i := 0; i < len(c.cfg.Endpoints); i++ {
+		n := (start + i) % len(c.cfg.Endpoints)
+		index, err := c.getEndpoint(ctx, hc, c.cfg.Endpoints[n], key, query, rsp)
+		if err == nil {
+			c.mu.Lock()
+			c.next = n
+			c.mu.Unlock()
+			return index, nil
+		}
+		if e, ok := err.(*v2Error); ok && e.Status < http.StatusInternalServerError {
+			return index, err
+		}
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		lastErr = err
+	}
+	return 0, lastErr
+}
+
+func (c *etcdClient) getEndpoint(ctx context.Context, hc *http.Client, endpoint, key string, query url.Values,
+	rsp interface{}) (uint64, error) {
+	u := endpoint + "/v2/keys/" + strings.TrimLeft(key, "/")
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.cfg.Username != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+	res, err := hc.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return 0, err
+	}
+	index, _ := strconv.ParseUint(res.Header.Get("X-Etcd-Index"), 10, 64)
+	if res.StatusCode != http.StatusOK {
+		e := &v2Error{Status: res.StatusCode}
+		_ = json.Unmarshal(data, e)
+		return index, e
+	}
+	return index, json.Unmarshal(data, rsp)
+}
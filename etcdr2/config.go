@@ -0,0 +1,123 @@
+package etcdr2
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/storeclient"
+)
+
+// Config of the etcd v2 client
+type Config struct {
+	// Endpoints of the cluster members. Used round-robin on failure
+	Endpoints []string
+	// Username and Password for HTTP basic authentication. Empty Username disables auth
+	Username string
+	Password string
+	// CertFile and KeyFile client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// CAFile trusted CA bundle for server certificates
+	CAFile             string
+	InsecureSkipVerify bool
+	// Timeout of a single request
+	Timeout time.Duration
+	// Watch every key read by KeyReader: values are served from the local cache
+	// which is updated by long-poll watch requests. See Watch and Subscribe
+	Watch bool
+}
+
+// DefaultTimeout of a single request
+const DefaultTimeout = 5 * time.Second
+
+//nolint:gochecknoglobals
+var (
+	clients storeclient.Holder
+	// defaultClient built from EtcdConnectionString when Init was not called
+	defaultMu     sync.Mutex
+	defaultClient *etcdClient
+	defaultConn   string
+)
+
+// Init etcd v2 client. Config from ETCDCTL_* environment variables used if cfg is nil.
+// Without Init reader uses EtcdConnectionString
+func Init(cfg *Config) error {
+	if cfg == nil {
+		c := ConfigFromEnv()
+		cfg = &c
+	}
+	c, err := newClient(*cfg)
+	if err != nil {
+		return err
+	}
+	clients.Set(c)
+	return nil
+}
+
+func getClient() (*etcdClient, error) {
+	if c := clients.Current(); c != nil {
+		return c.(*etcdClient), nil
+	}
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultClient != nil && defaultConn == EtcdConnectionString {
+		return defaultClient, nil
+	}
+	c, err := newClient(Config{Endpoints: []string{EtcdConnectionString}})
+	if err != nil {
+		return nil, err
+	}
+	if defaultClient != nil {
+		defaultClient.Close()
+	}
+	defaultClient, defaultConn = c, EtcdConnectionString
+	return c, nil
+}
+
+// ConfigFromEnv returns config from etcdctl environment variables (v2 and v3 names):
+//
+//	ETCDCTL_ENDPOINTS or ETCDCTL_ENDPOINT - comma separated endpoints (EtcdConnectionString by default)
+//	ETCDCTL_USER or ETCDCTL_USERNAME - "user" or "user:password"
+//	ETCDCTL_PASSWORD - password, if not set in user
+//	ETCDCTL_CERT or ETCDCTL_CERT_FILE, ETCDCTL_KEY or ETCDCTL_KEY_FILE - client certificate
+//	ETCDCTL_CACERT or ETCDCTL_CA_FILE - CA bundle
+//	ETCDCTL_INSECURE_SKIP_TLS_VERIFY - "true" to skip server certificate verification
+//	ETCDCTL_COMMAND_TIMEOUT - request timeout, e.g. "5s"
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Endpoints: []string{EtcdConnectionString},
+		CertFile:  env("ETCDCTL_CERT", "ETCDCTL_CERT_FILE"),
+		KeyFile:   env("ETCDCTL_KEY", "ETCDCTL_KEY_FILE"),
+		CAFile:    env("ETCDCTL_CACERT", "ETCDCTL_CA_FILE"),
+		Password:  os.Getenv("ETCDCTL_PASSWORD"),
+		Timeout:   DefaultTimeout,
+	}
+	if e := env("ETCDCTL_ENDPOINTS", "ETCDCTL_ENDPOINT"); e != "" {
+		cfg.Endpoints = strings.Split(e, ",")
+	}
+	if u := env("ETCDCTL_USER", "ETCDCTL_USERNAME"); u != "" {
+		parts := strings.SplitN(u, ":", 2)
+		cfg.Username = parts[0]
+		if len(parts) == 2 {
+			cfg.Password = parts[1]
+		}
+	}
+	cfg.InsecureSkipVerify, _ = strconv.ParseBool(os.Getenv("ETCDCTL_INSECURE_SKIP_TLS_VERIFY"))
+	if d, err := time.ParseDuration(os.Getenv("ETCDCTL_COMMAND_TIMEOUT")); err == nil {
+		cfg.Timeout = d
+	}
+	return cfg
+}
+
+// env returns the first non-empty environment variable
+func env(names ...string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
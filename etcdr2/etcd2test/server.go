@@ -0,0 +1,363 @@
+// Package etcd2test provides in-process fake of the etcd v2 keys API for tests.
+//
+// Implemented subset: GET (with recursive directory listing and long-poll wait), PUT and DELETE of /v2/keys,
+// HTTP basic authentication.
+package etcd2test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// v2 API error codes
+const (
+	codeKeyNotFound       = 100
+	codeNotFile           = 102
+	codeNotDir            = 104
+	codeUnavailable       = 300
+	codeEventIndexCleared = 401
+)
+
+// DefaultHistorySize count of the events kept for wait requests, as in etcd
+const DefaultHistorySize = 1000
+
+type event struct {
+	action string
+	node   *Node
+}
+
+type node struct {
+	key           string
+	value         string
+	dir           bool
+	createdIndex  uint64
+	modifiedIndex uint64
+}
+
+// Node of the v2 API response
+type Node struct {
+	Key           string  `json:"key"`
+	Value         *string `json:"value,omitempty"`
+	Dir           bool    `json:"dir,omitempty"`
+	Nodes         []*Node `json:"nodes,omitempty"`
+	CreatedIndex  uint64  `json:"createdIndex"`
+	ModifiedIndex uint64  `json:"modifiedIndex"`
+}
+
+// Server fake etcd v2 member
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	index    uint64
+	nodes    map[string]*node
+	username string
+	password string
+	down     bool
+	fail     int
+	requests int
+	history  []event
+	changed  chan struct{}
+	// HistorySize count of the events kept for wait requests
+	HistorySize int
+}
+
+// NewServer starts fake etcd server. Caller must Close it
+func NewServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSServer starts fake etcd server with TLS
+func NewTLSServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+// Start fake etcd server closed on test cleanup
+func Start(t testing.TB) *Server {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newServer() *Server {
+	return &Server{
+		nodes:       map[string]*node{"/": {key: "/", dir: true}},
+		changed:     make(chan struct{}),
+		HistorySize: DefaultHistorySize,
+	}
+}
+
+// SetAuth enables HTTP basic authentication
+func (s *Server) SetAuth(username, password string) *Server {
+	s.mu.Lock()
+	s.username, s.password = username, password
+	s.mu.Unlock()
+	return s
+}
+
+// SetDown makes member respond 503 to all requests
+func (s *Server) SetDown(down bool) *Server {
+	s.mu.Lock()
+	s.down = down
+	s.mu.Unlock()
+	return s
+}
+
+// FailReads makes member respond 503 to the next n reads, wait requests are served
+func (s *Server) FailReads(n int) *Server {
+	s.mu.Lock()
+	s.fail = n
+	s.mu.Unlock()
+	return s
+}
+
+// Requests returns count of handled requests
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Index returns current etcd index
+func (s *Server) Index() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index
+}
+
+// Set value of the key, creating parent directories. Returns modified index
+func (s *Server) Set(key, value string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, code := s.set(clean(key), value)
+	if code != 0 {
+		return 0
+	}
+	return n.modifiedIndex
+}
+
+// Delete key or directory recursively
+func (s *Server) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delete(clean(key))
+}
+
+func clean(key string) string {
+	return path.Clean("/" + key)
+}
+
+func (s *Server) set(key, value string) (*node, int) {
+	if n, ok := s.nodes[key]; ok && n.dir {
+		return nil, codeNotFile
+	}
+	for dir := path.Dir(key); dir != "/"; dir = path.Dir(dir) {
+		if n, ok := s.nodes[dir]; ok && !n.dir {
+			return nil, codeNotDir
+		}
+	}
+	s.index++
+	for dir := path.Dir(key); dir != "/"; dir = path.Dir(dir) {
+		if _, ok := s.nodes[dir]; !ok {
+			s.nodes[dir] = &node{key: dir, dir: true, createdIndex: s.index, modifiedIndex: s.index}
+		}
+	}
+	n, ok := s.nodes[key]
+	if !ok {
+		n = &node{key: key, createdIndex: s.index}
+		s.nodes[key] = n
+	}
+	n.value = value
+	n.modifiedIndex = s.index
+	s.record("set", s.tree(n, false, false))
+	return n, 0
+}
+
+// record event for wait requests and wake up waiting ones. Requires lock
+func (s *Server) record(action string, n *Node) {
+	s.history = append(s.history, event{action: action, node: n})
+	if len(s.history) > s.HistorySize {
+		s.history = s.history[len(s.history)-s.HistorySize:]
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) delete(key string) bool {
+	n, ok := s.nodes[key]
+	if !ok || key == "/" {
+		return false
+	}
+	s.index++
+	for k := range s.nodes {
+		if k == key || strings.HasPrefix(k, key+"/") {
+			delete(s.nodes, k)
+		}
+	}
+	s.record("delete", &Node{Key: n.key, Dir: n.dir, CreatedIndex: n.createdIndex, ModifiedIndex: s.index})
+	return true
+}
+
+// tree returns response node. Children of directories are listed one level deep
+// unless recursive is true
+func (s *Server) tree(n *node, recursive, children bool) *Node {
+	res := &Node{Key: n.key, Dir: n.dir, CreatedIndex: n.createdIndex, ModifiedIndex: n.modifiedIndex}
+	if !n.dir {
+		v := n.value
+		res.Value = &v
+		return res
+	}
+	if !children {
+		return res
+	}
+	prefix := strings.TrimSuffix(n.key, "/") + "/"
+	var keys []string
+	for k := range s.nodes {
+		if strings.HasPrefix(k, prefix) && !strings.Contains(k[len(prefix):], "/") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		res.Nodes = append(res.Nodes, s.tree(s.nodes[k], recursive, recursive))
+	}
+	return res
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Query().Get("wait") == "true" {
+		s.wait(w, r)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.check(w, r) {
+		return
+	}
+	key := clean(strings.TrimPrefix(r.URL.Path, "/v2/keys"))
+	q := r.URL.Query()
+	switch r.Method {
+	case http.MethodGet:
+		if s.fail > 0 {
+			s.fail--
+			s.writeError(w, http.StatusServiceUnavailable, codeUnavailable, "etcd member is unavailable", "")
+			return
+		}
+		n, ok := s.nodes[key]
+		if !ok {
+			s.writeError(w, http.StatusNotFound, codeKeyNotFound, "Key not found", key)
+			return
+		}
+		s.writeNode(w, http.StatusOK, "get", s.tree(n, q.Get("recursive") == "true", true))
+	case http.MethodPut:
+		_ = r.ParseForm()
+		n, code := s.set(key, r.PostForm.Get("value"))
+		if code != 0 {
+			s.writeError(w, http.StatusForbidden, code, "Not a file", key)
+			return
+		}
+		s.writeNode(w, http.StatusOK, "set", s.tree(n, false, false))
+	case http.MethodDelete:
+		n, ok := s.nodes[key]
+		if !ok || !s.delete(key) {
+			s.writeError(w, http.StatusNotFound, codeKeyNotFound, "Key not found", key)
+			return
+		}
+		s.writeNode(w, http.StatusOK, "delete", &Node{Key: n.key, Dir: n.dir, CreatedIndex: n.createdIndex,
+			ModifiedIndex: s.index})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// check availability, authentication and path of the request. Requires lock
+func (s *Server) check(w http.ResponseWriter, r *http.Request) bool {
+	s.requests++
+	if s.down {
+		s.writeError(w, http.StatusServiceUnavailable, codeUnavailable, "etcd member is unavailable", "")
+		return false
+	}
+	if s.username != "" {
+		u, p, ok := r.BasicAuth()
+		if !ok || u != s.username || p != s.password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="etcd"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Insufficient credentials"})
+			return false
+		}
+	}
+	if !strings.HasPrefix(r.URL.Path, "/v2/keys") {
+		http.NotFound(w, r)
+		return false
+	}
+	return true
+}
+
+// wait responds with the first event of the key (or its descendants if recursive)
+// with index >= waitIndex, blocking until it happens
+func (s *Server) wait(w http.ResponseWriter, r *http.Request) {
+	key := clean(strings.TrimPrefix(r.URL.Path, "/v2/keys"))
+	q := r.URL.Query()
+	recursive := q.Get("recursive") == "true"
+	waitIndex, _ := strconv.ParseUint(q.Get("waitIndex"), 10, 64)
+
+	s.mu.Lock()
+	if !s.check(w, r) {
+		s.mu.Unlock()
+		return
+	}
+	for {
+		if waitIndex == 0 {
+			waitIndex = s.index + 1
+		}
+		if len(s.history) >= s.HistorySize && waitIndex < s.history[0].node.ModifiedIndex {
+			s.writeError(w, http.StatusBadRequest, codeEventIndexCleared,
+				"The event in requested index is outdated and cleared", key)
+			s.mu.Unlock()
+			return
+		}
+		for _, ev := range s.history {
+			k := ev.node.Key
+			if ev.node.ModifiedIndex >= waitIndex && (k == key || (recursive && strings.HasPrefix(k, key+"/"))) {
+				s.writeNode(w, http.StatusOK, ev.action, ev.node)
+				s.mu.Unlock()
+				return
+			}
+		}
+		ch := s.changed
+		s.mu.Unlock()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+		}
+		s.mu.Lock()
+	}
+}
+
+func (s *Server) writeNode(w http.ResponseWriter, status int, action string, n *Node) {
+	w.Header().Set("X-Etcd-Index", strconv.FormatUint(s.index, 10))
+	writeJSON(w, status, map[string]interface{}{"action": action, "node": n})
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status, code int, msg, cause string) {
+	w.Header().Set("X-Etcd-Index", strconv.FormatUint(s.index, 10))
+	writeJSON(w, status, map[string]interface{}{"errorCode": code, "message": msg, "cause": cause, "index": s.index})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -1,8 +1,14 @@
+// Package etcdr2 Noble etcd key/value API v2 reader
 package etcdr2
 
 import (
-	"github.com/lancer-kit/armory/api/httpx"
+	"encoding/json"
+	"net/url"
+	"path"
+	"strings"
+
 	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/jsonpath"
 	"github.com/pkg/errors"
 )
 
@@ -15,30 +21,102 @@ func init() {
 }
 
 //KeyReader type implements noble.SecretStorage
+//
+// Key format: <key>[?recursive=true][#<field>]
+//
+//	etcd2:services/db/password - value of the key
+//	etcd2:services/db - JSON object of the directory values, nested directories are empty objects
+//	etcd2:services?recursive=true - JSON object of the directory with all nested directories
+//	etcd2:services/db#password - field of the JSON value (or directory), e.g. "#db.hosts[0]"
 type KeyReader struct {
 	//key string
 }
 
+type v2Node struct {
+	Key           string    `json:"key"`
+	Value         string    `json:"value"`
+	Dir           bool      `json:"dir"`
+	Nodes         []*v2Node `json:"nodes"`
+	ModifiedIndex uint64    `json:"modifiedIndex"`
+}
+
 type v2Message struct {
-	Node struct {
-		Value string `json:"value"`
-	} `json:"node"`
+	Action string  `json:"action"`
+	Node   *v2Node `json:"node"`
 }
 
 // Read key value from etcd API v2
 func (r *KeyReader) Read(key string) (string, error) {
-	rsp, err := httpx.GetJSON(EtcdConnectionString+"/v2/keys/"+key, nil)
+	c, err := getClient()
 	if err != nil {
 		return "", err
 	}
-	if rsp.StatusCode != 200 {
-		return "", errors.Errorf("invalid etcd api v2 status code: %d", rsp.StatusCode)
+	key, query, field, err := parseKey(key)
+	if err != nil {
+		return "", err
 	}
-	var msg v2Message
-	if err := httpx.ParseJSONResult(rsp, &msg); err != nil {
+	n, err := c.read(key, query)
+	if err != nil {
 		return "", err
 	}
-	return msg.Node.Value, nil
+	if n == nil {
+		return "", errors.New("empty etcd api v2 response")
+	}
+	return nodeValue(n, field)
+}
+
+// parseKey splits key into etcd key, query and JSON field selector
+func parseKey(s string) (key string, query url.Values, field string, err error) {
+	if i := strings.Index(s, "#"); i >= 0 {
+		s, field = s[:i], s[i+1:]
+	}
+	if i := strings.Index(s, "?"); i >= 0 {
+		q, err := url.ParseQuery(s[i+1:])
+		if err != nil {
+			return "", nil, "", errors.Wrap(err, "incorrect key format. use <key>[?recursive=true][#<field>]")
+		}
+		query = url.Values{}
+		if q.Get("recursive") == "true" {
+			query.Set("recursive", "true")
+		}
+		s = s[:i]
+	}
+	return s, query, field, nil
+}
+
+// nodeValue returns value of the key or JSON of the directory, and extracts field if set
+func nodeValue(n *v2Node, field string) (string, error) {
+	if !n.Dir && field == "" {
+		return n.Value, nil
+	}
+	var data []byte
+	if n.Dir {
+		var err error
+		if data, err = json.Marshal(dirMap(n)); err != nil {
+			return "", err
+		}
+	} else {
+		data = []byte(n.Value)
+	}
+	if field == "" {
+		return string(data), nil
+	}
+	v, err := jsonpath.Extract(data, field)
+	return v, errors.Wrap(err, "etcd key "+n.Key)
+}
+
+// dirMap converts directory node to map with keys relative to the directory
+func dirMap(n *v2Node) map[string]interface{} {
+	m := make(map[string]interface{}, len(n.Nodes))
+	for _, c := range n.Nodes {
+		name := path.Base(c.Key)
+		if c.Dir {
+			m[name] = dirMap(c)
+			continue
+		}
+		m[name] = c.Value
+	}
+	return m
 }
 
 // Clone returns new empty instance of KeyReader
@@ -3,6 +3,7 @@ package etcdr2
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/lancer-kit/armory/api/httpx"
 
@@ -10,6 +11,8 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/etcdr2/etcd2test"
+	"github.com/lancer-kit/noble/nobletest"
 )
 
 const (
@@ -72,3 +75,220 @@ func TestFileReaderJson(t *testing.T) {
 	}
 	println("value:", c.Secret.Get())
 }
+
+func initClient(t *testing.T, cfg *Config) {
+	nobletest.InitClient(t, func() error { return Init(cfg) }, clients.Reset)
+}
+
+func TestKeyReader_Read(t *testing.T) {
+	srv := etcd2test.Start(t)
+	srv.Set("messages4/test", testValue)
+
+	prev := EtcdConnectionString
+	EtcdConnectionString = srv.URL
+	defer func() { EtcdConnectionString = prev }()
+	r := KeyReader{}
+	v, err := r.Read("messages4/test")
+	assert.NoError(t, err)
+	assert.Equal(t, testValue, v)
+	_, err = r.Read("messages4/none")
+	assert.Error(t, err)
+}
+
+func TestInit_Failover(t *testing.T) {
+	a, b := etcd2test.Start(t), etcd2test.Start(t)
+	a.Set("key", "a")
+	b.Set("key", "b")
+	a.SetDown(true)
+	initClient(t, &Config{Endpoints: []string{a.URL, "127.0.0.1:1", b.URL}})
+
+	r := KeyReader{}
+	v, err := r.Read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", v)
+	_, _ = r.Read("key")
+	assert.Equal(t, 1, a.Requests())
+	assert.Equal(t, 2, b.Requests())
+
+	// key errors are not retried
+	_, err = r.Read("none")
+	assert.Error(t, err)
+	assert.Equal(t, 1, a.Requests())
+}
+
+func TestInit_Auth(t *testing.T) {
+	srv := etcd2test.NewTLSServer()
+	defer srv.Close()
+	srv.SetAuth("root", "pass").Set("key", "value")
+
+	initClient(t, &Config{Endpoints: []string{srv.URL}, Username: "root", Password: "pass"})
+	_, err := (&KeyReader{}).Read("key")
+	assert.Error(t, err, "untrusted certificate")
+
+	initClient(t, &Config{Endpoints: []string{srv.URL}, InsecureSkipVerify: true, Username: "root", Password: "x"})
+	_, err = (&KeyReader{}).Read("key")
+	assert.Error(t, err)
+
+	initClient(t, &Config{Endpoints: []string{srv.URL}, InsecureSkipVerify: true, Username: "root", Password: "pass"})
+	v, err := (&KeyReader{}).Read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", v)
+
+	assert.Error(t, Init(&Config{CertFile: "none.pem", KeyFile: "none-key.pem"}))
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	env := map[string]string{
+		"ETCDCTL_ENDPOINTS":       "https://etcd-1:2379,https://etcd-2:2379",
+		"ETCDCTL_USER":            "root:pass",
+		"ETCDCTL_CACERT":          "/etc/etcd/ca.pem",
+		"ETCDCTL_CERT_FILE":       "/etc/etcd/client.pem",
+		"ETCDCTL_KEY":             "/etc/etcd/client-key.pem",
+		"ETCDCTL_COMMAND_TIMEOUT": "2s",
+	}
+	nobletest.Setenv(t, env)
+	assert.Equal(t, Config{
+		Endpoints: []string{"https://etcd-1:2379", "https://etcd-2:2379"},
+		Username:  "root",
+		Password:  "pass",
+		CertFile:  "/etc/etcd/client.pem",
+		KeyFile:   "/etc/etcd/client-key.pem",
+		CAFile:    "/etc/etcd/ca.pem",
+		Timeout:   2 * time.Second,
+	}, ConfigFromEnv())
+}
+
+func TestKeyReader_Directory(t *testing.T) {
+	srv := etcd2test.Start(t)
+	srv.Set("services/db/password", "secret")
+	srv.Set("services/db/user", "admin")
+	srv.Set("services/db/replica/host", "db-2")
+	srv.Set("services/api", `{"keys":{"stripe":"sk_test"},"hosts":["a","b"]}`)
+	initClient(t, &Config{Endpoints: []string{srv.URL}})
+
+	r := KeyReader{}
+	v, err := r.Read("services/db")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"password":"secret","user":"admin","replica":{}}`, v)
+
+	v, err = r.Read("services?recursive=true")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"db":{"password":"secret","user":"admin","replica":{"host":"db-2"}},
+		"api":"{\"keys\":{\"stripe\":\"sk_test\"},\"hosts\":[\"a\",\"b\"]}"}`, v)
+
+	v, err = r.Read("services/db#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+	v, err = r.Read("services?recursive=true#db.replica.host")
+	assert.NoError(t, err)
+	assert.Equal(t, "db-2", v)
+	v, err = r.Read("services/api#keys.stripe")
+	assert.NoError(t, err)
+	assert.Equal(t, "sk_test", v)
+	v, err = r.Read("services/api#hosts[1]")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", v)
+
+	_, err = r.Read("services/api#keys.none")
+	assert.Error(t, err)
+	_, err = r.Read("services/db/user#name")
+	assert.Error(t, err)
+
+	s := noble.Secret{}.New("etcd2:services?recursive=true")
+	m, err := s.Map()
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", m["db"].(map[string]interface{})["user"])
+}
+
+func TestWatch(t *testing.T) {
+	srv := etcd2test.Start(t)
+	srv.Set("services/db/password", "secret")
+	srv.Set("services/db/user", "admin")
+	srv.Set("services/api", "key-1")
+	initClient(t, &Config{Endpoints: []string{srv.URL}, Watch: true})
+	defer Close()
+
+	events := make(chan [2]string, 10)
+	defer Subscribe(func(key, value string) { events <- [2]string{key, value} })()
+
+	r := KeyReader{}
+	v, err := r.Read("services/api")
+	assert.NoError(t, err)
+	assert.Equal(t, "key-1", v)
+	assert.NoError(t, Watch("services/db?recursive=true"))
+	v, err = r.Read("services/db?recursive=true#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+
+	// served from memory
+	requests := srv.Requests()
+	for i := 0; i < 3; i++ {
+		_, _ = r.Read("services/api")
+	}
+	assert.Equal(t, requests, srv.Requests())
+
+	srv.Set("services/api", "key-2")
+	assert.Equal(t, [2]string{"services/api", "key-2"}, waitEvent(t, events))
+	v, err = r.Read("services/api")
+	assert.NoError(t, err)
+	assert.Equal(t, "key-2", v)
+
+	srv.Set("services/db/password", "changed")
+	ev := waitEvent(t, events)
+	assert.Equal(t, "services/db?recursive=true", ev[0])
+	assert.JSONEq(t, `{"password":"changed","user":"admin"}`, ev[1])
+	v, err = r.Read("services/db?recursive=true#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "changed", v)
+
+	srv.Delete("services/api")
+	assert.Equal(t, [2]string{"services/api", ""}, waitEvent(t, events))
+	_, err = r.Read("services/api")
+	assert.Error(t, err)
+}
+
+func TestWatch_IndexCleared(t *testing.T) {
+	srv := etcd2test.Start(t)
+	srv.HistorySize = 2
+	srv.Set("key", "1")
+	initClient(t, &Config{Endpoints: []string{srv.URL}})
+	defer Close()
+	events := make(chan [2]string, 10)
+	defer Subscribe(func(key, value string) { events <- [2]string{key, value} })()
+
+	assert.NoError(t, Watch("key"))
+	for i := 0; i < 5; i++ {
+		srv.Set("other", "x")
+	}
+	srv.Set("key", "2")
+	assert.Equal(t, [2]string{"key", "2"}, waitEvent(t, events))
+	v, err := (&KeyReader{}).Read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", v)
+}
+
+func TestWatch_RefreshFailed(t *testing.T) {
+	defer func(min time.Duration) { WatchRetryMin = min }(WatchRetryMin)
+	WatchRetryMin = 10 * time.Millisecond
+	srv := etcd2test.Start(t)
+	srv.Set("key", "1")
+	initClient(t, &Config{Endpoints: []string{srv.URL}})
+	defer Close()
+	events := make(chan [2]string, 10)
+	defer Subscribe(func(key, value string) { events <- [2]string{key, value} })()
+
+	assert.NoError(t, Watch("key"))
+	srv.FailReads(1)
+	srv.Set("key", "2")
+	assert.Equal(t, [2]string{"key", "2"}, waitEvent(t, events))
+}
+
+func waitEvent(t *testing.T, events chan [2]string) [2]string {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watch event")
+	}
+	return [2]string{}
+}
@@ -0,0 +1,241 @@
+package etcdr2
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/watch"
+)
+
+// Long-poll parameters
+//
+//nolint:gochecknoglobals
+var (
+	// WatchTimeout re-issues long-poll request after this time to detect dead connections
+	WatchTimeout = 5 * time.Minute
+	// WatchRetryMin and WatchRetryMax bounds of the delay between failed watch requests
+	WatchRetryMin = 500 * time.Millisecond
+	WatchRetryMax = 30 * time.Second
+)
+
+// errorCode of the v2 API
+const (
+	codeKeyNotFound = 100
+	// waitIndex is older than the kept event history
+	codeEventIndexCleared = 401
+)
+
+// Subscriber receives watched key and its new value (JSON for directories, empty string if deleted)
+type Subscriber = watch.Subscriber
+
+//nolint:gochecknoglobals
+var subs watch.Registry
+
+// Subscribe fn to changes of the watched keys. Returns function to unsubscribe
+func Subscribe(fn Subscriber) func() {
+	return subs.Subscribe(fn)
+}
+
+// Watch reads key (with optional "?recursive=true" for directories) into the local cache
+// and keeps it current using long-poll requests. KeyReader serves watched keys from memory
+func Watch(key string) error {
+	c, err := getClient()
+	if err != nil {
+		return err
+	}
+	key, query, _, err := parseKey(key)
+	if err != nil {
+		return err
+	}
+	_, err = c.watcher(key, query)
+	return err
+}
+
+// Close stops all watchers of the current client. Keys are read directly after Close
+func Close() {
+	var c *etcdClient
+	if cur := clients.Current(); cur != nil {
+		c = cur.(*etcdClient)
+	} else {
+		defaultMu.Lock()
+		c = defaultClient
+		defaultMu.Unlock()
+	}
+	if c != nil {
+		c.stopWatchers()
+	}
+}
+
+type watcher struct {
+	c     *etcdClient
+	key   string
+	query url.Values
+	name  string // key with query, passed to subscribers
+
+	mu     sync.RWMutex
+	node   *v2Node
+	err    error
+	index  uint64
+	loaded bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// read returns node from the cache of the watched key, starting watcher if Config.Watch is set
+func (c *etcdClient) read(key string, query url.Values) (*v2Node, error) {
+	c.wmu.Lock()
+	w, ok := c.watchers[watchName(key, query)]
+	c.wmu.Unlock()
+	if !ok && !c.cfg.Watch {
+		var msg v2Message
+		if err := c.get(key, query, &msg); err != nil {
+			return nil, err
+		}
+		return msg.Node, nil
+	}
+	if !ok {
+		var err error
+		if w, err = c.watcher(key, query); err != nil {
+			return nil, err
+		}
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.node, w.err
+}
+
+func watchName(key string, query url.Values) string {
+	if query.Get("recursive") == "true" {
+		return key + "?recursive=true"
+	}
+	return key
+}
+
+// watcher returns running watcher of the key or starts new one after the initial read
+func (c *etcdClient) watcher(key string, query url.Values) (*watcher, error) {
+	name := watchName(key, query)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if w, ok := c.watchers[name]; ok {
+		return w, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &watcher{c: c, key: key, query: query, name: name, cancel: cancel, done: make(chan struct{})}
+	if err := w.refresh(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	c.watchers[name] = w
+	go w.run(ctx)
+	return w, nil
+}
+
+func (c *etcdClient) stopWatchers() {
+	c.wmu.Lock()
+	list := c.watchers
+	c.watchers = make(map[string]*watcher)
+	c.wmu.Unlock()
+	for _, w := range list {
+		w.cancel()
+		<-w.done
+	}
+}
+
+// refresh reads the key and stores it in the cache. Returns error only for failed requests,
+// missing key is cached as error
+func (w *watcher) refresh(ctx context.Context) error {
+	var msg v2Message
+	index, err := w.c.getWith(ctx, w.c.http, w.key, w.query, &msg)
+	if e, ok := err.(*v2Error); ok && e.ErrorCode == codeKeyNotFound {
+		w.update(nil, err, index)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if msg.Node != nil && msg.Node.ModifiedIndex > index {
+		index = msg.Node.ModifiedIndex
+	}
+	w.update(msg.Node, nil, index)
+	return nil
+}
+
+func (w *watcher) update(n *v2Node, err error, index uint64) {
+	var old, val string
+	w.mu.Lock()
+	first := !w.loaded
+	w.loaded = true
+	if w.node != nil {
+		old, _ = nodeValue(w.node, "")
+	}
+	if n != nil {
+		val, _ = nodeValue(n, "")
+	}
+	changed := (w.node == nil) != (n == nil) || old != val
+	w.node, w.err = n, err
+	if index > w.index {
+		w.index = index
+	}
+	w.mu.Unlock()
+	if changed && !first {
+		subs.Notify(w.name, val)
+	}
+}
+
+func (w *watcher) run(ctx context.Context) {
+	defer close(w.done)
+	b := watch.Backoff{Min: &WatchRetryMin, Max: &WatchRetryMax}
+	for ctx.Err() == nil {
+		if err := w.wait(ctx); err == nil {
+			b.Reset()
+			continue
+		}
+		if !b.Wait(ctx) {
+			return
+		}
+	}
+}
+
+// wait for the next change of the key and refresh the cache
+func (w *watcher) wait(ctx context.Context) error {
+	w.mu.RLock()
+	index := w.index
+	w.mu.RUnlock()
+
+	q := url.Values{}
+	q.Set("wait", "true")
+	q.Set("waitIndex", strconv.FormatUint(index+1, 10))
+	if w.query.Get("recursive") == "true" {
+		q.Set("recursive", "true")
+	}
+	wctx, cancel := context.WithTimeout(ctx, WatchTimeout)
+	defer cancel()
+	var msg v2Message
+	_, err := w.c.getWith(wctx, w.c.watch, w.key, q, &msg)
+	switch e, ok := err.(*v2Error); {
+	case err == nil:
+		// index is advanced only after refresh, failed refresh waits for the same change again
+		if err := w.refresh(ctx); err != nil {
+			return err
+		}
+		if msg.Node != nil {
+			w.mu.Lock()
+			if msg.Node.ModifiedIndex > w.index {
+				w.index = msg.Node.ModifiedIndex
+			}
+			w.mu.Unlock()
+		}
+		return nil
+	case ok && e.ErrorCode == codeEventIndexCleared:
+		// missed events: read the current state and wait from its index
+		return w.refresh(ctx)
+	case wctx.Err() == context.DeadlineExceeded && ctx.Err() == nil:
+		// long-poll timeout: nothing changed
+		return nil
+	}
+	return err
+}
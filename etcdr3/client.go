@@ -0,0 +1,282 @@
+package etcdr3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/tlsconf"
+	"github.com/pkg/errors"
+)
+
+// Config of the etcd v3 client
+type Config struct {
+	// Endpoints of the cluster members, e.g. "https://10.0.0.1:2379". Used round-robin on failure
+	Endpoints []string
+	// APIPrefix of the gRPC gateway: "/v3" for etcd 3.4+, "/v3beta" for 3.3
+	APIPrefix string
+	// Username and Password for etcd authentication. Empty Username disables auth
+	Username string
+	Password string
+	// CertFile and KeyFile client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// CAFile trusted CA bundle for server certificates
+	CAFile             string
+	InsecureSkipVerify bool
+	// Timeout of a single request
+	Timeout time.Duration
+	// Revision pins all reads to revision. Zero reads the latest one
+	Revision int64
+}
+
+//nolint:gochecknoglobals
+var defaultConfig = Config{
+	Endpoints: []string{"http://127.0.0.1:2379"},
+	APIPrefix: "/v3",
+	Timeout:   5 * time.Second,
+}
+
+// KeyValue from the range response
+type KeyValue struct {
+	Key            string
+	Value          string
+	CreateRevision int64
+	ModRevision    int64
+	Version        int64
+}
+
+// Client of the etcd v3 JSON gRPC gateway
+type Client struct {
+	cfg  Config
+	http *http.Client
+
+	mu    sync.Mutex
+	next  int // index of the endpoint to try first
+	token string
+}
+
+// NewClient returns client for the config
+func NewClient(cfg Config) (*Client, error) {
+	if len(cfg.Endpoints) == 0 {
+		cfg.Endpoints = defaultConfig.Endpoints
+	}
+	if cfg.APIPrefix == "" {
+		cfg.APIPrefix = defaultConfig.APIPrefix
+	}
+	cfg.APIPrefix = "/" + strings.Trim(cfg.APIPrefix, "/")
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultConfig.Timeout
+	}
+	endpoints := make([]string, 0, len(cfg.Endpoints))
+	for _, e := range cfg.Endpoints {
+		e = strings.TrimRight(strings.TrimSpace(e), "/")
+		if !strings.Contains(e, "://") {
+			e = "http://" + e
+		}
+		endpoints = append(endpoints, e)
+	}
+	cfg.Endpoints = endpoints
+	tlsCfg, err := tlsconf.Files{
+		CAFile:             cfg.CAFile,
+		CertFile:           cfg.CertFile,
+		KeyFile:            cfg.KeyFile,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}.Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		cfg: cfg,
+		http: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsCfg, Proxy: http.ProxyFromEnvironment},
+		},
+	}, nil
+}
+
+// Close idle connections of the client
+func (c *Client) Close() {
+	c.http.CloseIdleConnections()
+}
+
+type rangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+	Revision int64  `json:"revision,string,omitempty"`
+}
+
+type rangeResponse struct {
+	Header struct {
+		Revision int64 `json:"revision,string"`
+	} `json:"header"`
+	Kvs []struct {
+		Key            []byte `json:"key"`
+		Value          []byte `json:"value"`
+		CreateRevision int64  `json:"create_revision,string"`
+		ModRevision    int64  `json:"mod_revision,string"`
+		Version        int64  `json:"version,string"`
+	} `json:"kvs"`
+}
+
+type gatewayError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+// Range reads key, or all keys with the prefix when prefix is true, at revision (0 - latest)
+func (c *Client) Range(key string, prefix bool, revision int64) ([]KeyValue, error) {
+	req := rangeRequest{Key: []byte(key), Revision: revision}
+	if prefix {
+		req.RangeEnd = prefixEnd([]byte(key))
+	}
+	var rsp rangeResponse
+	if err := c.call("/kv/range", req, &rsp); err != nil {
+		return nil, err
+	}
+	res := make([]KeyValue, 0, len(rsp.Kvs))
+	for _, kv := range rsp.Kvs {
+		res = append(res, KeyValue{
+			Key:            string(kv.Key),
+			Value:          string(kv.Value),
+			CreateRevision: kv.CreateRevision,
+			ModRevision:    kv.ModRevision,
+			Version:        kv.Version,
+		})
+	}
+	return res, nil
+}
+
+// prefixEnd returns range end for all keys with the prefix
+func prefixEnd(key []byte) []byte {
+	end := append([]byte(nil), key...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// whole keyspace
+	return []byte{0}
+}
+
+// call gateway method on the first available endpoint
+func (c *Client) call(method string, req, rsp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	start := c.next
+	c.mu.Unlock()
+
+	var lastErr error
+	for i := 0; i < len(c.cfg.Endpoints); i++ {
+		n := (start + i) % len(c.cfg.Endpoints)
+		err := c.callEndpoint(c.cfg.Endpoints[n], method, body, rsp)
+		if err == nil {
+			c.mu.Lock()
+			c.next = n
+			c.mu.Unlock()
+			return nil
+		}
+		if e, ok := err.(*apiError); ok && e.status < http.StatusInternalServerError {
+			return err
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+// apiError etcd response error. Retried on other endpoints only for 5xx statuses
+type apiError struct {
+	status int
+	gatewayError
+}
+
+func (e *apiError) Error() string {
+	msg := e.gatewayError.Error
+	if msg == "" {
+		msg = e.Message
+	}
+	return fmt.Sprintf("etcd api v3 error (status %d, code %d): %s", e.status, e.Code, msg)
+}
+
+const codeUnauthenticated = 16
+
+func (c *Client) callEndpoint(endpoint, method string, body []byte, rsp interface{}) error {
+	token, err := c.authToken(endpoint, false)
+	if err != nil {
+		return err
+	}
+	err = c.post(endpoint, method, token, body, rsp)
+	if e, ok := err.(*apiError); ok && e.Code == codeUnauthenticated && c.cfg.Username != "" {
+		// token expired
+		if token, err = c.authToken(endpoint, true); err != nil {
+			return err
+		}
+		err = c.post(endpoint, method, token, body, rsp)
+	}
+	return err
+}
+
+func (c *Client) authToken(endpoint string, renew bool) (string, error) {
+	if c.cfg.Username == "" {
+		return "", nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && !renew {
+		return c.token, nil
+	}
+	body, err := json.Marshal(map[string]string{"name": c.cfg.Username, "password": c.cfg.Password})
+	if err != nil {
+		return "", err
+	}
+	var rsp struct {
+		Token string `json:"token"`
+	}
+	if err := c.post(endpoint, "/auth/authenticate", "", body, &rsp); err != nil {
+		return "", err
+	}
+	c.token = rsp.Token
+	return c.token, nil
+}
+
+func (c *Client) post(endpoint, method, token string, body []byte, rsp interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint+c.cfg.APIPrefix+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		e := &apiError{status: res.StatusCode}
+		if json.Unmarshal(data, &e.gatewayError) != nil || (e.gatewayError.Error == "" && e.Message == "") {
+			if res.StatusCode >= 500 || res.StatusCode == http.StatusNotFound {
+				// not an etcd gateway or member is unavailable: try the next endpoint
+				return errors.Errorf("invalid etcd api v3 status code: %d", res.StatusCode)
+			}
+			e.Message = string(data)
+		}
+		return e
+	}
+	return json.Unmarshal(data, rsp)
+}
@@ -0,0 +1,320 @@
+// Package etcd3test provides in-process fake of the etcd v3 JSON gRPC gateway for tests.
+//
+// Implemented subset: kv/range (with revisions and ranges), kv/put, kv/deleterange and auth/authenticate.
+package etcd3test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// gRPC status codes returned by the gateway
+const (
+	codeInvalidArgument = 3
+	codeNotFound        = 5
+	codeOutOfRange      = 11
+	codeUnavailable     = 14
+	codeUnauthenticated = 16
+)
+
+type event struct {
+	key            string
+	value          []byte
+	createRevision int64
+	modRevision    int64
+	version        int64
+	deleted        bool
+}
+
+// Server fake etcd member
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	revision int64
+	history  []event
+	users    map[string]string
+	tokens   map[string]bool
+	down     bool
+	requests int
+}
+
+// NewServer starts fake etcd server. Caller must Close it
+func NewServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSServer starts fake etcd server with TLS. Use Server.Client() or Server.Certificate() to trust it
+func NewTLSServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+// Start fake etcd server closed on test cleanup
+func Start(t testing.TB) *Server {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newServer() *Server {
+	return &Server{revision: 1, tokens: make(map[string]bool)}
+}
+
+// AddUser enables authentication and adds user
+func (s *Server) AddUser(name, password string) *Server {
+	s.mu.Lock()
+	if s.users == nil {
+		s.users = make(map[string]string)
+	}
+	s.users[name] = password
+	s.mu.Unlock()
+	return s
+}
+
+// RevokeTokens invalidates all issued auth tokens
+func (s *Server) RevokeTokens() *Server {
+	s.mu.Lock()
+	s.tokens = make(map[string]bool)
+	s.mu.Unlock()
+	return s
+}
+
+// SetDown makes member respond 503 Unavailable to all requests
+func (s *Server) SetDown(down bool) *Server {
+	s.mu.Lock()
+	s.down = down
+	s.mu.Unlock()
+	return s
+}
+
+// Requests returns count of handled requests
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Put stores value and returns new revision
+func (s *Server) Put(key, value string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(key, []byte(value))
+}
+
+// Delete key and returns new revision
+func (s *Server) Delete(key string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteRange([]byte(key), nil)
+	return s.revision
+}
+
+// Revision returns current revision
+func (s *Server) Revision() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revision
+}
+
+func (s *Server) put(key string, value []byte) int64 {
+	s.revision++
+	ev := event{key: key, value: value, createRevision: s.revision, modRevision: s.revision, version: 1}
+	if prev, ok := s.latest(key, s.revision); ok {
+		ev.createRevision = prev.createRevision
+		ev.version = prev.version + 1
+	}
+	s.history = append(s.history, ev)
+	return s.revision
+}
+
+func (s *Server) deleteRange(key, end []byte) int64 {
+	kvs := s.rangeAt(key, end, s.revision)
+	if len(kvs) == 0 {
+		return 0
+	}
+	s.revision++
+	for _, kv := range kvs {
+		s.history = append(s.history, event{key: kv.key, modRevision: s.revision, deleted: true})
+	}
+	return int64(len(kvs))
+}
+
+// latest returns the last event of the key at revision
+func (s *Server) latest(key string, rev int64) (event, bool) {
+	for i := len(s.history) - 1; i >= 0; i-- {
+		ev := s.history[i]
+		if ev.key == key && ev.modRevision <= rev {
+			return ev, !ev.deleted
+		}
+	}
+	return event{}, false
+}
+
+func (s *Server) rangeAt(key, end []byte, rev int64) []event {
+	keys := make(map[string]bool)
+	for _, ev := range s.history {
+		if inRange([]byte(ev.key), key, end) {
+			keys[ev.key] = true
+		}
+	}
+	res := make([]event, 0, len(keys))
+	for k := range keys {
+		if ev, ok := s.latest(k, rev); ok {
+			res = append(res, ev)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].key < res[j].key })
+	return res
+}
+
+func inRange(k, key, end []byte) bool {
+	switch {
+	case len(end) == 0:
+		return bytes.Equal(k, key)
+	case len(end) == 1 && end[0] == 0:
+		return bytes.Compare(k, key) >= 0
+	default:
+		return bytes.Compare(k, key) >= 0 && bytes.Compare(k, end) < 0
+	}
+}
+
+type rangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end"`
+	Revision int64  `json:"revision,string"`
+}
+
+type keyValue struct {
+	Key            []byte `json:"key"`
+	Value          []byte `json:"value,omitempty"`
+	CreateRevision int64  `json:"create_revision,string"`
+	ModRevision    int64  `json:"mod_revision,string"`
+	Version        int64  `json:"version,string"`
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if s.down {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "etcdserver: request timed out")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, codeNotFound, "method not allowed")
+		return
+	}
+	if r.URL.Path == "/v3/auth/authenticate" {
+		s.authenticate(w, r)
+		return
+	}
+	if s.users != nil && !s.tokens[r.Header.Get("Authorization")] {
+		writeError(w, http.StatusUnauthorized, codeUnauthenticated, "etcdserver: invalid auth token")
+		return
+	}
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		var req rangeRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		rev := req.Revision
+		if rev == 0 {
+			rev = s.revision
+		}
+		if rev > s.revision {
+			writeError(w, http.StatusBadRequest, codeOutOfRange, "etcdserver: mvcc: required revision is a future revision")
+			return
+		}
+		kvs := make([]keyValue, 0)
+		for _, ev := range s.rangeAt(req.Key, req.RangeEnd, rev) {
+			kvs = append(kvs, keyValue{
+				Key: []byte(ev.key), Value: ev.value,
+				CreateRevision: ev.createRevision, ModRevision: ev.modRevision, Version: ev.version,
+			})
+		}
+		rsp := map[string]interface{}{"header": s.header(), "count": strconv.Itoa(len(kvs))}
+		if len(kvs) > 0 {
+			rsp["kvs"] = kvs
+		}
+		writeJSON(w, http.StatusOK, rsp)
+	case "/v3/kv/put":
+		var req struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value"`
+		}
+		if !decode(w, r, &req) {
+			return
+		}
+		s.put(string(req.Key), req.Value)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"header": s.header()})
+	case "/v3/kv/deleterange":
+		var req rangeRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		n := s.deleteRange(req.Key, req.RangeEnd)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"header": s.header(), "deleted": strconv.FormatInt(n, 10)})
+	default:
+		writeError(w, http.StatusNotFound, codeNotFound, "Not Found")
+	}
+}
+
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if p, ok := s.users[req.Name]; !ok || p != req.Password {
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, "etcdserver: authentication failed, invalid user ID or password")
+		return
+	}
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	token := hex.EncodeToString(b)
+	s.tokens[token] = true
+	writeJSON(w, http.StatusOK, map[string]interface{}{"header": s.header(), "token": token})
+}
+
+func (s *Server) header() map[string]string {
+	return map[string]string{
+		"cluster_id": "1",
+		"member_id":  "1",
+		"revision":   strconv.FormatInt(s.revision, 10),
+		"raft_term":  "2",
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return false
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, status, code int, msg string) {
+	writeJSON(w, status, map[string]interface{}{"error": msg, "code": code, "message": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,109 @@
+// Package etcdr3 Noble etcd key/value API v3 reader
+package etcdr3
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/storeclient"
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoglobals
+var clients storeclient.Holder
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("etcd3", &KeyReader{})
+}
+
+// Init etcd v3 client. Default config (http://127.0.0.1:2379, no auth) used if cfg is nil
+// or Init was not called before the first read
+func Init(cfg *Config) error {
+	if cfg == nil {
+		c := defaultConfig
+		cfg = &c
+	}
+	c, err := NewClient(*cfg)
+	if err != nil {
+		return err
+	}
+	clients.Set(c)
+	return nil
+}
+
+func getClient() (*Client, error) {
+	c, err := clients.Get(func() error { return Init(nil) })
+	if err != nil {
+		return nil, err
+	}
+	return c.(*Client), nil
+}
+
+// KeyReader type implements noble.SecretStorage
+//
+// Key format: <key>[?rev=<revision>][&prefix]
+//
+//	etcd3:/app/db/password - value of the key
+//	etcd3:/app/db/password?rev=42 - value of the key at revision 42
+//	etcd3:/app/db/?prefix - JSON object of all keys with the prefix, relative to the prefix
+type KeyReader struct {
+}
+
+// Read key value from etcd API v3
+func (r *KeyReader) Read(key string) (string, error) {
+	c, err := getClient()
+	if err != nil {
+		return "", err
+	}
+	key, prefix, rev, err := parseKey(key)
+	if err != nil {
+		return "", err
+	}
+	if rev == 0 {
+		rev = c.cfg.Revision
+	}
+	kvs, err := c.Range(key, prefix, rev)
+	if err != nil {
+		return "", err
+	}
+	if !prefix {
+		if len(kvs) == 0 {
+			return "", errors.New("etcd key not found: " + key)
+		}
+		return kvs[0].Value, nil
+	}
+	m := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		m[strings.TrimPrefix(kv.Key, key)] = kv.Value
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+func parseKey(s string) (key string, prefix bool, rev int64, err error) {
+	i := strings.Index(s, "?")
+	if i < 0 {
+		return s, false, 0, nil
+	}
+	key = s[:i]
+	q, err := url.ParseQuery(s[i+1:])
+	if err != nil {
+		return "", false, 0, errors.Wrap(err, "incorrect key format. use <key>[?rev=<revision>][&prefix]")
+	}
+	_, prefix = q["prefix"]
+	if v := q.Get("rev"); v != "" {
+		if rev, err = strconv.ParseInt(v, 10, 64); err != nil || rev < 0 {
+			return "", false, 0, errors.New("incorrect revision: " + v)
+		}
+	}
+	return key, prefix, rev, nil
+}
+
+// Clone returns new empty instance of KeyReader
+func (r *KeyReader) Clone() noble.SecretStorage {
+	return &KeyReader{}
+}
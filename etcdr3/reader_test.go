@@ -0,0 +1,152 @@
+package etcdr3
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lancer-kit/noble/etcdr3/etcd3test"
+	"github.com/lancer-kit/noble/nobletest"
+)
+
+const (
+	testYaml = `
+secret: "etcd3:/messages/test"
+`
+	testJSON = `
+{
+"secret": "etcd3:/messages/test"
+}
+`
+)
+
+func initClient(t *testing.T, cfg Config) {
+	nobletest.InitClient(t, func() error { return Init(&cfg) }, clients.Reset)
+}
+
+func TestKeyReader_Read(t *testing.T) {
+	srv := etcd3test.Start(t)
+	first := srv.Put("/messages/test", "Hello")
+	srv.Put("/messages/test", "Hello world")
+	srv.Put("/messages/other", "Other")
+	srv.Put("/messagesx", "Outside")
+	initClient(t, Config{Endpoints: []string{srv.URL}})
+
+	r := KeyReader{}
+	v, err := r.Read("/messages/test")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello world", v)
+
+	v, err = r.Read("/messages/test?rev=" + strconv.FormatInt(first, 10))
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello", v)
+
+	v, err = r.Read("/messages/?prefix")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"test":"Hello world","other":"Other"}`, v)
+
+	_, err = r.Read("/messages/none")
+	assert.Error(t, err)
+	_, err = r.Read("/messages/test?rev=x")
+	assert.Error(t, err)
+	_, err = r.Read("/messages/test?rev=1000")
+	assert.Error(t, err)
+}
+
+func TestKeyReader_Config(t *testing.T) {
+	srv := etcd3test.Start(t)
+	srv.Put("/messages/test", "Hello")
+	initClient(t, Config{Endpoints: []string{srv.URL}})
+
+	var c nobletest.Config
+	assert.NoError(t, yaml.Unmarshal([]byte(testYaml), &c))
+	assert.NoError(t, c.Secret.InternalError())
+	assert.Equal(t, "Hello", c.Secret.Get())
+
+	c = nobletest.Config{}
+	assert.NoError(t, json.Unmarshal([]byte(testJSON), &c))
+	assert.NoError(t, c.Secret.InternalError())
+	srv.Put("/messages/test", "Changed")
+	assert.Equal(t, "Changed", c.Secret.Get())
+}
+
+func TestClient_Auth(t *testing.T) {
+	srv := etcd3test.Start(t)
+	srv.AddUser("root", "pass")
+	srv.Put("/key", "value")
+
+	c, err := NewClient(Config{Endpoints: []string{srv.URL}})
+	assert.NoError(t, err)
+	_, err = c.Range("/key", false, 0)
+	assert.Error(t, err)
+
+	c, err = NewClient(Config{Endpoints: []string{srv.URL}, Username: "root", Password: "wrong"})
+	assert.NoError(t, err)
+	_, err = c.Range("/key", false, 0)
+	assert.Error(t, err)
+
+	c, err = NewClient(Config{Endpoints: []string{srv.URL}, Username: "root", Password: "pass"})
+	assert.NoError(t, err)
+	kvs, err := c.Range("/key", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", kvs[0].Value)
+	srv.RevokeTokens()
+	kvs, err = c.Range("/key", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", kvs[0].Value)
+}
+
+func TestClient_Failover(t *testing.T) {
+	a, b := etcd3test.Start(t), etcd3test.Start(t)
+	a.Put("/key", "a")
+	b.Put("/key", "b")
+	a.SetDown(true)
+
+	c, err := NewClient(Config{Endpoints: []string{a.URL, "127.0.0.1:1", b.URL}})
+	assert.NoError(t, err)
+	kvs, err := c.Range("/key", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", kvs[0].Value)
+	// last good endpoint is used first
+	_, _ = c.Range("/key", false, 0)
+	assert.Equal(t, 1, a.Requests())
+	assert.Equal(t, 2, b.Requests())
+
+	b.SetDown(true)
+	_, err = c.Range("/key", false, 0)
+	assert.Error(t, err)
+}
+
+func TestClient_TLS(t *testing.T) {
+	srv := etcd3test.NewTLSServer()
+	defer srv.Close()
+	srv.Put("/key", "tls")
+
+	dir, err := ioutil.TempDir("", "etcdr3")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	ca := filepath.Join(dir, "ca.pem")
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	assert.NoError(t, ioutil.WriteFile(ca, pemData, 0600))
+
+	c, err := NewClient(Config{Endpoints: []string{srv.URL}})
+	assert.NoError(t, err)
+	_, err = c.Range("/key", false, 0)
+	assert.Error(t, err)
+
+	c, err = NewClient(Config{Endpoints: []string{srv.URL}, CAFile: ca})
+	assert.NoError(t, err)
+	kvs, err := c.Range("/key", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "tls", kvs[0].Value)
+
+	_, err = NewClient(Config{CAFile: filepath.Join(dir, "none.pem")})
+	assert.Error(t, err)
+}
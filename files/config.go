@@ -0,0 +1,257 @@
+package files
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/jsonpath"
+	"gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// Config of the file reader
+type Config struct {
+	// BaseDir of the relative paths. Working directory if empty
+	BaseDir string
+	// Strict refuses files accessible by group or others or owned by another user (except root),
+	// like OpenSSH does for private keys
+	Strict bool
+}
+
+//nolint:gochecknoglobals
+var (
+	cfgMu  sync.RWMutex
+	config Config
+)
+
+// Init file reader. Nil cfg restores defaults. Affects secrets parsed after the call
+func Init(cfg *Config) error {
+	c := Config{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.BaseDir != "" {
+		dir, err := filepath.Abs(c.BaseDir)
+		if err != nil {
+			return err
+		}
+		c.BaseDir = dir
+	}
+	cfgMu.Lock()
+	config = c
+	cfgMu.Unlock()
+	return nil
+}
+
+// current config of the new readers
+func current() Config {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return config
+}
+
+// Load config file into v: JSON for ".json" extension, YAML otherwise.
+// Relative paths of the file storages in values decoded into noble.Secret (including templates)
+// are resolved against the directory of the config file. Other values are decoded as is
+func Load(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return loadJSON(data, dir, v)
+	}
+	return loadYAML(data, dir, v)
+}
+
+//nolint:gochecknoglobals
+var secretType = reflect.TypeOf(noble.Secret{})
+
+func loadJSON(data []byte, dir string, v interface{}) error {
+	doc, err := jsonpath.Decode(data)
+	if err != nil {
+		return err
+	}
+	if data, err = json.Marshal(absJSON(doc, reflect.TypeOf(v), dir)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// absJSON resolves secrets of the document decoded by encoding/json into t
+func absJSON(v interface{}, t reflect.Type, dir string) interface{} {
+	t = deref(t)
+	if t == nil {
+		return v
+	}
+	switch val := v.(type) {
+	case string:
+		if t == secretType {
+			return absSecret(val, dir)
+		}
+	case map[string]interface{}:
+		switch t.Kind() {
+		case reflect.Map:
+			for k, e := range val {
+				val[k] = absJSON(e, t.Elem(), dir)
+			}
+		case reflect.Struct:
+			fields := make(map[string]reflect.Type)
+			structFields(t, "json", fields)
+			for k, e := range val {
+				if f, ok := fields[strings.ToLower(k)]; ok {
+					val[k] = absJSON(e, f, dir)
+				}
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, e := range val {
+				val[i] = absJSON(e, t.Elem(), dir)
+			}
+		}
+	}
+	return v
+}
+
+// loadYAML rewrites document nodes, keeping tags and styles of the source for the decoder
+func loadYAML(data []byte, dir string, v interface{}) error {
+	var doc yaml3.Node
+	if err := yaml3.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.Kind == 0 {
+		// empty document
+		return yaml.Unmarshal(data, v)
+	}
+	absYAML(&doc, reflect.TypeOf(v), dir)
+	data, err := yaml3.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+// absYAML resolves secrets of the node decoded by yaml.v2 into t
+func absYAML(n *yaml3.Node, t reflect.Type, dir string) {
+	t = deref(t)
+	if t == nil {
+		return
+	}
+	switch n.Kind {
+	case yaml3.DocumentNode:
+		for _, c := range n.Content {
+			absYAML(c, t, dir)
+		}
+	case yaml3.SequenceNode:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for _, c := range n.Content {
+				absYAML(c, t.Elem(), dir)
+			}
+		}
+	case yaml3.MappingNode:
+		switch t.Kind() {
+		case reflect.Map:
+			for i := 1; i < len(n.Content); i += 2 {
+				absYAML(n.Content[i], t.Elem(), dir)
+			}
+		case reflect.Struct:
+			fields := make(map[string]reflect.Type)
+			structFields(t, "yaml", fields)
+			for i := 1; i < len(n.Content); i += 2 {
+				if f, ok := fields[n.Content[i-1].Value]; ok {
+					absYAML(n.Content[i], f, dir)
+				}
+			}
+		}
+	case yaml3.ScalarNode:
+		if t == secretType && n.Tag == "!!str" {
+			n.Value = absSecret(n.Value, dir)
+		}
+	}
+}
+
+func deref(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// structFields collects types of the fields of struct t by names in the document.
+// JSON names are lower case as encoding/json matches them case-insensitively,
+// YAML names default to lower case field names as in yaml.v2.
+// Fields of the embedded (inline) structs are added after own fields of t
+func structFields(t reflect.Type, tag string, fields map[string]reflect.Type) {
+	var inline []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		opts := strings.Split(f.Tag.Get(tag), ",")
+		name := opts[0]
+		if name == "-" && len(opts) == 1 {
+			continue
+		}
+		ft := deref(f.Type)
+		if ft.Kind() == reflect.Struct && (tag == "yaml" && len(opts) > 1 && opts[1] == "inline" ||
+			tag == "json" && f.Anonymous && name == "") {
+			inline = append(inline, ft)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		if tag == "json" {
+			name = strings.ToLower(name)
+		}
+		if _, ok := fields[name]; !ok {
+			fields[name] = f.Type
+		}
+	}
+	for _, it := range inline {
+		structFields(it, tag, fields)
+	}
+}
+
+//nolint:gochecknoglobals
+var templateRe = regexp.MustCompile(`{{([^{}]*)}}`)
+
+// absSecret resolves relative paths of the file storages in the secret source against dir
+func absSecret(s, dir string) string {
+	if !strings.Contains(s, "{{") {
+		return absRef(s, dir)
+	}
+	return templateRe.ReplaceAllStringFunc(s, func(m string) string {
+		return "{{" + absRef(m[2:len(m)-2], dir) + "}}"
+	})
+}
+
+func absRef(ref, dir string) string {
+	i := strings.Index(ref, ":")
+	if i < 0 {
+		return ref
+	}
+	switch ref[:i] {
+	case "file", "jsonfile", "yamlfile", "tomlfile":
+	default:
+		return ref
+	}
+	path := ref[i+1:]
+	if path == "" || filepath.IsAbs(path) {
+		return ref
+	}
+	// not cleaned: selectors and options may contain slashes
+	return ref[:i+1] + dir + string(filepath.Separator) + path
+}
@@ -2,6 +2,10 @@ package files
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -49,3 +53,256 @@ func TestFileReaderJson(t *testing.T) {
 	}
 	println("value:", c.Secret.Get())
 }
+
+func writeFile(t *testing.T, dir, name, data string) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(data), 0600))
+	return path
+}
+
+func TestReader_Modes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	noNewline := writeFile(t, dir, "token", "secret")
+	lines := writeFile(t, dir, "lines", "first\r\nsecond\n  third  \n")
+	pem := "-----BEGIN KEY-----\nAAAA\n-----END KEY-----\n"
+	key := writeFile(t, dir, "key.pem", pem)
+
+	r := Reader{}
+	for _, tt := range []struct {
+		path string
+		want string
+	}{
+		{noNewline, "secret"},
+		{lines, "first"},
+		{lines + "?line=2", "second"},
+		{lines + "?line=3", "  third  "},
+		{lines + "?line=3&trim=space", "third"},
+		{lines + "?line=2&trim=none", "second\n"},
+		{key + "?mode=all", strings.TrimRight(pem, "\n")},
+		{key + "?mode=all&trim=none", pem},
+		{noNewline + "?mode=all", "secret"},
+	} {
+		v, err := r.Read(tt.path)
+		assert.NoError(t, err, tt.path)
+		assert.Equal(t, tt.want, v, tt.path)
+	}
+
+	for _, path := range []string{
+		lines + "?line=4", lines + "?line=0", lines + "?mode=bin", lines + "?trim=x", lines + "?mode=all&line=2",
+		filepath.Join(dir, "none"),
+	} {
+		_, err := r.Read(path)
+		assert.Error(t, err, path)
+	}
+	empty := writeFile(t, dir, "empty", "")
+	_, err = r.Read(empty)
+	assert.Error(t, err)
+
+	// unknown query is part of the file name
+	v, err := r.Read(writeFile(t, dir, "name?x=1", "q"))
+	assert.NoError(t, err)
+	assert.Equal(t, "q", v)
+
+	prev := MaxSize
+	MaxSize = 8
+	defer func() { MaxSize = prev }()
+	_, err = r.Read(key + "?mode=all")
+	assert.Error(t, err)
+	_, err = r.Read(key)
+	assert.Error(t, err)
+	v, err = r.Read(noNewline)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "secrets"), 0700))
+	writeFile(t, dir, "secrets/password", "secret\n")
+	yml := writeFile(t, dir, "config.yaml", `secret: "file:secrets/password"`)
+	jsn := writeFile(t, dir, "config.json", `{"secret": "file:./secrets/password"}`)
+
+	for _, path := range []string{yml, jsn} {
+		var c testConfig
+		assert.NoError(t, Load(path, &c))
+		assert.NoError(t, c.Secret.InternalError(), path)
+		assert.Equal(t, "secret", c.Secret.Get(), path)
+	}
+
+	writeFile(t, dir, "secrets/db.json", `{"user":"admin"}`)
+	type config struct {
+		DSN   noble.Secret `yaml:"dsn" json:"dsn"`
+		User  noble.Secret `yaml:"user" json:"user"`
+		Plain string       `yaml:"plain" json:"plain"`
+		Port  int          `yaml:"port" json:"port"`
+	}
+	yml = writeFile(t, dir, "all.yaml", `
+dsn: "postgres://{{jsonfile:secrets/db.json#user}}:{{file:secrets/password}}@db"
+user: jsonfile:secrets/db.json#user
+plain: text
+port: 5432
+`)
+	jsn = writeFile(t, dir, "all.json",
+		`{"dsn":"postgres://{{jsonfile:secrets/db.json#user}}:{{file:secrets/password}}@db","user":"jsonfile:secrets/db.json#user","plain":"text","port":5432}`)
+	for _, path := range []string{yml, jsn} {
+		var all config
+		assert.NoError(t, Load(path, &all), path)
+		assert.Equal(t, "postgres://admin:secret@db", all.DSN.Get(), path)
+		assert.Equal(t, "admin", all.User.Get(), path)
+		assert.Equal(t, "text", all.Plain, path)
+		assert.Equal(t, 5432, all.Port, path)
+	}
+	assert.Error(t, Load(writeFile(t, dir, "bad.json", `{"secret": "file:secrets/password"} {}`), &config{}))
+
+	// only values decoded into noble.Secret are resolved
+	type keys struct {
+		Cache string                  `yaml:"cache" json:"cache"`
+		Keys  map[string]noble.Secret `yaml:"keys" json:"keys"`
+	}
+	type mixed struct {
+		keys   `yaml:",inline"`
+		DB     string          `yaml:"db" json:"db"`
+		Args   []string        `yaml:"args" json:"args"`
+		Backup []*noble.Secret `yaml:"backup" json:"backup"`
+	}
+	yml = writeFile(t, dir, "mixed.yaml", `
+db: "file:test.db?cache=shared"
+args: ["file:secrets/password"]
+cache: file:cache.db
+keys: {api: "file:secrets/password"}
+backup: ["file:secrets/password"]
+`)
+	jsn = writeFile(t, dir, "mixed.json", `{"db":"file:test.db?cache=shared","args":["file:secrets/password"],`+
+		`"cache":"file:cache.db","keys":{"api":"file:secrets/password"},"backup":["file:secrets/password"]}`)
+	for _, path := range []string{yml, jsn} {
+		var m mixed
+		assert.NoError(t, Load(path, &m), path)
+		assert.Equal(t, "file:test.db?cache=shared", m.DB, path)
+		assert.Equal(t, []string{"file:secrets/password"}, m.Args, path)
+		assert.Equal(t, "file:cache.db", m.Cache, path)
+		api := m.Keys["api"]
+		assert.Equal(t, "secret", api.Get(), path)
+		if assert.Len(t, m.Backup, 1, path) {
+			assert.Equal(t, "secret", m.Backup[0].Get(), path)
+		}
+	}
+
+	// secrets parsed outside of Load are not affected
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			var c testConfig
+			assert.NoError(t, yaml.Unmarshal([]byte(`secret: "file:secrets/password"`), &c))
+			assert.Error(t, c.Secret.InternalError())
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		var c testConfig
+		assert.NoError(t, Load(yml, &c))
+	}
+	<-done
+
+	// Init sets base dir of the secrets parsed later
+	defer func() { _ = Init(nil) }()
+	assert.NoError(t, Init(&Config{BaseDir: dir}))
+	s := noble.Secret{}.New("file:secrets/password")
+	assert.Equal(t, "secret", s.Get())
+	assert.NoError(t, Init(nil))
+	assert.Equal(t, "secret", s.Get(), "parsed secret keeps base dir")
+}
+
+func TestConfig_Strict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	private := writeFile(t, dir, "private", "secret")
+	open := writeFile(t, dir, "open", "secret")
+	assert.NoError(t, os.Chmod(open, 0644))
+
+	defer func() { _ = Init(nil) }()
+	assert.NoError(t, Init(&Config{Strict: true}))
+	r := (&Reader{}).Clone()
+	v, err := r.Read(private)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+	_, err = r.Read(open)
+	assert.Error(t, err)
+}
+
+func TestStructReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	jsonFile := writeFile(t, dir, "secrets.json",
+		`{"db":{"password":"json-pass","port":5432,"id":123456789012},"hosts":[{"name":"a"},{"name":"b"}],"key.with.dots":"k"}`)
+	yamlFile := writeFile(t, dir, "secrets.yaml", `
+db:
+  password: yaml-pass
+  port: 5432
+hosts:
+  - name: a
+  - name: b
+`)
+	tomlFile := writeFile(t, dir, "secrets.toml", `
+rotated = 2026-01-02T15:04:05Z
+
+[db]
+password = "toml-pass"
+port = 5432
+id = 123456789012
+
+[[hosts]]
+name = "a"
+
+[[hosts]]
+name = "b"
+`)
+
+	for _, tt := range []struct {
+		key  string
+		want string
+	}{
+		{"jsonfile:" + jsonFile + "#db.password", "json-pass"},
+		{"jsonfile:" + jsonFile + "#$.hosts[1].name", "b"},
+		{"jsonfile:" + jsonFile + "#['key.with.dots']", "k"},
+		{"jsonfile:" + jsonFile + "#db.port", "5432"},
+		{"jsonfile:" + jsonFile + "#db.id", "123456789012"},
+		{"yamlfile:" + yamlFile + "#db.password", "yaml-pass"},
+		{"yamlfile:" + yamlFile + "#hosts.0.name", "a"},
+		{"tomlfile:" + tomlFile + "#db.password", "toml-pass"},
+		{"tomlfile:" + tomlFile + "#hosts[1].name", "b"},
+		{"tomlfile:" + tomlFile + "#db.id", "123456789012"},
+		{"tomlfile:" + tomlFile + "#rotated", "2026-01-02T15:04:05Z"},
+	} {
+		s := noble.Secret{}.New(tt.key)
+		assert.NoError(t, s.InternalError(), tt.key)
+		assert.Equal(t, tt.want, s.Get(), tt.key)
+	}
+	s := noble.Secret{}.New("yamlfile:" + yamlFile + "#db")
+	m, err := s.Map()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"password": "yaml-pass", "port": 5432.0}, m)
+
+	for _, key := range []string{
+		"jsonfile:" + jsonFile + "#db.none", "yamlfile:" + yamlFile + "#hosts[5]",
+		"tomlfile:" + jsonFile + "#db", "jsonfile:" + filepath.Join(dir, "none.json") + "#db",
+	} {
+		s := noble.Secret{}.New(key)
+		assert.Error(t, s.InternalError(), key)
+	}
+
+	// cached until modified
+	r := (&StructReader{format: FormatJSON}).Clone()
+	v, err := r.Read(jsonFile + "#db.password")
+	assert.NoError(t, err)
+	assert.Equal(t, "json-pass", v)
+	writeFile(t, dir, "secrets.json", `{"db":{"password":"rotated"}}`)
+	v, err = r.Read(jsonFile + "#db.password")
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated", v)
+}
@@ -2,39 +2,200 @@ package files
 
 import (
 	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/fileperm"
+	"github.com/pkg/errors"
+)
+
+// MaxSize of the read file in bytes
+//
+//nolint:gochecknoglobals
+var MaxSize int64 = 1 << 20
+
+// Read modes
+const (
+	// ModeLine reads one line, the first by default
+	ModeLine = "line"
+	// ModeAll reads whole file
+	ModeAll = "all"
+)
+
+// Trim modes
+const (
+	// TrimNewline removes line ending (trailing line endings in ModeAll). Default
+	TrimNewline = "newline"
+	// TrimSpace removes leading and trailing white space
+	TrimSpace = "space"
+	// TrimNone keeps value as is
+	TrimNone = "none"
 )
 
 func init() {
 	noble.Register("file", &Reader{})
+	noble.Register("jsonfile", &StructReader{format: FormatJSON})
+	noble.Register("yamlfile", &StructReader{format: FormatYAML})
+	noble.Register("tomlfile", &StructReader{format: FormatTOML})
 }
 
 // Reader object. Read data from file
+//
+// Key format: <path>[?mode=line|all][&line=<n>][&trim=newline|space|none]
+//
+//	file:/etc/app/password - first line without line ending
+//	file:/etc/app/key.pem?mode=all - whole file without trailing line endings
+//	file:/etc/app/token?mode=all&trim=none - exact content
+//	file:/etc/app/passwords?line=3 - third line
+//
+// Relative paths are resolved against Config.BaseDir or the directory of the file loaded by Load
 type Reader struct {
 	fileName string
+	cfg      *Config
+}
+
+type options struct {
+	mode string
+	line int
+	trim string
 }
 
 // Read file
 func (r *Reader) Read(fileName string) (string, error) {
+	fileName, opts, err := parsePath(fileName)
+	if err != nil {
+		return "", err
+	}
+	f, err := open(r.cfg, fileName)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	fileName = f.Name()
 	r.fileName = fileName
+
+	rdr := io.LimitReader(f, MaxSize+1)
+	if opts.mode == ModeAll {
+		data, err := ioutil.ReadAll(rdr)
+		if err != nil {
+			return "", err
+		}
+		if int64(len(data)) > MaxSize {
+			return "", fmt.Errorf("file %s is larger than %d bytes", fileName, MaxSize)
+		}
+		return trim(string(data), opts.trim), nil
+	}
+
+	br := bufio.NewReader(rdr)
+	var read int64
+	for n := 1; ; n++ {
+		res, err := br.ReadString('\n')
+		read += int64(len(res))
+		if read > MaxSize {
+			return "", fmt.Errorf("file %s is larger than %d bytes", fileName, MaxSize)
+		}
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if n == opts.line {
+			if err == io.EOF && res == "" {
+				break
+			}
+			return trim(res, opts.trim), nil
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return "", fmt.Errorf("file %s has no line %d", fileName, opts.line)
+}
+
+// open file relative to the BaseDir of cfg (current config if nil) and check its permissions in strict mode
+func open(cfg *Config, fileName string) (*os.File, error) {
+	if cfg == nil {
+		c := current()
+		cfg = &c
+	}
+	if cfg.BaseDir != "" && !filepath.IsAbs(fileName) {
+		fileName = filepath.Join(cfg.BaseDir, fileName)
+	}
 	f, err := os.Open(fileName)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	if cfg.Strict {
+		fi, err := f.Stat()
+		if err == nil {
+			err = fileperm.Check(fi, false)
+		}
+		if err != nil {
+			_ = f.Close()
+			return nil, errors.Wrap(err, "file "+fileName)
+		}
+	}
+	return f, nil
+}
 
-	rdr := bufio.NewReader(f)
-	res, err := rdr.ReadString([]byte("\n")[0])
+// parsePath splits path and read options. Query with unknown parameters is considered part of the path
+func parsePath(s string) (string, options, error) {
+	opts := options{mode: ModeLine, line: 1, trim: TrimNewline}
+	i := strings.LastIndex(s, "?")
+	if i < 0 {
+		return s, opts, nil
+	}
+	q, err := url.ParseQuery(s[i+1:])
 	if err != nil {
-		return "", err
+		return s, opts, nil
+	}
+	for k := range q {
+		if k != "mode" && k != "line" && k != "trim" {
+			return s, opts, nil
+		}
+	}
+	if m := q.Get("mode"); m != "" {
+		if m != ModeLine && m != ModeAll {
+			return "", opts, errors.New("unknown file mode: " + m)
+		}
+		opts.mode = m
 	}
+	if l := q.Get("line"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			return "", opts, errors.New("invalid file line: " + l)
+		}
+		if opts.mode == ModeAll {
+			return "", opts, errors.New("line can not be used with mode=all")
+		}
+		opts.line = n
+	}
+	if t := q.Get("trim"); t != "" {
+		if t != TrimNewline && t != TrimSpace && t != TrimNone {
+			return "", opts, errors.New("unknown file trim: " + t)
+		}
+		opts.trim = t
+	}
+	return s[:i], opts, nil
+}
 
-	return strings.Replace(res, "\n", "", -1), nil
+func trim(s, mode string) string {
+	switch mode {
+	case TrimNone:
+		return s
+	case TrimSpace:
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimRight(s, "\r\n")
 }
 
-// Clone returns new empty instance of Reader
+// Clone returns new empty instance of Reader with the current config
 func (r Reader) Clone() noble.SecretStorage {
-	return &Reader{}
+	c := current()
+	return &Reader{cfg: &c}
 }
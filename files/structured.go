@@ -0,0 +1,142 @@
+package files
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/jsonpath"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Formats of the structured files
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// StructReader reads values of the structured (JSON, YAML or TOML) files
+//
+// Key format: <path>[#<selector>]
+//
+//	jsonfile:/etc/app/secrets.json#db.password - value at dotted path
+//	yamlfile:secrets.yaml#$.hosts[0].password - JSONPath-like selector with array index
+//	tomlfile:secrets.toml#db - nested object as JSON
+//	jsonfile:secrets.json - whole document as JSON
+//
+// Parsed files are cached until modification time or size changes
+type StructReader struct {
+	format string
+	cfg    *Config
+}
+
+// Read value of the structured file
+func (r *StructReader) Read(key string) (string, error) {
+	path, selector := key, ""
+	if i := strings.LastIndex(key, "#"); i >= 0 {
+		path, selector = key[:i], key[i+1:]
+	}
+	if path == "" {
+		return "", errors.New("incorrect key format. use <path>[#<selector>]")
+	}
+	doc, err := r.load(path)
+	if err != nil {
+		return "", err
+	}
+	if selector == "" {
+		return jsonpath.String(doc)
+	}
+	val, err := jsonpath.SelectString(doc, selector)
+	return val, errors.Wrap(err, r.format+" file "+path)
+}
+
+// Clone returns new instance of StructReader with the current config
+func (r StructReader) Clone() noble.SecretStorage {
+	c := current()
+	return &StructReader{format: r.format, cfg: &c}
+}
+
+type document struct {
+	modTime time.Time
+	size    int64
+	doc     interface{}
+}
+
+//nolint:gochecknoglobals
+var (
+	docsMu sync.Mutex
+	docs   = make(map[string]*document)
+)
+
+// load parsed document from the cache, parsing the file again if modification time or size changed
+func (r *StructReader) load(path string) (interface{}, error) {
+	f, err := open(r.cfg, path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	id := r.format + ":" + abs
+	docsMu.Lock()
+	d, ok := docs[id]
+	docsMu.Unlock()
+	if ok && d.modTime.Equal(fi.ModTime()) && d.size == fi.Size() {
+		return d.doc, nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(f, MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > MaxSize {
+		return nil, fmt.Errorf("file %s is larger than %d bytes", f.Name(), MaxSize)
+	}
+	doc, err := decode(r.format, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse "+r.format+" file "+f.Name())
+	}
+	docsMu.Lock()
+	docs[id] = &document{modTime: fi.ModTime(), size: fi.Size(), doc: doc}
+	docsMu.Unlock()
+	return doc, nil
+}
+
+func decode(format string, data []byte) (interface{}, error) {
+	var doc interface{}
+	var err error
+	switch format {
+	case FormatJSON:
+		doc, err = jsonpath.Decode(data)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &doc)
+	case FormatTOML:
+		// arrays of tables and datetimes are normalized to JSON types
+		var m map[string]interface{}
+		if err = toml.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		var b []byte
+		if b, err = json.Marshal(m); err != nil {
+			return nil, err
+		}
+		doc, err = jsonpath.Decode(b)
+	default:
+		err = errors.New("unknown format " + format)
+	}
+	return doc, err
+}
@@ -0,0 +1,162 @@
+package gcpsm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type smClient struct {
+	cfg    Config
+	http   *http.Client
+	tokens TokenSource
+
+	mu      sync.Mutex
+	project string
+}
+
+// apiError error response of the Google API
+type apiError struct {
+	Code    int    `json:"code"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("secret manager api error (%d %s): %s", e.Code, e.Status, e.Message)
+}
+
+//nolint:gochecknoglobals
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+func newClient(cfg Config) (*smClient, error) {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	c := &smClient{
+		cfg:     cfg,
+		http:    &http.Client{Timeout: cfg.Timeout, Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}},
+		project: cfg.Project,
+	}
+	if cfg.WithoutAuthentication {
+		return c, nil
+	}
+	var (
+		tokens  = cfg.TokenSource
+		project string
+		err     error
+	)
+	switch {
+	case tokens != nil:
+	case cfg.CredentialsJSON != nil:
+		tokens, project, err = CredentialsFromJSON(cfg.CredentialsJSON)
+	case cfg.CredentialsFile != "":
+		var data []byte
+		if data, err = ioutil.ReadFile(cfg.CredentialsFile); err == nil {
+			tokens, project, err = CredentialsFromJSON(data)
+		}
+	default:
+		tokens, project, err = DefaultCredentials()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.project == "" {
+		c.project = project
+	}
+	c.tokens = &cachedToken{TokenSource: tokens}
+	return c, nil
+}
+
+// Close closes idle connections of the client
+func (c *smClient) Close() {
+	c.http.CloseIdleConnections()
+}
+
+// defaultProject returns project of the config or of the credentials
+func (c *smClient) defaultProject(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.project != "" {
+		return c.project, nil
+	}
+	if ct, ok := c.tokens.(*cachedToken); ok {
+		if m, ok := ct.TokenSource.(MetadataTokenSource); ok {
+			p, err := m.Project(ctx)
+			if err != nil {
+				return "", err
+			}
+			c.project = p
+			return p, nil
+		}
+	}
+	return "", errors.New("gcp project is not set")
+}
+
+// access secret version and verify checksum of the payload
+func (c *smClient) access(ctx context.Context, name, location string) ([]byte, error) {
+	endpoint := c.cfg.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+		if location != "" {
+			endpoint = "https://secretmanager." + location + ".rep.googleapis.com"
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet, endpoint+"/v1/"+name+":access", nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.tokens != nil {
+		t, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+t.AccessToken)
+	}
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error apiError `json:"error"`
+		}
+		if json.Unmarshal(body, &e) != nil || e.Error.Code == 0 {
+			e.Error = apiError{Code: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+		}
+		return nil, &e.Error
+	}
+	var out struct {
+		Payload struct {
+			Data       []byte          `json:"data"`
+			DataCrc32c json.RawMessage `json:"dataCrc32c"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, errors.Wrap(err, "invalid secret manager response")
+	}
+	if sum := strings.Trim(string(out.Payload.DataCrc32c), `"`); sum != "" && sum != "null" {
+		expected, err := strconv.ParseUint(sum, 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid payload checksum")
+		}
+		if uint64(crc32.Checksum(out.Payload.Data, crc32c)) != expected {
+			return nil, errors.New("payload checksum mismatch")
+		}
+	}
+	return out.Payload.Data, nil
+}
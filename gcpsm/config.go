@@ -0,0 +1,82 @@
+package gcpsm
+
+import (
+	"os"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/storeclient"
+)
+
+// Config of the Secret Manager client
+type Config struct {
+	// Project of the secrets with short names, e.g. "db-password". Project of the credentials if empty
+	Project string
+	// Endpoint of the API, e.g. "http://localhost:8080" for an emulator. DefaultEndpoint if empty.
+	// Regional secrets use the regional endpoint unless Endpoint is set
+	Endpoint string
+	// CredentialsFile with the service account key or the authorized user credentials
+	CredentialsFile string
+	// CredentialsJSON content of the credentials file
+	CredentialsJSON []byte
+	// TokenSource of the access tokens. Application default credentials are used if all credentials are empty
+	TokenSource TokenSource
+	// WithoutAuthentication sends requests without access token, e.g. to an emulator
+	WithoutAuthentication bool
+	// Timeout of a single request
+	Timeout time.Duration
+}
+
+// Defaults of the config
+const (
+	DefaultEndpoint = "https://secretmanager.googleapis.com"
+	DefaultTimeout  = 10 * time.Second
+)
+
+// EmulatorHostEnv environment variable with host:port of the Secret Manager emulator
+const EmulatorHostEnv = "SECRET_MANAGER_EMULATOR_HOST"
+
+//nolint:gochecknoglobals
+var clients storeclient.Holder
+
+// Init Secret Manager client. Config from the environment is used if cfg is nil
+// or Init was not called before the first read
+func Init(cfg *Config) error {
+	if cfg == nil {
+		c := ConfigFromEnv()
+		cfg = &c
+	}
+	c, err := newClient(*cfg)
+	if err != nil {
+		return err
+	}
+	clients.Set(c)
+	return nil
+}
+
+func getClient() (*smClient, error) {
+	c, err := clients.Get(func() error { return Init(nil) })
+	if err != nil {
+		return nil, err
+	}
+	return c.(*smClient), nil
+}
+
+// ConfigFromEnv returns config from the environment variables:
+//
+//	GOOGLE_CLOUD_PROJECT or GCLOUD_PROJECT - project of the short secret names
+//	SECRET_MANAGER_EMULATOR_HOST - host:port of the emulator, requests are sent without authentication
+//	GOOGLE_APPLICATION_CREDENTIALS - credentials file, see DefaultCredentials
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Project: os.Getenv("GOOGLE_CLOUD_PROJECT"),
+		Timeout: DefaultTimeout,
+	}
+	if cfg.Project == "" {
+		cfg.Project = os.Getenv("GCLOUD_PROJECT")
+	}
+	if host := os.Getenv(EmulatorHostEnv); host != "" {
+		cfg.Endpoint = "http://" + host
+		cfg.WithoutAuthentication = true
+	}
+	return cfg
+}
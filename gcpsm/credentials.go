@@ -0,0 +1,300 @@
+package gcpsm
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Scope of the access tokens
+const Scope = "https://www.googleapis.com/auth/cloud-platform"
+
+// DefaultTokenURI of the Google OAuth 2.0 server
+const DefaultTokenURI = "https://oauth2.googleapis.com/token"
+
+// DefaultMetadataHost of the Compute Engine metadata server
+const DefaultMetadataHost = "metadata.google.internal"
+
+// expiryWindow tokens are refreshed before they expire
+const expiryWindow = time.Minute
+
+// Token OAuth 2.0 access token
+type Token struct {
+	AccessToken string
+	// Expiry time of the token, zero if token does not expire
+	Expiry time.Time
+}
+
+// TokenSource of the access tokens
+type TokenSource interface {
+	Token(ctx context.Context) (Token, error)
+}
+
+// StaticToken returns fixed access token
+type StaticToken string
+
+// Token implements TokenSource
+func (t StaticToken) Token(context.Context) (Token, error) {
+	return Token{AccessToken: string(t)}, nil
+}
+
+// credentialsFile content of the service account key or of the authorized user (gcloud) credentials
+type credentialsFile struct {
+	Type           string `json:"type"`
+	ProjectID      string `json:"project_id"`
+	QuotaProjectID string `json:"quota_project_id"`
+	// service_account
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	// authorized_user
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// CredentialsFromJSON returns token source of the service account key or of the authorized user credentials
+// and project of the credentials
+func CredentialsFromJSON(data []byte) (TokenSource, string, error) {
+	var f credentialsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, "", errors.Wrap(err, "invalid credentials")
+	}
+	if f.TokenURI == "" {
+		f.TokenURI = DefaultTokenURI
+	}
+	switch f.Type {
+	case "service_account":
+		key, err := parseKey([]byte(f.PrivateKey))
+		if err != nil {
+			return nil, "", err
+		}
+		return &serviceAccount{email: f.ClientEmail, keyID: f.PrivateKeyID, key: key, tokenURI: f.TokenURI},
+			f.ProjectID, nil
+	case "authorized_user":
+		return &authorizedUser{form: url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {f.ClientID},
+			"client_secret": {f.ClientSecret},
+			"refresh_token": {f.RefreshToken},
+		}, tokenURI: f.TokenURI}, f.QuotaProjectID, nil
+	}
+	return nil, "", errors.New("unsupported credentials type: " + f.Type)
+}
+
+// DefaultCredentials returns application default credentials and their project:
+// GOOGLE_APPLICATION_CREDENTIALS file, gcloud application_default_credentials.json,
+// service account of the Compute Engine metadata server otherwise
+func DefaultCredentials() (TokenSource, string, error) {
+	name := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if name == "" {
+		name = wellKnownFile()
+		if _, err := os.Stat(name); err != nil {
+			return MetadataTokenSource{}, "", nil
+		}
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, "", err
+	}
+	return CredentialsFromJSON(data)
+}
+
+// wellKnownFile of the gcloud application default credentials
+func wellKnownFile() string {
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" && runtime.GOOS == "windows" {
+		dir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+	}
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config", "gcloud")
+	}
+	return filepath.Join(dir, "application_default_credentials.json")
+}
+
+func parseKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid private key")
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not RSA")
+	}
+	return rsaKey, nil
+}
+
+// serviceAccount exchanges signed JWT for the access token
+type serviceAccount struct {
+	email    string
+	keyID    string
+	key      *rsa.PrivateKey
+	tokenURI string
+}
+
+// Token implements TokenSource
+func (s *serviceAccount) Token(ctx context.Context) (Token, error) {
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": s.keyID})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   s.email,
+		"scope": Scope,
+		"aud":   s.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	h := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, h[:])
+	if err != nil {
+		return Token{}, err
+	}
+	return exchange(ctx, s.tokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + enc.EncodeToString(sig)},
+	})
+}
+
+// authorizedUser exchanges refresh token for the access token
+type authorizedUser struct {
+	form     url.Values
+	tokenURI string
+}
+
+// Token implements TokenSource
+func (u *authorizedUser) Token(ctx context.Context) (Token, error) {
+	return exchange(ctx, u.tokenURI, u.form)
+}
+
+// MetadataTokenSource returns token of the default service account from the Compute Engine
+// metadata server. Host is read from GCE_METADATA_HOST if empty
+type MetadataTokenSource struct {
+	Host string
+}
+
+// Token implements TokenSource
+func (m MetadataTokenSource) Token(ctx context.Context) (Token, error) {
+	body, err := m.get(ctx, "instance/service-accounts/default/token")
+	if err != nil {
+		return Token{}, errors.Wrap(err, "metadata server token")
+	}
+	return decodeToken(body)
+}
+
+// Project returns project of the instance
+func (m MetadataTokenSource) Project(ctx context.Context) (string, error) {
+	body, err := m.get(ctx, "project/project-id")
+	return strings.TrimSpace(string(body)), errors.Wrap(err, "metadata server project")
+}
+
+func (m MetadataTokenSource) get(ctx context.Context, path string) ([]byte, error) {
+	host := m.Host
+	if host == "" {
+		host = os.Getenv("GCE_METADATA_HOST")
+	}
+	if host == "" {
+		host = DefaultMetadataHost
+	}
+	req, err := http.NewRequest(http.MethodGet, "http://"+host+"/computeMetadata/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	return doHTTP(ctx, &http.Client{Timeout: 5 * time.Second}, req)
+}
+
+// exchange posts form to the token endpoint
+func exchange(ctx context.Context, tokenURI string, form url.Values) (Token, error) {
+	req, err := http.NewRequest(http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := doHTTP(ctx, &http.Client{Timeout: DefaultTimeout}, req)
+	if err != nil {
+		return Token{}, errors.Wrap(err, "oauth2 token")
+	}
+	return decodeToken(body)
+}
+
+func decodeToken(body []byte) (Token, error) {
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil || out.AccessToken == "" {
+		return Token{}, errors.New("invalid token response")
+	}
+	t := Token{AccessToken: out.AccessToken}
+	if out.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(out.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
+
+// cachedToken keeps token until it expires
+type cachedToken struct {
+	TokenSource
+	mu    sync.Mutex
+	token *Token
+}
+
+// Token implements TokenSource
+func (c *cachedToken) Token(ctx context.Context) (Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != nil && (c.token.Expiry.IsZero() || time.Until(c.token.Expiry) > expiryWindow) {
+		return *c.token, nil
+	}
+	t, err := c.TokenSource.Token(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+	c.token = &t
+	return t, nil
+}
+
+// doHTTP sends request and returns body of the 200 response
+func doHTTP(ctx context.Context, hc *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := hc.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,282 @@
+// Package gcpsmtest provides in-process fake of the Google Cloud Secret Manager API for tests.
+//
+// Implemented subset: AccessSecretVersion, OAuth 2.0 token endpoint (JWT bearer and refresh token grants),
+// token of the Compute Engine metadata server, bearer token authentication.
+package gcpsmtest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type version struct {
+	data     []byte
+	disabled bool
+}
+
+// Server fake Secret Manager API server
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	secrets  map[string][]*version
+	tokens   map[string]bool
+	refresh  map[string]bool
+	key      *rsa.PrivateKey
+	requests int
+	// Project returned by the metadata server
+	Project string
+	// Corrupt sends wrong payload checksum
+	Corrupt bool
+}
+
+// NewServer starts fake API server. Caller must Close it
+func NewServer() *Server {
+	s := &Server{secrets: make(map[string][]*version), refresh: make(map[string]bool)}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Start fake API server closed on test cleanup
+func Start(t testing.TB) *Server {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// AddToken enables authentication: only requests with one of the added or issued bearer tokens are allowed
+func (s *Server) AddToken(token string) *Server {
+	s.mu.Lock()
+	s.addToken(token)
+	s.mu.Unlock()
+	return s
+}
+
+// addToken requires lock
+func (s *Server) addToken(token string) {
+	s.enableAuth()
+	s.tokens[token] = true
+}
+
+// enableAuth requires lock
+func (s *Server) enableAuth() {
+	if s.tokens == nil {
+		s.tokens = make(map[string]bool)
+	}
+}
+
+// Requests returns count of handled Secret Manager API requests
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// AddVersion adds version of the secret "projects/<project>/[locations/<location>/]secrets/<secret>".
+// Returns name of the version
+func (s *Server) AddVersion(secret string, data []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[secret] = append(s.secrets[secret], &version{data: data})
+	return secret + "/versions/" + strconv.Itoa(len(s.secrets[secret]))
+}
+
+// DisableVersion of the secret
+func (s *Server) DisableVersion(secret string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n > 0 && n <= len(s.secrets[secret]) {
+		s.secrets[secret][n-1].disabled = true
+	}
+}
+
+// ServiceAccountJSON returns service account key file with the token endpoint of the server.
+// Enables authentication
+func (s *Server) ServiceAccountJSON(email, project string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key == nil {
+		s.key, _ = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	s.enableAuth()
+	key := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(s.key)})
+	b, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     project,
+		"private_key_id": "key-1",
+		"private_key":    string(key),
+		"client_email":   email,
+		"token_uri":      s.URL + "/token",
+	})
+	return b
+}
+
+// AuthorizedUserJSON returns gcloud user credentials file with the token endpoint of the server.
+// Enables authentication
+func (s *Server) AuthorizedUserJSON(refreshToken string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh[refreshToken] = true
+	s.enableAuth()
+	b, _ := json.Marshal(map[string]string{
+		"type":          "authorized_user",
+		"client_id":     "client",
+		"client_secret": "secret",
+		"refresh_token": refreshToken,
+		"token_uri":     s.URL + "/token",
+	})
+	return b
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.URL.Path == "/token" && r.Method == http.MethodPost:
+		s.token(w, r)
+		return
+	case strings.HasPrefix(r.URL.Path, "/computeMetadata/v1/"):
+		s.metadata(w, r)
+		return
+	}
+	s.requests++
+	if s.tokens != nil && !s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] {
+		writeError(w, http.StatusUnauthorized, "UNAUTHENTICATED", "Request had invalid authentication credentials.")
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/v1/")
+	if r.Method != http.MethodGet || !strings.HasSuffix(name, ":access") {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Method not found.")
+		return
+	}
+	name = strings.TrimSuffix(name, ":access")
+	i := strings.LastIndex(name, "/versions/")
+	if i < 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid resource field value in the request.")
+		return
+	}
+	secret, ver := name[:i], name[i+len("/versions/"):]
+	versions := s.secrets[secret]
+	if len(versions) == 0 {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Secret ["+secret+"] not found or has no versions.")
+		return
+	}
+	n := len(versions)
+	if ver != "latest" {
+		var err error
+		if n, err = strconv.Atoi(ver); err != nil || n < 1 || n > len(versions) {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Secret Version ["+name+"] not found.")
+			return
+		}
+	} else {
+		for n > 0 && versions[n-1].disabled {
+			n--
+		}
+	}
+	if n == 0 || versions[n-1].disabled {
+		writeError(w, http.StatusBadRequest, "FAILED_PRECONDITION", "Secret Version ["+name+"] is in DISABLED state.")
+		return
+	}
+	data := versions[n-1].data
+	sum := crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))
+	if s.Corrupt {
+		sum++
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":    secret + "/versions/" + strconv.Itoa(n),
+		"payload": map[string]interface{}{"data": data, "dataCrc32c": strconv.FormatUint(uint64(sum), 10)},
+	})
+}
+
+// token handles OAuth 2.0 token requests. Requires lock
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ok := false
+	switch r.PostForm.Get("grant_type") {
+	case "urn:ietf:params:oauth:grant-type:jwt-bearer":
+		ok = s.key != nil && verifyJWT(&s.key.PublicKey, r.PostForm.Get("assertion"), s.URL+"/token")
+	case "refresh_token":
+		ok = s.refresh[r.PostForm.Get("refresh_token")]
+	}
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	s.issue(w)
+}
+
+// metadata handles requests to the Compute Engine metadata server. Requires lock
+func (s *Server) metadata(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Metadata-Flavor") != "Google" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch strings.TrimPrefix(r.URL.Path, "/computeMetadata/v1/") {
+	case "instance/service-accounts/default/token":
+		s.issue(w)
+	case "project/project-id":
+		_, _ = w.Write([]byte(s.Project))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// issue new access token. Requires lock
+func (s *Server) issue(w http.ResponseWriter) {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	token := "ya29." + hex.EncodeToString(b)
+	s.addToken(token)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": token, "expires_in": 3599, "token_type": "Bearer"})
+}
+
+func verifyJWT(key *rsa.PublicKey, jwt, aud string) bool {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	h := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, h[:], sig) != nil {
+		return false
+	}
+	var claims struct {
+		Aud   string `json:"aud"`
+		Scope string `json:"scope"`
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	return json.Unmarshal(payload, &claims) == nil && claims.Aud == aud && claims.Scope != ""
+}
+
+func writeError(w http.ResponseWriter, code int, status, msg string) {
+	writeJSON(w, code, map[string]interface{}{"error": map[string]interface{}{
+		"code": code, "status": status, "message": msg,
+	}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,98 @@
+// Package gcpsm Noble Google Cloud Secret Manager reader
+package gcpsm
+
+import (
+	"context"
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/jsonpath"
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("gcpsm", &KeyReader{})
+}
+
+//nolint:gochecknoglobals
+var errKeyFormat = errors.New("incorrect key format. " +
+	"use [projects/<project>/[locations/<location>/]secrets/]<secret>[/versions/<version>][#<field>]")
+
+// KeyReader type implements noble.SecretStorage
+//
+// Key format: [projects/<project>/[locations/<location>/]secrets/]<secret>[/versions/<version>][#<field>]
+//
+//	gcpsm:projects/p/secrets/db-password/versions/latest - latest enabled version
+//	gcpsm:projects/p/secrets/db-password/versions/3 - pinned version
+//	gcpsm:db-password - latest version of the secret of the default project
+//	gcpsm:projects/p/locations/europe-west1/secrets/db#password - field of the JSON value of the regional secret
+type KeyReader struct {
+}
+
+// Read secret version payload from Secret Manager
+func (r *KeyReader) Read(key string) (string, error) {
+	c, err := getClient()
+	if err != nil {
+		return "", err
+	}
+	var field string
+	if i := strings.Index(key, "#"); i >= 0 {
+		key, field = key[:i], key[i+1:]
+	}
+	ctx := context.Background()
+	name, location, err := parseName(key)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(name, "projects/") {
+		project, err := c.defaultProject(ctx)
+		if err != nil {
+			return "", err
+		}
+		name = "projects/" + project + "/secrets/" + name
+	}
+	data, err := c.access(ctx, name, location)
+	if err != nil {
+		return "", errors.Wrap(err, "gcpsm secret "+name)
+	}
+	if field == "" {
+		return string(data), nil
+	}
+	val, err := jsonpath.Extract(data, field)
+	return val, errors.Wrap(err, "gcpsm secret "+name)
+}
+
+// parseName returns version name (relative to the default project for short names) and location of the secret
+func parseName(key string) (name, location string, err error) {
+	parts := strings.Split(key, "/")
+	for _, p := range parts {
+		if p == "" {
+			return "", "", errKeyFormat
+		}
+	}
+	rest := parts
+	if parts[0] == "projects" {
+		rest = nil
+		switch {
+		case len(parts) >= 4 && parts[2] == "secrets":
+			rest = parts[3:]
+		case len(parts) >= 6 && parts[2] == "locations" && parts[4] == "secrets":
+			location = parts[3]
+			rest = parts[5:]
+		}
+	}
+	switch {
+	case len(rest) == 1:
+		key += "/versions/latest"
+	case len(rest) == 3 && rest[1] == "versions":
+	default:
+		return "", "", errKeyFormat
+	}
+	return key, location, nil
+}
+
+// Clone returns new empty instance of KeyReader
+func (r *KeyReader) Clone() noble.SecretStorage {
+	return &KeyReader{}
+}
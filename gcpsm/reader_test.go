@@ -0,0 +1,126 @@
+package gcpsm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lancer-kit/noble/gcpsm/gcpsmtest"
+	"github.com/lancer-kit/noble/nobletest"
+)
+
+func initClient(t *testing.T, cfg *Config) {
+	nobletest.InitClient(t, func() error { return Init(cfg) }, clients.Reset)
+}
+
+func TestKeyReader_Read(t *testing.T) {
+	srv := gcpsmtest.Start(t)
+	srv.AddToken("token")
+	srv.AddVersion("projects/p/secrets/db", []byte(`{"user":"admin","password":"p1"}`))
+	srv.AddVersion("projects/p/secrets/db", []byte(`{"user":"admin","password":"p2"}`))
+	srv.AddVersion("projects/p/secrets/db", []byte(`{"user":"admin","password":"p3"}`))
+	srv.DisableVersion("projects/p/secrets/db", 3)
+	srv.AddVersion("projects/p/locations/europe-west1/secrets/api", []byte("regional"))
+	srv.AddVersion("projects/other/secrets/key", []byte("k\n"))
+	initClient(t, &Config{Endpoint: srv.URL, Project: "p", TokenSource: StaticToken("token")})
+
+	r := KeyReader{}
+	for key, expected := range map[string]string{
+		"projects/p/secrets/db/versions/latest#password":           "p2",
+		"projects/p/secrets/db/versions/1#password":                "p1",
+		"projects/p/secrets/db#password":                           "p2",
+		"db/versions/1#password":                                   "p1",
+		"db#$.user":                                                "admin",
+		"projects/p/locations/europe-west1/secrets/api":            "regional",
+		"projects/other/secrets/key/versions/latest":               "k\n",
+		"projects/p/locations/europe-west1/secrets/api/versions/1": "regional",
+	} {
+		v, err := r.Read(key)
+		assert.NoError(t, err, key)
+		assert.Equal(t, expected, v, key)
+	}
+	for _, key := range []string{
+		"projects/p/secrets/db/versions/3",
+		"projects/p/secrets/db/versions/9",
+		"projects/p/secrets/none",
+		"projects/p/secrets/db#none",
+		"projects/p/db",
+		"projects//secrets/db",
+		"db/1",
+	} {
+		_, err := r.Read(key)
+		assert.Error(t, err, key)
+	}
+
+	var c nobletest.Config
+	assert.NoError(t, yaml.Unmarshal([]byte(`secret: "gcpsm:projects/p/secrets/db/versions/latest#user"`), &c))
+	assert.NoError(t, c.Secret.InternalError())
+	assert.Equal(t, "admin", c.Secret.Get())
+
+	srv.Corrupt = true
+	_, err := r.Read("db")
+	assert.Contains(t, err.Error(), "checksum")
+
+	initClient(t, &Config{Endpoint: srv.URL, Project: "p", TokenSource: StaticToken("wrong")})
+	_, err = r.Read("db")
+	assert.Contains(t, err.Error(), "UNAUTHENTICATED")
+}
+
+func TestCredentials(t *testing.T) {
+	srv := gcpsmtest.Start(t)
+	srv.Project = "meta"
+	srv.AddVersion("projects/sa/secrets/db", []byte("sa"))
+	srv.AddVersion("projects/meta/secrets/db", []byte("meta"))
+	srv.AddVersion("projects/user/secrets/db", []byte("user"))
+
+	// service account key
+	initClient(t, &Config{Endpoint: srv.URL, CredentialsJSON: srv.ServiceAccountJSON("app@sa.iam.gserviceaccount.com", "sa")})
+	for i := 0; i < 3; i++ {
+		v, err := (&KeyReader{}).Read("db")
+		assert.NoError(t, err)
+		assert.Equal(t, "sa", v)
+	}
+
+	dir, err := ioutil.TempDir("", "gcpsm")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	// gcloud user credentials from the well-known file
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "gcloud"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "gcloud", "application_default_credentials.json"),
+		srv.AuthorizedUserJSON("refresh"), 0600))
+	nobletest.Setenv(t, map[string]string{
+		"CLOUDSDK_CONFIG":                filepath.Join(dir, "gcloud"),
+		"GOOGLE_APPLICATION_CREDENTIALS": "",
+		EmulatorHostEnv:                  "",
+		"GCE_METADATA_HOST":              strings.TrimPrefix(srv.URL, "http://"),
+	})
+	initClient(t, &Config{Endpoint: srv.URL, Project: "user"})
+	v, err := (&KeyReader{}).Read("db")
+	assert.NoError(t, err)
+	assert.Equal(t, "user", v)
+
+	// metadata server
+	nobletest.Setenv(t, map[string]string{"CLOUDSDK_CONFIG": filepath.Join(dir, "none")})
+	initClient(t, &Config{Endpoint: srv.URL})
+	v, err = (&KeyReader{}).Read("db")
+	assert.NoError(t, err)
+	assert.Equal(t, "meta", v)
+
+	// emulator
+	emu := gcpsmtest.Start(t)
+	emu.AddVersion("projects/emu/secrets/db", []byte("emu"))
+	nobletest.Setenv(t, map[string]string{EmulatorHostEnv: strings.TrimPrefix(emu.URL, "http://"), "GOOGLE_CLOUD_PROJECT": "emu"})
+	initClient(t, nil)
+	v, err = (&KeyReader{}).Read("db")
+	assert.NoError(t, err)
+	assert.Equal(t, "emu", v)
+
+	_, _, err = CredentialsFromJSON([]byte(`{"type":"external_account"}`))
+	assert.Error(t, err)
+}
@@ -1,13 +1,17 @@
 module github.com/lancer-kit/noble
 
-go 1.13
+go 1.14
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/hashicorp/vault/api v1.1.1
 	github.com/lancer-kit/armory v1.10.0
 	github.com/pkg/errors v0.9.1
 	github.com/sirupsen/logrus v1.8.1
 	github.com/stretchr/testify v1.7.0
 	github.com/urfave/cli v1.22.5
+	golang.org/x/sys v0.0.0-20220114195835-da31bd327af9
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
+	software.sslmate.com/src/go-pkcs12 v0.2.0
 )
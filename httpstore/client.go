@@ -0,0 +1,167 @@
+package httpstore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/tlsconf"
+	"github.com/pkg/errors"
+)
+
+type httpClient struct {
+	endpoints map[string]*endpoint
+}
+
+type endpoint struct {
+	cfg  Endpoint
+	http *http.Client
+
+	// smu guards reading of the header secrets
+	smu sync.Mutex
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+type cached struct {
+	body    []byte
+	expires time.Time
+}
+
+// apiError non-200 response of the endpoint
+type apiError struct {
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	if e.Status == http.StatusNotFound {
+		return "http resource not found"
+	}
+	return fmt.Sprintf("http error (status %d): %s", e.Status, e.Body)
+}
+
+// temporary errors are retried
+func (e *apiError) temporary() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status >= http.StatusInternalServerError
+}
+
+func newClient(cfg Config) (*httpClient, error) {
+	c := &httpClient{endpoints: make(map[string]*endpoint, len(cfg.Endpoints))}
+	for name, e := range cfg.Endpoints {
+		if !strings.Contains(e.URL, "://") {
+			return nil, errors.New("invalid url of http endpoint " + name + ": " + e.URL)
+		}
+		e.URL = strings.TrimRight(e.URL, "/")
+		if e.Timeout == 0 {
+			e.Timeout = DefaultTimeout
+		}
+		if e.Retries == 0 {
+			e.Retries = DefaultRetries
+		}
+		if e.CacheTTL == 0 {
+			e.CacheTTL = DefaultCacheTTL
+		}
+		tlsCfg, err := tlsconf.Files{
+			CAFile:             e.CAFile,
+			CertFile:           e.CertFile,
+			KeyFile:            e.KeyFile,
+			InsecureSkipVerify: e.InsecureSkipVerify,
+		}.Load()
+		if err != nil {
+			return nil, errors.Wrap(err, "http endpoint "+name)
+		}
+		transport := &http.Transport{TLSClientConfig: tlsCfg, Proxy: http.ProxyFromEnvironment}
+		c.endpoints[name] = &endpoint{
+			cfg:   e,
+			http:  &http.Client{Timeout: e.Timeout, Transport: transport},
+			cache: make(map[string]cached),
+		}
+	}
+	return c, nil
+}
+
+// Close closes idle connections of the endpoints
+func (c *httpClient) Close() {
+	for _, e := range c.endpoints {
+		e.http.CloseIdleConnections()
+	}
+}
+
+// get response body of the resource from the cache or from the endpoint, retrying temporary errors
+func (e *endpoint) get(resource string) ([]byte, error) {
+	u := e.cfg.URL + "/" + strings.TrimLeft(resource, "/")
+	if e.cfg.CacheTTL > 0 {
+		e.mu.Lock()
+		c, ok := e.cache[u]
+		e.mu.Unlock()
+		if ok && time.Now().Before(c.expires) {
+			return c.body, nil
+		}
+	}
+	delay := RetryDelay
+	var (
+		body []byte
+		err  error
+	)
+	for attempt := 0; ; attempt++ {
+		body, err = e.do(u)
+		ae, ok := err.(*apiError)
+		if err == nil || (ok && !ae.temporary()) || attempt >= e.cfg.Retries {
+			break
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	if err != nil {
+		return nil, err
+	}
+	if e.cfg.CacheTTL > 0 {
+		e.mu.Lock()
+		e.cache[u] = cached{body: body, expires: time.Now().Add(e.cfg.CacheTTL)}
+		e.mu.Unlock()
+	}
+	return body, nil
+}
+
+// do request of the URL
+func (e *endpoint) do(u string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	e.smu.Lock()
+	for k, v := range e.cfg.Headers {
+		val := v.Get()
+		if msg := v.Error(); msg != "" {
+			e.smu.Unlock()
+			return nil, errors.New("http header " + k + ": " + msg)
+		}
+		req.Header.Set(k, val)
+	}
+	if token := e.cfg.BearerToken.Get(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if msg := e.cfg.BearerToken.Error(); msg != "" {
+		e.smu.Unlock()
+		return nil, errors.New("http bearer token: " + msg)
+	}
+	e.smu.Unlock()
+	res, err := e.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, &apiError{Status: res.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	return data, nil
+}
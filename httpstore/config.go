@@ -0,0 +1,119 @@
+package httpstore
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/storeclient"
+)
+
+// Config of the HTTP client
+type Config struct {
+	// Endpoints by name used in keys
+	Endpoints map[string]Endpoint
+}
+
+// Endpoint of the HTTP(S) service
+type Endpoint struct {
+	// URL base of the resources, e.g. "https://billing.internal/api"
+	URL string
+	// BearerToken sent in "Authorization: Bearer" header if not empty, e.g. "env:BILLING_TOKEN"
+	BearerToken noble.Secret
+	// Headers sent with every request. Values are read on every request
+	Headers map[string]noble.Secret
+	// CertFile and KeyFile client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// CAFile trusted CA bundle for server certificates
+	CAFile             string
+	InsecureSkipVerify bool
+	// Timeout of a single request
+	Timeout time.Duration
+	// Retries of requests failed with network error, 429 or 5xx status. DefaultRetries if zero,
+	// negative disables retries
+	Retries int
+	// CacheTTL of the responses, negative disables cache
+	CacheTTL time.Duration
+}
+
+// Defaults of the endpoint config
+const (
+	DefaultTimeout  = 10 * time.Second
+	DefaultRetries  = 2
+	DefaultCacheTTL = time.Minute
+)
+
+// RetryDelay before the first retry, doubled for every next one
+//
+//nolint:gochecknoglobals
+var RetryDelay = 200 * time.Millisecond
+
+//nolint:gochecknoglobals
+var clients storeclient.Holder
+
+// Init HTTP client. Config from NOBLE_HTTP_* environment variables used if cfg is nil
+// or Init was not called before the first read
+func Init(cfg *Config) error {
+	if cfg == nil {
+		c := ConfigFromEnv()
+		cfg = &c
+	}
+	c, err := newClient(*cfg)
+	if err != nil {
+		return err
+	}
+	clients.Set(c)
+	return nil
+}
+
+func getClient() (*httpClient, error) {
+	c, err := clients.Get(func() error { return Init(nil) })
+	if err != nil {
+		return nil, err
+	}
+	return c.(*httpClient), nil
+}
+
+// ConfigFromEnv returns endpoints of the environment variables, name is lower case <NAME>:
+//
+//	NOBLE_HTTP_<NAME>_URL - base URL
+//	NOBLE_HTTP_<NAME>_TOKEN - bearer token as noble secret, e.g. "env:BILLING_TOKEN"
+//	NOBLE_HTTP_<NAME>_CACERT, NOBLE_HTTP_<NAME>_CLIENT_CERT, NOBLE_HTTP_<NAME>_CLIENT_KEY - TLS files
+//	NOBLE_HTTP_<NAME>_TIMEOUT - timeout, e.g. "5s"
+//	NOBLE_HTTP_<NAME>_RETRIES - count of retries, DefaultRetries if not set, "0" disables retries
+func ConfigFromEnv() Config {
+	cfg := Config{Endpoints: make(map[string]Endpoint)}
+	for _, kv := range os.Environ() {
+		k := strings.SplitN(kv, "=", 2)[0]
+		if !strings.HasPrefix(k, "NOBLE_HTTP_") || !strings.HasSuffix(k, "_URL") {
+			continue
+		}
+		prefix := strings.TrimSuffix(k, "URL")
+		name := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(prefix, "NOBLE_HTTP_"), "_"))
+		if name == "" {
+			continue
+		}
+		e := Endpoint{
+			URL:      os.Getenv(k),
+			CAFile:   os.Getenv(prefix + "CACERT"),
+			CertFile: os.Getenv(prefix + "CLIENT_CERT"),
+			KeyFile:  os.Getenv(prefix + "CLIENT_KEY"),
+		}
+		if t := os.Getenv(prefix + "TOKEN"); t != "" {
+			e.BearerToken = noble.Secret{}.New(t)
+		}
+		e.Timeout, _ = time.ParseDuration(os.Getenv(prefix + "TIMEOUT"))
+		if r, err := strconv.Atoi(os.Getenv(prefix + "RETRIES")); err == nil {
+			e.Retries = r
+			if r == 0 {
+				// zero Retries of the config means default
+				e.Retries = -1
+			}
+		}
+		cfg.Endpoints[name] = e
+	}
+	return cfg
+}
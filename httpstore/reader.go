@@ -0,0 +1,59 @@
+// Package httpstore Noble HTTP(S) endpoint reader
+package httpstore
+
+import (
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/jsonpath"
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("http", &KeyReader{})
+}
+
+// KeyReader type implements noble.SecretStorage
+//
+// Key format: <endpoint>/<path>[?<query>][#<field>]
+//
+//	http:billing/v1/keys/stripe - response body of the resource of the "billing" endpoint
+//	http:billing/v1/keys/stripe#$.data.key - field of the JSON response
+//	http:billing/v1/keys?name=stripe#data.key - resource with query
+type KeyReader struct {
+}
+
+// Read resource of the endpoint
+func (r *KeyReader) Read(key string) (string, error) {
+	c, err := getClient()
+	if err != nil {
+		return "", err
+	}
+	field := ""
+	if i := strings.Index(key, "#"); i >= 0 {
+		key, field = key[:i], key[i+1:]
+	}
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", errors.New("incorrect key format. use <endpoint>/<path>[?<query>][#<field>]")
+	}
+	e, ok := c.endpoints[parts[0]]
+	if !ok {
+		return "", errors.New("unknown http endpoint: " + parts[0])
+	}
+	body, err := e.get(parts[1])
+	if err != nil {
+		return "", errors.Wrap(err, "http "+key)
+	}
+	if field == "" {
+		return string(body), nil
+	}
+	val, err := jsonpath.Extract(body, field)
+	return val, errors.Wrap(err, "http "+key)
+}
+
+// Clone returns new empty instance of KeyReader
+func (r *KeyReader) Clone() noble.SecretStorage {
+	return &KeyReader{}
+}
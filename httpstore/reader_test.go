@@ -0,0 +1,218 @@
+package httpstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/nobletest"
+)
+
+func initClient(t *testing.T, cfg *Config) {
+	nobletest.InitClient(t, func() error { return Init(cfg) }, clients.Reset)
+}
+
+// billing serves /api/v1 resources for requests with the token
+func billing(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token || r.Header.Get("X-Tenant") != "tenant-a" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/keys/stripe":
+			_, _ = w.Write([]byte(`{"data":{"key":"sk_test","scopes":["read"]}}`))
+		case "/api/v1/keys":
+			_, _ = w.Write([]byte(`{"data":{"key":"` + r.URL.Query().Get("name") + `"}}`))
+		case "/api/v1/plain":
+			_, _ = w.Write([]byte("plain"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestKeyReader_Read(t *testing.T) {
+	srv := httptest.NewServer(billing("token"))
+	defer srv.Close()
+	nobletest.Setenv(t, map[string]string{"BILLING_TOKEN": "token"})
+	initClient(t, &Config{Endpoints: map[string]Endpoint{
+		"billing": {
+			URL:         srv.URL + "/api/",
+			BearerToken: noble.Secret{}.New("dynenv:BILLING_TOKEN"),
+			Headers:     map[string]noble.Secret{"X-Tenant": noble.Secret{}.New("raw:tenant-a")},
+			CacheTTL:    -1,
+		},
+		"denied": {URL: srv.URL + "/api", Retries: -1, CacheTTL: -1},
+	}})
+
+	r := KeyReader{}
+	v, err := r.Read("billing/v1/keys/stripe#$.data.key")
+	assert.NoError(t, err)
+	assert.Equal(t, "sk_test", v)
+	v, err = r.Read("billing/v1/keys/stripe#data.scopes")
+	assert.NoError(t, err)
+	assert.Equal(t, `["read"]`, v)
+	v, err = r.Read("billing/v1/keys?name=other#data.key")
+	assert.NoError(t, err)
+	assert.Equal(t, "other", v)
+	v, err = r.Read("billing/v1/plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain", v)
+
+	for _, key := range []string{"billing/v1/none", "billing/v1/plain#key", "billing/v1/keys/stripe#data.none",
+		"denied/v1/plain", "none/v1/plain", "billing", "/v1/plain"} {
+		_, err = r.Read(key)
+		assert.Error(t, err, key)
+	}
+
+	// token is read on every request
+	nobletest.Setenv(t, map[string]string{"BILLING_TOKEN": "rotated"})
+	_, err = r.Read("billing/v1/plain")
+	assert.Error(t, err)
+
+	var c nobletest.Config
+	assert.NoError(t, yaml.Unmarshal([]byte(`secret: "http:denied/v1/plain"`), &c))
+	assert.Error(t, c.Secret.InternalError())
+}
+
+func TestEndpoint_RetryCache(t *testing.T) {
+	defer func(d time.Duration) { RetryDelay = d }(RetryDelay)
+	RetryDelay = time.Millisecond
+	var requests, failures int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"key":"value"}`))
+	}))
+	defer srv.Close()
+	initClient(t, &Config{Endpoints: map[string]Endpoint{
+		"api": {URL: srv.URL, CacheTTL: 100 * time.Millisecond},
+	}})
+
+	r := KeyReader{}
+	atomic.StoreInt32(&failures, 2)
+	v, err := r.Read("api/key#key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", v)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	// cached
+	atomic.StoreInt32(&failures, 10)
+	_, err = r.Read("api/key")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	time.Sleep(150 * time.Millisecond)
+	_, err = r.Read("api/key")
+	assert.Error(t, err)
+	assert.Equal(t, int32(3+1+DefaultRetries), atomic.LoadInt32(&requests))
+}
+
+func TestEndpoint_MutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpstore")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour), ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("value"))
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.StartTLS()
+	defer srv.Close()
+
+	files := map[string][]byte{
+		"ca.pem":         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}),
+		"client.pem":     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		"client-key.pem": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+	for name, data := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0600))
+	}
+
+	initClient(t, &Config{Endpoints: map[string]Endpoint{
+		"mtls":    {URL: srv.URL, CAFile: filepath.Join(dir, "ca.pem"), CertFile: filepath.Join(dir, "client.pem"), KeyFile: filepath.Join(dir, "client-key.pem")},
+		"nocert":  {URL: srv.URL, CAFile: filepath.Join(dir, "ca.pem"), Retries: -1},
+		"untrust": {URL: srv.URL, Retries: -1},
+	}})
+	r := KeyReader{}
+	v, err := r.Read("mtls/key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", v)
+	_, err = r.Read("nocert/key")
+	assert.Error(t, err)
+	_, err = r.Read("untrust/key")
+	assert.Error(t, err)
+
+	assert.Error(t, Init(&Config{Endpoints: map[string]Endpoint{"e": {URL: srv.URL, CertFile: "none.pem", KeyFile: "none-key.pem"}}}))
+	assert.Error(t, Init(&Config{Endpoints: map[string]Endpoint{"e": {URL: "localhost:8080"}}}))
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	nobletest.Setenv(t, map[string]string{
+		"NOBLE_HTTP_BILLING_URL":         "https://billing.internal/api",
+		"NOBLE_HTTP_BILLING_TOKEN":       "env:BILLING_TOKEN",
+		"NOBLE_HTTP_BILLING_CACERT":      "/etc/billing/ca.pem",
+		"NOBLE_HTTP_BILLING_CLIENT_CERT": "/etc/billing/client.pem",
+		"NOBLE_HTTP_BILLING_CLIENT_KEY":  "/etc/billing/client-key.pem",
+		"NOBLE_HTTP_BILLING_TIMEOUT":     "3s",
+		"NOBLE_HTTP_BILLING_RETRIES":     "5",
+		"NOBLE_HTTP_USER_API_URL":        "http://users:8080",
+		"NOBLE_HTTP_USER_API_RETRIES":    "0",
+		"NOBLE_HTTP_AUDIT_URL":           "http://audit:8080",
+		"BILLING_TOKEN":                  "token",
+	})
+	cfg := ConfigFromEnv()
+	assert.Len(t, cfg.Endpoints, 3)
+	e := cfg.Endpoints["billing"]
+	assert.Equal(t, "https://billing.internal/api", e.URL)
+	assert.Equal(t, "token", e.BearerToken.Get())
+	assert.Equal(t, "/etc/billing/ca.pem", e.CAFile)
+	assert.Equal(t, "/etc/billing/client.pem", e.CertFile)
+	assert.Equal(t, "/etc/billing/client-key.pem", e.KeyFile)
+	assert.Equal(t, 3*time.Second, e.Timeout)
+	assert.Equal(t, 5, e.Retries)
+	assert.Equal(t, "http://users:8080", cfg.Endpoints["user_api"].URL)
+
+	// explicit zero disables retries, unset is default
+	delete(cfg.Endpoints, "billing")
+	c, err := newClient(cfg)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer c.Close()
+	assert.Equal(t, -1, c.endpoints["user_api"].cfg.Retries)
+	assert.Equal(t, DefaultRetries, c.endpoints["audit"].cfg.Retries)
+}
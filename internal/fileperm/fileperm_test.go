@@ -0,0 +1,45 @@
+//go:build !windows
+// +build !windows
+
+package fileperm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileperm")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	for _, tt := range []struct {
+		perm       os.FileMode
+		allowGroup bool
+		ok         bool
+	}{
+		{0600, false, true},
+		{0400, false, true},
+		{0640, false, false},
+		{0644, false, false},
+		// systemd credential shared with the service user by ACL
+		{0440, true, true},
+		{0400, true, true},
+		{0444, true, false},
+	} {
+		name := filepath.Join(dir, "secret")
+		assert.NoError(t, ioutil.WriteFile(name, []byte("secret"), 0600))
+		assert.NoError(t, os.Chmod(name, tt.perm))
+		fi, err := os.Stat(name)
+		assert.NoError(t, err)
+		if tt.ok {
+			assert.NoError(t, Check(fi, tt.allowGroup), "%04o", tt.perm)
+		} else {
+			assert.Error(t, Check(fi, tt.allowGroup), "%04o", tt.perm)
+		}
+	}
+}
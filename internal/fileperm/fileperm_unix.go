@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+// Package fileperm checks permissions of the secret files
+package fileperm
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Check accepts files owned by the effective user or root without group and others permissions.
+// Group permissions are accepted if allowGroup is set, e.g. ACL mask of the systemd credentials
+func Check(fi os.FileInfo, allowGroup bool) error {
+	mask := os.FileMode(0077)
+	if allowGroup {
+		mask = 0007
+	}
+	if perm := fi.Mode().Perm(); perm&mask != 0 {
+		return fmt.Errorf("permissions %04o are too open, expected 0400 or 0600", perm)
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if uid := os.Geteuid(); int(st.Uid) != uid && st.Uid != 0 {
+		return fmt.Errorf("owned by uid %d, expected %d or root", st.Uid, uid)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+// Package fileperm checks permissions of the secret files
+package fileperm
+
+import "os"
+
+// Check is not supported on windows
+func Check(os.FileInfo, bool) error {
+	return nil
+}
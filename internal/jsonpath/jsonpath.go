@@ -0,0 +1,167 @@
+// Package jsonpath extracts values from decoded JSON documents by simple selectors.
+//
+// Supported syntax: dotted keys "db.password", array indexes "hosts[0]" or "hosts.0",
+// bracket keys "['key.with.dots']" and optional JSONPath root "$." prefix.
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Select returns value of the document (decoded by encoding/json, yaml or similar) at selector
+func Select(doc interface{}, selector string) (interface{}, error) {
+	path, err := Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+	cur := doc
+	for i, p := range path {
+		next, ok := step(cur, p)
+		if !ok {
+			return nil, errors.Errorf("field %q not found", strings.Join(path[:i+1], "."))
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+// SelectString returns value at selector converted by String
+func SelectString(doc interface{}, selector string) (string, error) {
+	v, err := Select(doc, selector)
+	if err != nil {
+		return "", err
+	}
+	return String(v)
+}
+
+// Extract decodes JSON data and returns value at selector converted by String
+func Extract(data []byte, selector string) (string, error) {
+	doc, err := Decode(data)
+	if err != nil {
+		return "", errors.Wrap(err, "value is not a JSON document")
+	}
+	return SelectString(doc, selector)
+}
+
+// Decode JSON document. Numbers are decoded as json.Number to keep their text form
+func Decode(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("invalid data after top-level value")
+	}
+	return doc, nil
+}
+
+// String converts selected value: strings are returned as is, scalars in text form,
+// objects and arrays as JSON
+func String(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32), nil
+	case bool, int, int64, int32, uint, uint64, uint32, json.Number:
+		return fmt.Sprint(val), nil
+	case []byte:
+		return string(val), nil
+	}
+	b, err := json.Marshal(normalize(v))
+	return string(b), err
+}
+
+// Parse splits selector into path segments
+func Parse(selector string) ([]string, error) {
+	s := strings.TrimSpace(selector)
+	s = strings.TrimPrefix(s, "$")
+	var path []string
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+		case '[':
+			end := strings.Index(s, "]")
+			if end < 0 {
+				return nil, errors.Errorf("unclosed bracket in selector %q", selector)
+			}
+			seg := s[1:end]
+			if len(seg) >= 2 && (seg[0] == '\'' || seg[0] == '"') && seg[len(seg)-1] == seg[0] {
+				seg = seg[1 : len(seg)-1]
+			}
+			path = append(path, seg)
+			s = s[end+1:]
+		default:
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			path = append(path, s[:end])
+			s = s[end:]
+		}
+	}
+	if len(path) == 0 {
+		return nil, errors.Errorf("empty selector %q", selector)
+	}
+	return path, nil
+}
+
+func step(cur interface{}, key string) (interface{}, bool) {
+	switch c := cur.(type) {
+	case map[string]interface{}:
+		v, ok := c[key]
+		return v, ok
+	case map[interface{}]interface{}:
+		v, ok := c[key]
+		return v, ok
+	case map[string]string:
+		v, ok := c[key]
+		return v, ok
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(c) {
+			return nil, false
+		}
+		return c[i], true
+	}
+	return nil, false
+}
+
+// normalize converts map[interface{}]interface{} produced by yaml decoders to JSON-compatible maps
+func normalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			m[fmt.Sprint(k)] = normalize(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			m[k] = normalize(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(val))
+		for i, e := range val {
+			a[i] = normalize(e)
+		}
+		return a
+	}
+	return v
+}
@@ -0,0 +1,82 @@
+package jsonpath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDoc = `{
+	"db": {"password": "secret", "port": 5432, "ssl": true, "hosts": ["db-1", "db-2"]},
+	"id": 123456789012, "big": 9007199254740993, "ratio": 0.25, "tiny": 1e-7,
+	"key.with.dots": {"v": null},
+	"list": [{"name": "a"}, {"name": "b"}]
+}`
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     string
+		wantErr  bool
+	}{
+		{selector: "db.password", want: "secret"},
+		{selector: "$.db.password", want: "secret"},
+		{selector: "db.port", want: "5432"},
+		{selector: "db.ssl", want: "true"},
+		{selector: "id", want: "123456789012"},
+		{selector: "big", want: "9007199254740993"},
+		{selector: "ratio", want: "0.25"},
+		{selector: "tiny", want: "1e-7"},
+		{selector: "db.hosts[1]", want: "db-2"},
+		{selector: "db.hosts.0", want: "db-1"},
+		{selector: "db.hosts", want: `["db-1","db-2"]`},
+		{selector: "$['key.with.dots'].v", want: ""},
+		{selector: `list[1]["name"]`, want: "b"},
+		{selector: "list[1]", want: `{"name":"b"}`},
+		{selector: "db.none", wantErr: true},
+		{selector: "db.hosts[5]", wantErr: true},
+		{selector: "db.password.x", wantErr: true},
+		{selector: "list[0", wantErr: true},
+		{selector: "$", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			got, err := Extract([]byte(testDoc), tt.selector)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	_, err := Extract([]byte("not json"), "a")
+	assert.Error(t, err)
+	_, err = Extract([]byte(`{"a":1} {"a":2}`), "a")
+	assert.Error(t, err)
+}
+
+func TestSelect_YAML(t *testing.T) {
+	doc := map[interface{}]interface{}{
+		"db": map[interface{}]interface{}{"user": "admin", 1: "one"},
+	}
+	v, err := SelectString(doc, "db")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"user":"admin","1":"one"}`, v)
+	v, err = SelectString(doc, "db.user")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", v)
+}
+
+func TestString_Float(t *testing.T) {
+	for v, want := range map[interface{}]string{
+		float64(123456789012): "123456789012",
+		0.25:                  "0.25",
+		float32(1.5):          "1.5",
+		float64(-3):           "-3",
+	} {
+		got, err := String(v)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+}
@@ -0,0 +1,155 @@
+// Package sigv4 signs HTTP requests with AWS Signature Version 4
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Formats of the signature dates
+const (
+	TimeFormat  = "20060102T150405Z"
+	ShortFormat = "20060102"
+)
+
+// Algorithm of the signature
+const Algorithm = "AWS4-HMAC-SHA256"
+
+// Key of the signer
+type Key struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Sign adds X-Amz-Date, X-Amz-Security-Token, X-Amz-Content-Sha256 (for s3) and Authorization headers.
+// All headers set before the call are signed
+func Sign(req *http.Request, body []byte, key Key, region, service string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(TimeFormat))
+	if key.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", key.SessionToken)
+	}
+	payloadHash := HashHex(body)
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	signed := []string{"host"}
+	for k := range req.Header {
+		if k = strings.ToLower(k); k != "authorization" && k != "user-agent" {
+			signed = append(signed, k)
+		}
+	}
+	sort.Strings(signed)
+	scope := Scope(now, region, service)
+	signature := Signature(req, signed, payloadHash, key.SecretAccessKey, scope, now)
+	req.Header.Set("Authorization", Algorithm+" Credential="+key.AccessKeyID+"/"+scope+
+		", SignedHeaders="+strings.Join(signed, ";")+", Signature="+signature)
+}
+
+// Scope of the credential: <date>/<region>/<service>/aws4_request
+func Scope(now time.Time, region, service string) string {
+	return strings.Join([]string{now.UTC().Format(ShortFormat), region, service, "aws4_request"}, "/")
+}
+
+// Signature returns hex encoded signature of the request with the sorted lowercase signed header names
+func Signature(req *http.Request, signed []string, payloadHash, secret, scope string, now time.Time) string {
+	canonical := CanonicalRequest(req, signed, payloadHash)
+	toSign := Algorithm + "\n" + now.UTC().Format(TimeFormat) + "\n" + scope + "\n" + HashHex([]byte(canonical))
+	k := []byte("AWS4" + secret)
+	for _, p := range strings.Split(scope, "/") {
+		k = hmacSHA256(k, p)
+	}
+	return hex.EncodeToString(hmacSHA256(k, toSign))
+}
+
+// CanonicalRequest returns canonical form of the request with the sorted lowercase signed header names
+func CanonicalRequest(req *http.Request, signed []string, payloadHash string) string {
+	var ch strings.Builder
+	for _, k := range signed {
+		v := host(req)
+		if k != "host" {
+			src := req.Header[textproto.CanonicalMIMEHeaderKey(k)]
+			vals := make([]string, len(src))
+			for i, s := range src {
+				vals[i] = strings.Join(strings.Fields(s), " ")
+			}
+			v = strings.Join(vals, ",")
+		}
+		ch.WriteString(k + ":" + v + "\n")
+	}
+	return strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		ch.String(),
+		strings.Join(signed, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+func host(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+func canonicalURI(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		return "/"
+	}
+	return p
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string{}, q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, Escape(k)+"="+Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// Escape string by the URI encoding rules of the signature: only A-Z a-z 0-9 - _ . ~ are kept
+func Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' ||
+			c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+	}
+	return b.String()
+}
+
+// HashHex returns hex encoded SHA-256 of data
+func HashHex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	_, _ = m.Write([]byte(data))
+	return m.Sum(nil)
+}
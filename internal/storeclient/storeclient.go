@@ -0,0 +1,57 @@
+// Package storeclient holds the client of the storage package: lazy initialization on the first read,
+// replacement by Init and closing of the replaced client.
+package storeclient
+
+import "sync"
+
+// Client of the storage
+type Client interface {
+	// Close releases resources of the client: idle connections, watchers, etc.
+	Close()
+}
+
+// Holder of the current client. Zero value is ready to use
+type Holder struct {
+	mu sync.Mutex
+	c  Client
+}
+
+// Set current client and close the previous one
+func (h *Holder) Set(c Client) {
+	h.mu.Lock()
+	prev := h.c
+	h.c = c
+	h.mu.Unlock()
+	if prev != nil {
+		prev.Close()
+	}
+}
+
+// Current client, nil if it is not initialized
+func (h *Holder) Current() Client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.c
+}
+
+// Get current client, calling init to set it if there is none
+func (h *Holder) Get(init func() error) (Client, error) {
+	if c := h.Current(); c != nil {
+		return c, nil
+	}
+	if err := init(); err != nil {
+		return nil, err
+	}
+	return h.Get(init)
+}
+
+// Reset closes and removes the current client. Next Get initializes new one
+func (h *Holder) Reset() {
+	h.mu.Lock()
+	prev := h.c
+	h.c = nil
+	h.mu.Unlock()
+	if prev != nil {
+		prev.Close()
+	}
+}
@@ -0,0 +1,51 @@
+package storeclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testClient struct {
+	closed int
+}
+
+func (c *testClient) Close() {
+	c.closed++
+}
+
+func TestHolder(t *testing.T) {
+	var h Holder
+	assert.Nil(t, h.Current())
+
+	fail := errors.New("fail")
+	_, err := h.Get(func() error { return fail })
+	assert.Equal(t, fail, err)
+
+	first := &testClient{}
+	inits := 0
+	init := func() error {
+		inits++
+		h.Set(first)
+		return nil
+	}
+	c, err := h.Get(init)
+	assert.NoError(t, err)
+	assert.Equal(t, first, c)
+	c, err = h.Get(init)
+	assert.NoError(t, err)
+	assert.Equal(t, first, c)
+	assert.Equal(t, 1, inits)
+
+	second := &testClient{}
+	h.Set(second)
+	assert.Equal(t, 1, first.closed, "replaced client is closed")
+	assert.Equal(t, second, h.Current())
+
+	h.Reset()
+	assert.Equal(t, 1, second.closed)
+	assert.Nil(t, h.Current())
+	h.Reset()
+	assert.Equal(t, 1, second.closed)
+}
@@ -0,0 +1,58 @@
+// Package tlsconf builds TLS client configs from certificate files
+package tlsconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// Files of the TLS client config. Empty fields are ignored
+type Files struct {
+	// CAFile trusted CA bundle for server certificates. System pool is used if empty
+	CAFile string
+	// CertFile and KeyFile client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// CAData, CertData and KeyData PEM contents used instead of the files
+	CAData   []byte
+	CertData []byte
+	KeyData  []byte
+	// InsecureSkipVerify disables server certificate verification
+	InsecureSkipVerify bool
+}
+
+// Load returns TLS client config
+func (f Files) Load() (*tls.Config, error) {
+	t := &tls.Config{InsecureSkipVerify: f.InsecureSkipVerify} // #nosec
+	ca := f.CAData
+	if len(ca) == 0 && f.CAFile != "" {
+		pem, err := ioutil.ReadFile(f.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read CA file")
+		}
+		ca = pem
+	}
+	if len(ca) > 0 {
+		t.RootCAs = x509.NewCertPool()
+		if !t.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no certificates in CA " + f.CAFile)
+		}
+	}
+	if len(f.CertData) > 0 || len(f.KeyData) > 0 {
+		cert, err := tls.X509KeyPair(f.CertData, f.KeyData)
+		if err != nil {
+			return nil, errors.Wrap(err, "load client certificate")
+		}
+		t.Certificates = []tls.Certificate{cert}
+	} else if f.CertFile != "" || f.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(f.CertFile, f.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "load client certificate")
+		}
+		t.Certificates = []tls.Certificate{cert}
+	}
+	return t, nil
+}
@@ -0,0 +1,79 @@
+// Package watch provides subscriber registry and retry backoff shared by storages which watch their values.
+package watch
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Subscriber receives watched key and its new value, empty string if deleted
+type Subscriber func(key, value string)
+
+// Registry of subscribers. Zero value is ready to use
+type Registry struct {
+	mu   sync.RWMutex
+	subs map[int]Subscriber
+	seq  int
+}
+
+// Subscribe fn to notifications. Returns function to unsubscribe
+func (r *Registry) Subscribe(fn Subscriber) func() {
+	r.mu.Lock()
+	if r.subs == nil {
+		r.subs = make(map[int]Subscriber)
+	}
+	r.seq++
+	id := r.seq
+	r.subs[id] = fn
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		delete(r.subs, id)
+		r.mu.Unlock()
+	}
+}
+
+// Notify all subscribers. Subscribers are called without lock and may unsubscribe
+func (r *Registry) Notify(key, value string) {
+	r.mu.RLock()
+	list := make([]Subscriber, 0, len(r.subs))
+	for _, fn := range r.subs {
+		list = append(list, fn)
+	}
+	r.mu.RUnlock()
+	for _, fn := range list {
+		fn(key, value)
+	}
+}
+
+// Backoff doubles delay between failed attempts from *Min up to *Max.
+// Bounds are read on every call, so package variables of the storages stay configurable
+type Backoff struct {
+	Min   *time.Duration
+	Max   *time.Duration
+	delay time.Duration
+}
+
+// Reset delay to Min after successful attempt
+func (b *Backoff) Reset() {
+	b.delay = 0
+}
+
+// Wait current delay and double it. Returns false if ctx is done
+func (b *Backoff) Wait(ctx context.Context) bool {
+	if b.delay == 0 {
+		b.delay = *b.Min
+	}
+	t := time.NewTimer(b.delay)
+	defer t.Stop()
+	if b.delay *= 2; b.delay > *b.Max {
+		b.delay = *b.Max
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,37 @@
+package watch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	var r Registry
+	var got []string
+	unsubscribe := r.Subscribe(func(key, value string) { got = append(got, key+"="+value) })
+	r.Notify("a", "1")
+	unsubscribe()
+	r.Notify("b", "2")
+	assert.Equal(t, []string{"a=1"}, got)
+}
+
+func TestBackoff(t *testing.T) {
+	min, max := time.Millisecond, 4*time.Millisecond
+	b := Backoff{Min: &min, Max: &max}
+	for _, want := range []time.Duration{2, 4, 4} {
+		assert.True(t, b.Wait(context.Background()))
+		assert.Equal(t, want*time.Millisecond, b.delay)
+	}
+	b.Reset()
+	assert.True(t, b.Wait(context.Background()))
+	assert.Equal(t, 2*time.Millisecond, b.delay)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	max = time.Hour
+	b.delay = time.Hour
+	assert.False(t, b.Wait(ctx))
+}
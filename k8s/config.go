@@ -0,0 +1,73 @@
+package k8s
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Config of the mounted secrets reader
+type Config struct {
+	// Root directory of the secret volume mounts. Keys are relative to it
+	Root string
+	// PollInterval of the "..data" symlink checks. Without polling swaps are detected on read
+	PollInterval time.Duration
+}
+
+// DefaultRoot of the secret mounts. Overridden by NOBLE_K8S_ROOT environment variable
+const DefaultRoot = "/var/run/secrets"
+
+//nolint:gochecknoglobals
+var (
+	storeMu sync.Mutex
+	store   *secretStore
+)
+
+// Init mounted secrets reader. Default config used if cfg is nil or Init was not called before the first read
+func Init(cfg *Config) error {
+	if cfg == nil {
+		cfg = &Config{Root: os.Getenv("NOBLE_K8S_ROOT")}
+	}
+	c := *cfg
+	if c.Root == "" {
+		c.Root = DefaultRoot
+	}
+	root, err := filepath.Abs(c.Root)
+	if err != nil {
+		return err
+	}
+	c.Root = root
+	s := newStore(c)
+	storeMu.Lock()
+	prev := store
+	store = s
+	storeMu.Unlock()
+	if prev != nil {
+		prev.stop()
+	}
+	return nil
+}
+
+func getStore() (*secretStore, error) {
+	storeMu.Lock()
+	s := store
+	storeMu.Unlock()
+	if s != nil {
+		return s, nil
+	}
+	if err := Init(nil); err != nil {
+		return nil, err
+	}
+	return getStore()
+}
+
+// Close stops polling of the current reader
+func Close() {
+	storeMu.Lock()
+	s := store
+	storeMu.Unlock()
+	if s != nil {
+		s.stop()
+	}
+}
@@ -0,0 +1,116 @@
+// Package k8stest writes secret volumes the way kubelet does, for tests.
+//
+// Layout of the volume:
+//
+//	..2026_01_02_15_04_05.000000001/password - current version
+//	..data -> ..2026_01_02_15_04_05.000000001
+//	password -> ..data/password
+package k8stest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Volume secret volume directory
+type Volume struct {
+	// Dir of the volume
+	Dir string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewVolume creates volume in dir with data. Dir is created if missing
+func NewVolume(dir string, data map[string]string) (*Volume, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	v := &Volume{Dir: dir}
+	return v, v.Update(data)
+}
+
+// Start creates volume named name in root with data. Fails the test on error
+func Start(t testing.TB, root, name string, data map[string]string) *Volume {
+	v, err := NewVolume(filepath.Join(root, name), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// Update atomically replaces volume content with data: writes new version directory,
+// swaps "..data" symlink, updates key symlinks and removes the previous version
+func (v *Volume) Update(data map[string]string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.seq++
+	version := fmt.Sprintf("..%s.%09d", time.Now().UTC().Format("2006_01_02_15_04_05"), v.seq)
+	if err := os.Mkdir(filepath.Join(v.Dir, version), 0755); err != nil {
+		return err
+	}
+	for k, val := range data {
+		name := filepath.Join(v.Dir, version, k)
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(name, []byte(val), 0644); err != nil {
+			return err
+		}
+	}
+	prev, _ := os.Readlink(filepath.Join(v.Dir, "..data"))
+	tmp := filepath.Join(v.Dir, "..data_tmp")
+	if err := os.Symlink(version, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(v.Dir, "..data")); err != nil {
+		return err
+	}
+	if err := v.link(data, prev); err != nil {
+		return err
+	}
+	if prev != "" {
+		return os.RemoveAll(filepath.Join(v.Dir, prev))
+	}
+	return nil
+}
+
+// link creates symlinks of the top level keys and removes the ones missing in data
+func (v *Volume) link(data map[string]string, prev string) error {
+	top := make(map[string]bool, len(data))
+	for k := range data {
+		top[firstElem(k)] = true
+	}
+	for name := range top {
+		l := filepath.Join(v.Dir, name)
+		if _, err := os.Lstat(l); err == nil {
+			continue
+		}
+		if err := os.Symlink(filepath.Join("..data", name), l); err != nil {
+			return err
+		}
+	}
+	if prev == "" {
+		return nil
+	}
+	old, err := ioutil.ReadDir(filepath.Join(v.Dir, prev))
+	if err != nil {
+		return err
+	}
+	for _, fi := range old {
+		if !top[fi.Name()] {
+			_ = os.Remove(filepath.Join(v.Dir, fi.Name()))
+		}
+	}
+	return nil
+}
+
+func firstElem(p string) string {
+	return strings.SplitN(filepath.ToSlash(p), "/", 2)[0]
+}
@@ -0,0 +1,58 @@
+// Package k8s Noble reader of the Kubernetes secret volumes
+package k8s
+
+import (
+	"path"
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/jsonpath"
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("k8s", &KeyReader{})
+}
+
+// KeyReader type implements noble.SecretStorage
+//
+// Key format: <volume>/<key>[#<field>], relative to Config.Root
+//
+//	k8s:db-creds/password - whole value of the "password" key of the volume mounted at <root>/db-creds
+//	k8s:app/config.json#db.password - field of the JSON value
+//
+// Values of the volumes written by kubelet (with "..data" symlink) are cached
+// until the symlink is swapped, see Subscribe
+type KeyReader struct {
+}
+
+// Read value of the mounted secret key
+func (r *KeyReader) Read(key string) (string, error) {
+	s, err := getStore()
+	if err != nil {
+		return "", err
+	}
+	var field string
+	if i := strings.Index(key, "#"); i >= 0 {
+		key, field = key[:i], key[i+1:]
+	}
+	key = path.Clean("/" + key)[1:]
+	if key == "" || strings.Contains(key, DataLink) {
+		return "", errors.New("incorrect key format. use <volume>/<key>[#<field>]")
+	}
+	val, err := s.read(key)
+	if err != nil {
+		return "", errors.Wrap(err, "k8s secret "+key)
+	}
+	if field == "" {
+		return val, nil
+	}
+	val, err = jsonpath.Extract([]byte(val), field)
+	return val, errors.Wrap(err, "k8s secret "+key)
+}
+
+// Clone returns new empty instance of KeyReader
+func (r *KeyReader) Clone() noble.SecretStorage {
+	return &KeyReader{}
+}
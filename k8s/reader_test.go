@@ -0,0 +1,109 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lancer-kit/noble/k8s/k8stest"
+	"github.com/lancer-kit/noble/nobletest"
+)
+
+func initRoot(t *testing.T, cfg Config) string {
+	dir, err := ioutil.TempDir("", "k8s")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	cfg.Root = dir
+	if !assert.NoError(t, Init(&cfg)) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		Close()
+		store = nil
+		_ = os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func TestKeyReader_Read(t *testing.T) {
+	root := initRoot(t, Config{})
+	k8stest.Start(t, root, "db-creds", map[string]string{
+		"password":    "secret\n",
+		"config.json": `{"db":{"user":"admin"}}`,
+	})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "plain"), []byte("plain value"), 0600))
+
+	r := KeyReader{}
+	v, err := r.Read("db-creds/password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret\n", v, "whole value")
+	v, err = r.Read("db-creds/config.json#db.user")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", v)
+	v, err = r.Read("plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain value", v)
+
+	_, err = r.Read("db-creds/none")
+	assert.Error(t, err)
+	_, err = r.Read("db-creds/..data/password")
+	assert.Error(t, err)
+	_, err = r.Read("../../etc/passwd")
+	assert.Error(t, err)
+
+	var c nobletest.Config
+	assert.NoError(t, yaml.Unmarshal([]byte(`secret: "k8s:db-creds/config.json#db.user"`), &c))
+	assert.NoError(t, c.Secret.InternalError())
+	assert.Equal(t, "admin", c.Secret.Get())
+}
+
+func TestKeyReader_Swap(t *testing.T) {
+	root := initRoot(t, Config{})
+	vol := k8stest.Start(t, root, "db-creds", map[string]string{"user": "admin", "password": "secret"})
+	events := make(chan change, 10)
+	defer Subscribe(func(key, value string) { events <- change{key: key, value: value} })()
+
+	r := KeyReader{}
+	_, err := r.Read("db-creds/user")
+	assert.NoError(t, err)
+	v, err := r.Read("db-creds/password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+
+	assert.NoError(t, vol.Update(map[string]string{"user": "admin", "password": "rotated"}))
+	v, err = r.Read("db-creds/password")
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated", v)
+	assert.Equal(t, change{key: "db-creds/password", value: "rotated"}, <-events)
+	assert.Empty(t, events, "unchanged keys are not reported")
+
+	assert.NoError(t, vol.Update(map[string]string{"user": "admin"}))
+	_, err = r.Read("db-creds/password")
+	assert.Error(t, err)
+	assert.Equal(t, change{key: "db-creds/password"}, <-events)
+}
+
+func TestPollInterval(t *testing.T) {
+	root := initRoot(t, Config{PollInterval: 10 * time.Millisecond})
+	vol := k8stest.Start(t, root, "api", map[string]string{"token": "t-1"})
+	events := make(chan change, 10)
+	defer Subscribe(func(key, value string) { events <- change{key: key, value: value} })()
+
+	v, err := (&KeyReader{}).Read("api/token")
+	assert.NoError(t, err)
+	assert.Equal(t, "t-1", v)
+
+	assert.NoError(t, vol.Update(map[string]string{"token": "t-2"}))
+	select {
+	case ev := <-events:
+		assert.Equal(t, change{key: "api/token", value: "t-2"}, ev)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change event")
+	}
+}
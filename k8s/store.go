@@ -0,0 +1,207 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/watch"
+)
+
+// DataLink name of the symlink to the current version of the volume written by kubelet
+const DataLink = "..data"
+
+// Subscriber receives key and its new value after the volume update, empty string if removed
+type Subscriber = watch.Subscriber
+
+//nolint:gochecknoglobals
+var subs watch.Registry
+
+// Subscribe fn to changes of the keys read before. Returns function to unsubscribe
+func Subscribe(fn Subscriber) func() {
+	return subs.Subscribe(fn)
+}
+
+type change struct {
+	key   string
+	value string
+}
+
+func notify(changes []change) {
+	for _, c := range changes {
+		subs.Notify(c.key, c.value)
+	}
+}
+
+type cached struct {
+	mount string
+	rel   string
+	value string
+}
+
+// secretStore caches values of the volumes with DataLink until the link is swapped
+type secretStore struct {
+	cfg Config
+
+	mu     sync.Mutex
+	mounts map[string]string  // mount dir -> DataLink target
+	values map[string]*cached // key -> value
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	done     chan struct{}
+}
+
+func newStore(cfg Config) *secretStore {
+	s := &secretStore{
+		cfg:    cfg,
+		mounts: make(map[string]string),
+		values: make(map[string]*cached),
+		stopCh: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if cfg.PollInterval > 0 {
+		go s.poll()
+	} else {
+		close(s.done)
+	}
+	return s
+}
+
+func (s *secretStore) stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+	<-s.done
+}
+
+// read value of the key relative to the root
+func (s *secretStore) read(key string) (string, error) {
+	path := filepath.Join(s.cfg.Root, key)
+	mount := findMount(s.cfg.Root, filepath.Dir(path))
+	if mount == "" {
+		b, err := ioutil.ReadFile(path)
+		return string(b), err
+	}
+	rel, err := filepath.Rel(mount, path)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	changes, err := s.sync(mount)
+	if err != nil {
+		s.mu.Unlock()
+		notify(changes)
+		return "", err
+	}
+	if v, ok := s.values[key]; ok {
+		s.mu.Unlock()
+		notify(changes)
+		return v.value, nil
+	}
+	val, err := s.load(mount, rel, &changes)
+	if err == nil {
+		s.values[key] = &cached{mount: mount, rel: rel, value: val}
+	}
+	s.mu.Unlock()
+	notify(changes)
+	return val, err
+}
+
+// load file of the current volume version. Kubelet removes the old version right after the swap,
+// so missing file is retried with the new DataLink target. Requires lock
+func (s *secretStore) load(mount, rel string, changes *[]change) (string, error) {
+	for i := 0; ; i++ {
+		b, err := ioutil.ReadFile(filepath.Join(mount, s.mounts[mount], rel))
+		if err == nil || !os.IsNotExist(err) || i == 2 {
+			return string(b), err
+		}
+		prev := s.mounts[mount]
+		c, serr := s.sync(mount)
+		*changes = append(*changes, c...)
+		if serr != nil {
+			return "", serr
+		}
+		if s.mounts[mount] == prev {
+			return "", err
+		}
+	}
+}
+
+// sync reads DataLink of the mount and reloads cached values if it was swapped. Requires lock
+func (s *secretStore) sync(mount string) ([]change, error) {
+	target, err := os.Readlink(filepath.Join(mount, DataLink))
+	if err != nil {
+		return s.drop(mount), err
+	}
+	prev, ok := s.mounts[mount]
+	s.mounts[mount] = target
+	if !ok || prev == target {
+		return nil, nil
+	}
+	var changes []change
+	for key, v := range s.values {
+		if v.mount != mount {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(mount, target, v.rel))
+		if err != nil {
+			delete(s.values, key)
+			changes = append(changes, change{key: key})
+			continue
+		}
+		if string(b) != v.value {
+			v.value = string(b)
+			changes = append(changes, change{key: key, value: v.value})
+		}
+	}
+	return changes, nil
+}
+
+// drop cached values of the removed mount. Requires lock
+func (s *secretStore) drop(mount string) []change {
+	delete(s.mounts, mount)
+	var changes []change
+	for key, v := range s.values {
+		if v.mount == mount {
+			delete(s.values, key)
+			changes = append(changes, change{key: key})
+		}
+	}
+	return changes
+}
+
+func (s *secretStore) poll() {
+	defer close(s.done)
+	t := time.NewTicker(s.cfg.PollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-t.C:
+		}
+		s.mu.Lock()
+		var changes []change
+		for mount := range s.mounts {
+			c, _ := s.sync(mount)
+			changes = append(changes, c...)
+		}
+		s.mu.Unlock()
+		notify(changes)
+	}
+}
+
+// findMount returns the nearest directory between dir and root with DataLink
+func findMount(root, dir string) string {
+	for {
+		if fi, err := os.Lstat(filepath.Join(dir, DataLink)); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			return dir
+		}
+		if dir == root || len(dir) <= len(root) {
+			return ""
+		}
+		dir = filepath.Dir(dir)
+	}
+}
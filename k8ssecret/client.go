@@ -0,0 +1,158 @@
+package k8ssecret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/lancer-kit/noble/internal/tlsconf"
+	"github.com/pkg/errors"
+)
+
+type apiClient struct {
+	cfg  Config
+	http *http.Client
+	// watch client without timeout for watch streams
+	watch *http.Client
+
+	wmu      sync.Mutex
+	watchers map[string]*watcher
+}
+
+// apiError Status response of the API server
+type apiError struct {
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("kubernetes api error (status %d)", e.Code)
+	}
+	return fmt.Sprintf("kubernetes api error (status %d): %s", e.Code, e.Message)
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.Code == http.StatusNotFound
+}
+
+// secret object of the core/v1 API
+type secret struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Data map[string][]byte `json:"data"`
+}
+
+func newClient(cfg Config) (*apiClient, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("kubernetes api host is not set")
+	}
+	if !strings.Contains(cfg.Host, "://") {
+		cfg.Host = "https://" + cfg.Host
+	}
+	cfg.Host = strings.TrimRight(cfg.Host, "/")
+	if cfg.Namespace == "" {
+		cfg.Namespace = DefaultNamespace
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	tlsCfg, err := tlsconf.Files{
+		CAFile:             cfg.CAFile,
+		CAData:             cfg.CAData,
+		CertFile:           cfg.CertFile,
+		KeyFile:            cfg.KeyFile,
+		CertData:           cfg.CertData,
+		KeyData:            cfg.KeyData,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}.Load()
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{TLSClientConfig: tlsCfg, Proxy: http.ProxyFromEnvironment}
+	return &apiClient{
+		cfg:      cfg,
+		http:     &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		watch:    &http.Client{Transport: transport},
+		watchers: make(map[string]*watcher),
+	}, nil
+}
+
+func secretsPath(namespace string) string {
+	return "/api/v1/namespaces/" + url.PathEscape(namespace) + "/secrets"
+}
+
+// Close stops watchers and closes idle connections of the client
+func (c *apiClient) Close() {
+	c.stopWatchers()
+	c.http.CloseIdleConnections()
+}
+
+// getSecret reads Secret object
+func (c *apiClient) getSecret(ctx context.Context, namespace, name string) (*secret, error) {
+	res, err := c.do(ctx, c.http, secretsPath(namespace)+"/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	var s secret
+	if err := json.NewDecoder(res.Body).Decode(&s); err != nil {
+		return nil, errors.Wrap(err, "invalid secret object")
+	}
+	return &s, nil
+}
+
+// do GET request and returns response with status 200. Caller must close the body
+func (c *apiClient) do(ctx context.Context, hc *http.Client, path string, query url.Values) (*http.Response, error) {
+	u := c.cfg.Host + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	token, err := c.token()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusOK {
+		return res, nil
+	}
+	defer func() { _ = res.Body.Close() }()
+	e := &apiError{}
+	data, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1<<16))
+	_ = json.Unmarshal(data, e)
+	e.Code = res.StatusCode
+	return nil, e
+}
+
+func (c *apiClient) token() (string, error) {
+	if c.cfg.TokenFile == "" {
+		return c.cfg.Token, nil
+	}
+	b, err := ioutil.ReadFile(c.cfg.TokenFile)
+	if err != nil {
+		return "", errors.Wrap(err, "read token file")
+	}
+	return strings.TrimSpace(string(b)), nil
+}
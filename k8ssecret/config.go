@@ -0,0 +1,234 @@
+package k8ssecret
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/storeclient"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Config of the Kubernetes API client
+type Config struct {
+	// Host URL of the API server, e.g. "https://10.0.0.1:443"
+	Host string
+	// Token bearer token. TokenFile is re-read on every request to follow rotated service account tokens
+	Token     string
+	TokenFile string
+	// CAFile or CAData (PEM) trusted CA bundle for the API server certificate
+	CAFile string
+	CAData []byte
+	// CertFile and KeyFile or CertData and KeyData (PEM) client certificate
+	CertFile           string
+	KeyFile            string
+	CertData           []byte
+	KeyData            []byte
+	InsecureSkipVerify bool
+	// Namespace of the keys without namespace
+	Namespace string
+	// Timeout of a single request
+	Timeout time.Duration
+	// Watch every secret read by KeyReader: values are served from the local cache
+	// which is updated by watch requests. See Watch and Subscribe
+	Watch bool
+}
+
+// Defaults of the config
+const (
+	DefaultTimeout   = 10 * time.Second
+	DefaultNamespace = "default"
+)
+
+//nolint:gochecknoglobals
+var (
+	clients storeclient.Holder
+	// serviceAccountDir mounted into every pod
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// Init Kubernetes API client. DefaultConfig used if cfg is nil or Init was not called before the first read
+func Init(cfg *Config) error {
+	if cfg == nil {
+		c, err := DefaultConfig()
+		if err != nil {
+			return err
+		}
+		cfg = &c
+	}
+	c, err := newClient(*cfg)
+	if err != nil {
+		return err
+	}
+	clients.Set(c)
+	return nil
+}
+
+func getClient() (*apiClient, error) {
+	c, err := clients.Get(func() error { return Init(nil) })
+	if err != nil {
+		return nil, err
+	}
+	return c.(*apiClient), nil
+}
+
+// DefaultConfig returns InClusterConfig inside a pod (KUBERNETES_SERVICE_HOST is set),
+// otherwise KubeconfigConfig of the current context
+func DefaultConfig() (Config, error) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return InClusterConfig()
+	}
+	return KubeconfigConfig("", "")
+}
+
+// InClusterConfig returns config of the pod service account
+func InClusterConfig() (Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return Config{}, errors.New("not running in cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+	}
+	tokenFile := filepath.Join(serviceAccountDir, "token")
+	if _, err := os.Stat(tokenFile); err != nil {
+		return Config{}, errors.Wrap(err, "service account token")
+	}
+	cfg := Config{
+		Host:      "https://" + net.JoinHostPort(host, port),
+		TokenFile: tokenFile,
+		CAFile:    filepath.Join(serviceAccountDir, "ca.crt"),
+		Namespace: DefaultNamespace,
+		Timeout:   DefaultTimeout,
+	}
+	if ns, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "namespace")); err == nil {
+		cfg.Namespace = strings.TrimSpace(string(ns))
+	}
+	return cfg, nil
+}
+
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// KubeconfigConfig returns config of the context (current-context if empty) from the kubeconfig file.
+// Path defaults to the first file of KUBECONFIG or ~/.kube/config. Exec and auth-provider plugins are not supported
+func KubeconfigConfig(path, context string) (Config, error) {
+	if path == "" {
+		path = kubeconfigPath()
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, errors.Wrap(err, "read kubeconfig")
+	}
+	var kc kubeconfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return Config{}, errors.Wrap(err, "parse kubeconfig "+path)
+	}
+	if context == "" {
+		context = kc.CurrentContext
+	}
+	cfg := Config{Namespace: DefaultNamespace, Timeout: DefaultTimeout}
+	var clusterName, userName string
+	found := false
+	for _, c := range kc.Contexts {
+		if c.Name == context {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+			if c.Context.Namespace != "" {
+				cfg.Namespace = c.Context.Namespace
+			}
+		}
+	}
+	if !found {
+		return Config{}, errors.New("kubeconfig context not found: " + context)
+	}
+	dir := filepath.Dir(path)
+	found = false
+	for _, c := range kc.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		found = true
+		cfg.Host = strings.TrimRight(c.Cluster.Server, "/")
+		cfg.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify
+		cfg.CAFile = resolve(dir, c.Cluster.CertificateAuthority)
+		if cfg.CAData, err = decodeData(c.Cluster.CertificateAuthorityData); err != nil {
+			return Config{}, err
+		}
+	}
+	if !found {
+		return Config{}, errors.New("kubeconfig cluster not found: " + clusterName)
+	}
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		cfg.Token = u.User.Token
+		cfg.TokenFile = resolve(dir, u.User.TokenFile)
+		cfg.CertFile = resolve(dir, u.User.ClientCertificate)
+		cfg.KeyFile = resolve(dir, u.User.ClientKey)
+		if cfg.CertData, err = decodeData(u.User.ClientCertificateData); err != nil {
+			return Config{}, err
+		}
+		if cfg.KeyData, err = decodeData(u.User.ClientKeyData); err != nil {
+			return Config{}, err
+		}
+	}
+	return cfg, nil
+}
+
+func kubeconfigPath() string {
+	for _, p := range filepath.SplitList(os.Getenv("KUBECONFIG")) {
+		if p != "" {
+			return p
+		}
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".kube", "config")
+}
+
+// resolve file path relative to the kubeconfig directory
+func resolve(dir, name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+func decodeData(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	return b, errors.Wrap(err, "invalid kubeconfig data")
+}
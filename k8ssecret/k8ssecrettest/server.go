@@ -0,0 +1,276 @@
+// Package k8ssecrettest provides in-process fake of the Kubernetes API server Secrets for tests.
+//
+// Implemented subset: GET of core/v1 Secrets, list and watch with metadata.name field selector,
+// bearer token authentication.
+package k8ssecrettest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// DefaultHistorySize count of the events kept for watch requests
+const DefaultHistorySize = 100
+
+// Secret object of the core/v1 API
+type Secret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   Metadata          `json:"metadata"`
+	Type       string            `json:"type"`
+	Data       map[string][]byte `json:"data"`
+}
+
+// Metadata of the object
+type Metadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+type event struct {
+	Type    string      `json:"type"`
+	Object  interface{} `json:"object"`
+	version uint64
+	ns      string
+	name    string
+}
+
+// Server fake API server
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	version  uint64
+	secrets  map[string]*Secret
+	tokens   map[string]bool
+	requests int
+	history  []event
+	changed  chan struct{}
+	// HistorySize count of the events kept for watch requests
+	HistorySize int
+}
+
+// NewServer starts fake API server. Caller must Close it
+func NewServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSServer starts fake API server with TLS
+func NewTLSServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+// Start fake API server closed on test cleanup
+func Start(t testing.TB) *Server {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newServer() *Server {
+	return &Server{
+		version:     1,
+		secrets:     make(map[string]*Secret),
+		changed:     make(chan struct{}),
+		HistorySize: DefaultHistorySize,
+	}
+}
+
+// AddToken enables authentication: only requests with one of the added bearer tokens are allowed
+func (s *Server) AddToken(token string) *Server {
+	s.mu.Lock()
+	if s.tokens == nil {
+		s.tokens = make(map[string]bool)
+	}
+	s.tokens[token] = true
+	s.mu.Unlock()
+	return s
+}
+
+// Requests returns count of handled requests
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Set creates or replaces Secret data. Returns resource version
+func (s *Server) Set(namespace, name string, data map[string]string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	typ := "MODIFIED"
+	sec, ok := s.secrets[namespace+"/"+name]
+	if !ok {
+		typ = "ADDED"
+		sec = &Secret{APIVersion: "v1", Kind: "Secret", Type: "Opaque",
+			Metadata: Metadata{Name: name, Namespace: namespace}}
+		s.secrets[namespace+"/"+name] = sec
+	}
+	sec.Data = make(map[string][]byte, len(data))
+	for k, v := range data {
+		sec.Data[k] = []byte(v)
+	}
+	sec.Metadata.ResourceVersion = strconv.FormatUint(s.version, 10)
+	s.record(typ, sec)
+	return sec.Metadata.ResourceVersion
+}
+
+// Delete Secret
+func (s *Server) Delete(namespace, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec, ok := s.secrets[namespace+"/"+name]
+	if !ok {
+		return
+	}
+	delete(s.secrets, namespace+"/"+name)
+	s.version++
+	sec.Metadata.ResourceVersion = strconv.FormatUint(s.version, 10)
+	s.record("DELETED", sec)
+}
+
+// record event for watch requests. Requires lock
+func (s *Server) record(typ string, sec *Secret) {
+	c := *sec
+	s.history = append(s.history, event{Type: typ, Object: &c, version: s.version,
+		ns: sec.Metadata.Namespace, name: sec.Metadata.Name})
+	if len(s.history) > s.HistorySize {
+		s.history = s.history[len(s.history)-s.HistorySize:]
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	if s.tokens != nil && !s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] {
+		s.mu.Unlock()
+		writeStatus(w, http.StatusUnauthorized, "Unauthorized", "Unauthorized")
+		return
+	}
+	// /api/v1/namespaces/{ns}/secrets[/{name}]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if r.Method != http.MethodGet || len(parts) < 5 || len(parts) > 6 ||
+		strings.Join(parts[:3], "/") != "api/v1/namespaces" || parts[4] != "secrets" {
+		s.mu.Unlock()
+		writeStatus(w, http.StatusNotFound, "NotFound", "the server could not find the requested resource")
+		return
+	}
+	ns := parts[3]
+	if len(parts) == 6 {
+		defer s.mu.Unlock()
+		sec, ok := s.secrets[ns+"/"+parts[5]]
+		if !ok {
+			writeStatus(w, http.StatusNotFound, "NotFound", `secrets "`+parts[5]+`" not found`)
+			return
+		}
+		writeJSON(w, http.StatusOK, sec)
+		return
+	}
+	q := r.URL.Query()
+	name := strings.TrimPrefix(q.Get("fieldSelector"), "metadata.name=")
+	if q.Get("watch") == "true" || q.Get("watch") == "1" {
+		s.watch(w, r, ns, name)
+		return
+	}
+	defer s.mu.Unlock()
+	items := s.list(ns, name)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "SecretList",
+		"metadata":   map[string]string{"resourceVersion": strconv.FormatUint(s.version, 10)},
+		"items":      items,
+	})
+}
+
+// list Secrets of the namespace, all if name is empty. Requires lock
+func (s *Server) list(ns, name string) []*Secret {
+	var items []*Secret
+	for _, sec := range s.secrets {
+		if sec.Metadata.Namespace == ns && (name == "" || sec.Metadata.Name == name) {
+			items = append(items, sec)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Metadata.Name < items[j].Metadata.Name })
+	return items
+}
+
+// watch streams events after resourceVersion until timeoutSeconds. Without resourceVersion
+// current objects are sent as ADDED first. Requires lock, releases it
+func (s *Server) watch(w http.ResponseWriter, r *http.Request, ns, name string) {
+	q := r.URL.Query()
+	version, _ := strconv.ParseUint(q.Get("resourceVersion"), 10, 64)
+	timeout := 30 * time.Minute
+	if sec, err := strconv.Atoi(q.Get("timeoutSeconds")); err == nil && sec > 0 {
+		timeout = time.Duration(sec) * time.Second
+	}
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	send := func(ev event) {
+		_ = enc.Encode(ev)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if version == 0 {
+		for _, sec := range s.list(ns, name) {
+			send(event{Type: "ADDED", Object: sec})
+		}
+		version = s.version
+	} else if len(s.history) >= s.HistorySize && version+1 < s.history[0].version {
+		s.mu.Unlock()
+		send(event{Type: "ERROR", Object: map[string]interface{}{
+			"kind": "Status", "status": "Failure", "reason": "Expired", "code": http.StatusGone,
+			"message": "too old resource version: " + q.Get("resourceVersion"),
+		}})
+		return
+	}
+	deadline := time.After(timeout)
+	for {
+		for _, ev := range s.history {
+			if ev.version > version && ev.ns == ns && (name == "" || ev.name == name) {
+				send(ev)
+			}
+		}
+		version = s.version
+		ch := s.changed
+		s.mu.Unlock()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline:
+			return
+		case <-ch:
+		}
+		s.mu.Lock()
+	}
+}
+
+func writeStatus(w http.ResponseWriter, code int, reason, msg string) {
+	writeJSON(w, code, map[string]interface{}{
+		"apiVersion": "v1", "kind": "Status", "status": "Failure", "reason": reason, "message": msg, "code": code,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,100 @@
+// Package k8ssecret Noble reader of the Kubernetes Secret objects
+package k8ssecret
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("k8ssecret", &KeyReader{})
+}
+
+// KeyReader type implements noble.SecretStorage
+//
+// Key format: [<namespace>/]<name>[#<key>]
+//
+//	k8ssecret:payments/db-creds#password - decoded value of the "password" key
+//	k8ssecret:db-creds#password - secret from Config.Namespace
+//	k8ssecret:payments/db-creds - JSON object of all decoded keys
+type KeyReader struct {
+}
+
+// Read Secret key from the API server
+func (r *KeyReader) Read(key string) (string, error) {
+	c, err := getClient()
+	if err != nil {
+		return "", err
+	}
+	namespace, name, field, err := parseKey(key, c.cfg.Namespace)
+	if err != nil {
+		return "", err
+	}
+	s, err := c.read(namespace, name)
+	if err != nil {
+		return "", errors.Wrap(err, "k8s secret "+namespace+"/"+name)
+	}
+	if field == "" {
+		return secretValue(s), nil
+	}
+	v, ok := s.Data[field]
+	if !ok {
+		return "", errors.New("k8s secret " + namespace + "/" + name + " has no key " + field)
+	}
+	return string(v), nil
+}
+
+// parseKey splits key into namespace, Secret name and data key
+func parseKey(key, namespace string) (ns, name, field string, err error) {
+	if i := strings.Index(key, "#"); i >= 0 {
+		key, field = key[:i], key[i+1:]
+	}
+	parts := strings.Split(key, "/")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return namespace, parts[0], field, nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return parts[0], parts[1], field, nil
+	}
+	return "", "", "", errors.New("incorrect key format. use [<namespace>/]<name>[#<key>]")
+}
+
+// secretValue returns JSON object of the decoded data
+func secretValue(s *secret) string {
+	m := make(map[string]string, len(s.Data))
+	for k, v := range s.Data {
+		m[k] = string(v)
+	}
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+// read returns Secret from the watcher cache or from the API
+func (c *apiClient) read(namespace, name string) (*secret, error) {
+	id := namespace + "/" + name
+	c.wmu.Lock()
+	w, ok := c.watchers[id]
+	c.wmu.Unlock()
+	if !ok && !c.cfg.Watch {
+		return c.getSecret(context.Background(), namespace, name)
+	}
+	if !ok {
+		var err error
+		if w, err = c.watcher(namespace, name); err != nil {
+			return nil, err
+		}
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.secret, w.err
+}
+
+// Clone returns new empty instance of KeyReader
+func (r *KeyReader) Clone() noble.SecretStorage {
+	return &KeyReader{}
+}
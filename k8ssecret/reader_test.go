@@ -0,0 +1,229 @@
+package k8ssecret
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lancer-kit/noble/k8ssecret/k8ssecrettest"
+	"github.com/lancer-kit/noble/nobletest"
+)
+
+func initClient(t *testing.T, cfg *Config) {
+	nobletest.InitClient(t, func() error { return Init(cfg) }, clients.Reset)
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "k8ssecret")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+func TestKeyReader_Read(t *testing.T) {
+	srv := k8ssecrettest.Start(t)
+	srv.Set("payments", "db-creds", map[string]string{"user": "admin", "password": "secret", "tls.crt": "cert"})
+	srv.Set("default", "api", map[string]string{"token": "t-1"})
+	initClient(t, &Config{Host: srv.URL})
+
+	r := KeyReader{}
+	v, err := r.Read("payments/db-creds#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+	v, err = r.Read("payments/db-creds#tls.crt")
+	assert.NoError(t, err)
+	assert.Equal(t, "cert", v)
+	v, err = r.Read("payments/db-creds")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"user":"admin","password":"secret","tls.crt":"cert"}`, v)
+	v, err = r.Read("api#token")
+	assert.NoError(t, err)
+	assert.Equal(t, "t-1", v)
+
+	_, err = r.Read("payments/db-creds#none")
+	assert.Error(t, err)
+	_, err = r.Read("payments/none#password")
+	assert.Error(t, err)
+	_, err = r.Read("a/b/c")
+	assert.Error(t, err)
+
+	var c nobletest.Config
+	assert.NoError(t, yaml.Unmarshal([]byte(`secret: "k8ssecret:payments/db-creds#user"`), &c))
+	assert.NoError(t, c.Secret.InternalError())
+	assert.Equal(t, "admin", c.Secret.Get())
+}
+
+func TestInClusterConfig(t *testing.T) {
+	srv := k8ssecrettest.NewTLSServer()
+	defer srv.Close()
+	srv.AddToken("sa-token-1").AddToken("sa-token-2")
+	srv.Set("payments", "db-creds", map[string]string{"password": "secret"})
+
+	dir := tempDir(t)
+	prev := serviceAccountDir
+	serviceAccountDir = dir
+	defer func() { serviceAccountDir = prev }()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ca.crt"), ca, 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "token"), []byte("sa-token-1"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "namespace"), []byte("payments\n"), 0600))
+
+	_, err := InClusterConfig()
+	assert.Error(t, err, "not in cluster")
+
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	assert.NoError(t, err)
+	nobletest.Setenv(t, map[string]string{"KUBERNETES_SERVICE_HOST": host, "KUBERNETES_SERVICE_PORT": port})
+	initClient(t, nil)
+	v, err := (&KeyReader{}).Read("db-creds#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+
+	// rotated token is read from the file
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "token"), []byte("sa-token-2"), 0600))
+	_, err = (&KeyReader{}).Read("db-creds#password")
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "token"), []byte("revoked"), 0600))
+	_, err = (&KeyReader{}).Read("db-creds#password")
+	assert.Error(t, err)
+}
+
+func TestKubeconfigConfig(t *testing.T) {
+	srv := k8ssecrettest.NewTLSServer()
+	defer srv.Close()
+	srv.AddToken("user-token")
+	srv.Set("team-a", "api", map[string]string{"token": "t-1"})
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	kubeconfig := `
+apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster:
+    server: ` + srv.URL + `
+    certificate-authority-data: ` + base64.StdEncoding.EncodeToString(ca) + `
+- name: prod
+  cluster:
+    server: https://prod.example.com
+    certificate-authority: ca.pem
+users:
+- name: dev-user
+  user:
+    token: user-token
+- name: prod-user
+  user:
+    tokenFile: token
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: dev-user
+    namespace: team-a
+- name: prod
+  context:
+    cluster: prod
+    user: prod-user
+`
+	dir := tempDir(t)
+	path := filepath.Join(dir, "config")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(kubeconfig), 0600))
+
+	cfg, err := KubeconfigConfig(path, "prod")
+	assert.NoError(t, err)
+	assert.Equal(t, Config{
+		Host:      "https://prod.example.com",
+		TokenFile: filepath.Join(dir, "token"),
+		CAFile:    filepath.Join(dir, "ca.pem"),
+		Namespace: DefaultNamespace,
+		Timeout:   DefaultTimeout,
+	}, cfg)
+	_, err = KubeconfigConfig(path, "none")
+	assert.Error(t, err)
+
+	nobletest.Setenv(t, map[string]string{"KUBECONFIG": path + string(filepath.ListSeparator) + "other"})
+	initClient(t, nil)
+	v, err := (&KeyReader{}).Read("api#token")
+	assert.NoError(t, err)
+	assert.Equal(t, "t-1", v)
+}
+
+func TestWatch(t *testing.T) {
+	srv := k8ssecrettest.Start(t)
+	srv.Set("payments", "db-creds", map[string]string{"password": "secret"})
+	srv.Set("payments", "other", map[string]string{"key": "1"})
+	initClient(t, &Config{Host: srv.URL, Watch: true})
+	defer Close()
+
+	events := make(chan [2]string, 10)
+	defer Subscribe(func(key, value string) { events <- [2]string{key, value} })()
+
+	r := KeyReader{}
+	v, err := r.Read("payments/db-creds#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+	assert.NoError(t, Watch("payments/later"))
+
+	// served from memory
+	requests := srv.Requests()
+	for i := 0; i < 3; i++ {
+		_, _ = r.Read("payments/db-creds#password")
+	}
+	assert.Equal(t, requests, srv.Requests())
+
+	srv.Set("payments", "other", map[string]string{"key": "2"})
+	srv.Set("payments", "db-creds", map[string]string{"password": "rotated"})
+	assert.Equal(t, [2]string{"payments/db-creds", `{"password":"rotated"}`}, waitEvent(t, events))
+	v, err = r.Read("payments/db-creds#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated", v)
+
+	srv.Set("payments", "later", map[string]string{"key": "created"})
+	assert.Equal(t, [2]string{"payments/later", `{"key":"created"}`}, waitEvent(t, events))
+
+	srv.Delete("payments", "db-creds")
+	assert.Equal(t, [2]string{"payments/db-creds", ""}, waitEvent(t, events))
+	_, err = r.Read("payments/db-creds#password")
+	assert.Error(t, err)
+}
+
+func TestWatch_Expired(t *testing.T) {
+	srv := k8ssecrettest.Start(t)
+	srv.HistorySize = 2
+	old := srv.Set("ns", "app", map[string]string{"key": "1"})
+	initClient(t, &Config{Host: srv.URL})
+	for i := 0; i < 5; i++ {
+		srv.Set("ns", "other", map[string]string{"i": "x"})
+	}
+	srv.Set("ns", "app", map[string]string{"key": "2"})
+	events := make(chan [2]string, 10)
+	defer Subscribe(func(key, value string) { events <- [2]string{key, value} })()
+
+	w := &watcher{c: clients.Current().(*apiClient), namespace: "ns", name: "app", id: "ns/app", version: old, loaded: true,
+		secret: &secret{Data: map[string][]byte{"key": []byte("1")}}}
+	assert.Equal(t, errGone, w.stream(context.Background()))
+	assert.NoError(t, w.refresh(context.Background()))
+	assert.Equal(t, [2]string{"ns/app", `{"key":"2"}`}, waitEvent(t, events))
+}
+
+func waitEvent(t *testing.T, events chan [2]string) [2]string {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watch event")
+	}
+	return [2]string{}
+}
@@ -0,0 +1,224 @@
+package k8ssecret
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/watch"
+	"github.com/pkg/errors"
+)
+
+// Watch parameters
+//
+//nolint:gochecknoglobals
+var (
+	// WatchTimeout of the watch request, re-issued after it to detect dead connections
+	WatchTimeout = 5 * time.Minute
+	// WatchRetryMin and WatchRetryMax bounds of the delay between failed watch requests
+	WatchRetryMin = 500 * time.Millisecond
+	WatchRetryMax = 30 * time.Second
+)
+
+// errGone resourceVersion of the watch is too old, object must be read again
+var errGone = errors.New("resource version expired") //nolint:gochecknoglobals
+
+// Subscriber receives watched secret ("namespace/name") and JSON object of its keys, empty string if deleted
+type Subscriber = watch.Subscriber
+
+//nolint:gochecknoglobals
+var subs watch.Registry
+
+// Subscribe fn to changes of the watched secrets. Returns function to unsubscribe
+func Subscribe(fn Subscriber) func() {
+	return subs.Subscribe(fn)
+}
+
+// Watch reads secret ("[namespace/]name") into the local cache and keeps it current
+// using watch requests. KeyReader serves watched secrets from memory
+func Watch(key string) error {
+	c, err := getClient()
+	if err != nil {
+		return err
+	}
+	namespace, name, _, err := parseKey(key, c.cfg.Namespace)
+	if err != nil {
+		return err
+	}
+	_, err = c.watcher(namespace, name)
+	return err
+}
+
+// Close stops all watchers of the current client. Secrets are read directly after Close
+func Close() {
+	if c := clients.Current(); c != nil {
+		c.(*apiClient).stopWatchers()
+	}
+}
+
+type watcher struct {
+	c         *apiClient
+	namespace string
+	name      string
+	id        string // namespace/name, passed to subscribers
+
+	mu      sync.RWMutex
+	secret  *secret
+	err     error
+	version string // resourceVersion to watch from
+	loaded  bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// watcher returns running watcher of the secret or starts new one after the initial read
+func (c *apiClient) watcher(namespace, name string) (*watcher, error) {
+	id := namespace + "/" + name
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if w, ok := c.watchers[id]; ok {
+		return w, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &watcher{c: c, namespace: namespace, name: name, id: id, cancel: cancel, done: make(chan struct{})}
+	if err := w.refresh(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	c.watchers[id] = w
+	go w.run(ctx)
+	return w, nil
+}
+
+func (c *apiClient) stopWatchers() {
+	c.wmu.Lock()
+	list := c.watchers
+	c.watchers = make(map[string]*watcher)
+	c.wmu.Unlock()
+	for _, w := range list {
+		w.cancel()
+		<-w.done
+	}
+}
+
+// refresh reads the secret and stores it in the cache. Returns error only for failed requests,
+// missing secret is cached as error
+func (w *watcher) refresh(ctx context.Context) error {
+	s, err := w.c.getSecret(ctx, w.namespace, w.name)
+	if isNotFound(err) {
+		w.update(nil, err, "")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	w.update(s, nil, s.Metadata.ResourceVersion)
+	return nil
+}
+
+func (w *watcher) update(s *secret, err error, version string) {
+	var old, val string
+	w.mu.Lock()
+	first := !w.loaded
+	w.loaded = true
+	if w.secret != nil {
+		old = secretValue(w.secret)
+	}
+	if s != nil {
+		val = secretValue(s)
+	}
+	changed := (w.secret == nil) != (s == nil) || old != val
+	w.secret, w.err, w.version = s, err, version
+	w.mu.Unlock()
+	if changed && !first {
+		subs.Notify(w.id, val)
+	}
+}
+
+func (w *watcher) run(ctx context.Context) {
+	defer close(w.done)
+	b := watch.Backoff{Min: &WatchRetryMin, Max: &WatchRetryMax}
+	for ctx.Err() == nil {
+		err := w.stream(ctx)
+		if err == errGone {
+			err = w.refresh(ctx)
+		}
+		if err == nil {
+			b.Reset()
+			continue
+		}
+		if !b.Wait(ctx) {
+			return
+		}
+	}
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// stream applies events of the watch request until it ends
+func (w *watcher) stream(ctx context.Context) error {
+	w.mu.RLock()
+	version := w.version
+	w.mu.RUnlock()
+	q := url.Values{
+		"watch":               {"true"},
+		"fieldSelector":       {"metadata.name=" + w.name},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {strconv.Itoa(int(WatchTimeout / time.Second))},
+	}
+	if version != "" {
+		q.Set("resourceVersion", version)
+	}
+	sctx, cancel := context.WithTimeout(ctx, WatchTimeout+w.c.cfg.Timeout)
+	defer cancel()
+	res, err := w.c.do(sctx, w.c.watch, secretsPath(w.namespace), q)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	dec := json.NewDecoder(res.Body)
+	for {
+		var ev watchEvent
+		if err := dec.Decode(&ev); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch ev.Type {
+		case "ADDED", "MODIFIED":
+			var s secret
+			if err := json.Unmarshal(ev.Object, &s); err != nil {
+				return err
+			}
+			w.update(&s, nil, s.Metadata.ResourceVersion)
+		case "DELETED":
+			var s secret
+			_ = json.Unmarshal(ev.Object, &s)
+			w.update(nil, &apiError{Code: http.StatusNotFound, Reason: "NotFound",
+				Message: "secrets \"" + w.name + "\" not found"}, s.Metadata.ResourceVersion)
+		case "BOOKMARK":
+			var s secret
+			if json.Unmarshal(ev.Object, &s) == nil && s.Metadata.ResourceVersion != "" {
+				w.mu.Lock()
+				w.version = s.Metadata.ResourceVersion
+				w.mu.Unlock()
+			}
+		case "ERROR":
+			e := &apiError{}
+			_ = json.Unmarshal(ev.Object, e)
+			if e.Code == http.StatusGone {
+				return errGone
+			}
+			return e
+		}
+	}
+}
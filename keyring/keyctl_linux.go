@@ -0,0 +1,93 @@
+package keyring
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// keyType of the keys with payload readable from user space
+const keyType = "user"
+
+func ringID(ring string) (int, error) {
+	switch ring {
+	case User:
+		return unix.KEY_SPEC_USER_KEYRING, nil
+	case Session:
+		return unix.KEY_SPEC_SESSION_KEYRING, nil
+	case UserSession:
+		return unix.KEY_SPEC_USER_SESSION_KEYRING, nil
+	case Process:
+		return unix.KEY_SPEC_PROCESS_KEYRING, nil
+	case Thread:
+		return unix.KEY_SPEC_THREAD_KEYRING, nil
+	}
+	id, err := strconv.Atoi(ring)
+	if err != nil || id <= 0 {
+		return 0, errors.New("unknown keyring: " + ring)
+	}
+	return id, nil
+}
+
+// Get payload of the "user" key with description from the keyring and keyrings linked to it
+func Get(ring, description string) (string, error) {
+	rid, err := ringID(ring)
+	if err != nil {
+		return "", err
+	}
+	id, err := unix.KeyctlSearch(rid, keyType, description, 0)
+	if err != nil {
+		return "", errors.Wrap(err, "search key")
+	}
+	size, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, nil, 0)
+	if err != nil {
+		return "", errors.Wrap(err, "read key")
+	}
+	buf := make([]byte, size)
+	n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, buf, 0)
+	if err != nil {
+		return "", errors.Wrap(err, "read key")
+	}
+	if n > size {
+		// payload updated between the calls
+		return Get(ring, description)
+	}
+	return string(buf[:n]), nil
+}
+
+// Add "user" key to the keyring, replacing existing one. Key expires after timeout if it is not zero
+func Add(ring, description, value string, timeout time.Duration) error {
+	rid, err := ringID(ring)
+	if err != nil {
+		return err
+	}
+	id, err := unix.AddKey(keyType, description, []byte(value), rid)
+	if err != nil {
+		return errors.Wrap(err, "add key")
+	}
+	if timeout > 0 {
+		secs := int((timeout + time.Second - 1) / time.Second)
+		if _, err := unix.KeyctlInt(unix.KEYCTL_SET_TIMEOUT, id, secs, 0, 0); err != nil {
+			return errors.Wrap(err, "set key timeout")
+		}
+	}
+	return nil
+}
+
+// Remove key from the keyring
+func Remove(ring, description string) error {
+	rid, err := ringID(ring)
+	if err != nil {
+		return err
+	}
+	id, err := unix.KeyctlSearch(rid, keyType, description, 0)
+	if err != nil {
+		return errors.Wrap(err, "search key")
+	}
+	if _, err := unix.KeyctlInt(unix.KEYCTL_UNLINK, id, rid, 0, 0); err != nil {
+		return errors.Wrap(err, "unlink key")
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package keyring
+
+import "time"
+
+// Get is not supported
+func Get(ring, description string) (string, error) {
+	return "", ErrUnsupported
+}
+
+// Add is not supported
+func Add(ring, description, value string, timeout time.Duration) error {
+	return ErrUnsupported
+}
+
+// Remove is not supported
+func Remove(ring, description string) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,70 @@
+// Package keyring Noble reader of the Linux kernel keyring
+package keyring
+
+import (
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/pkg/errors"
+)
+
+// Keyring special names accepted in keys
+const (
+	User        = "@u"
+	Session     = "@s"
+	UserSession = "@us"
+	Process     = "@p"
+	Thread      = "@t"
+)
+
+// DefaultKeyring searched for keys without keyring
+const DefaultKeyring = User
+
+// ErrUnsupported returned on systems without kernel keyring
+var ErrUnsupported = errors.New("kernel keyring is supported only on linux") //nolint:gochecknoglobals
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("keyring", &Reader{})
+}
+
+// Reader type implements noble.SecretStorage
+//
+// Key format: [<keyring>/]<description>
+//
+//	keyring:@u/db_password - "user" key from the user keyring (and keyrings linked to it)
+//	keyring:@s/db_password - key from the session keyring
+//	keyring:db_password - key from DefaultKeyring
+type Reader struct {
+}
+
+// Read payload of the "user" type key
+func (r *Reader) Read(key string) (string, error) {
+	ring, desc, err := ParseKey(key)
+	if err != nil {
+		return "", err
+	}
+	val, err := Get(ring, desc)
+	return val, errors.Wrap(err, "keyring key "+key)
+}
+
+// ParseKey splits key into keyring and description
+func ParseKey(key string) (ring, description string, err error) {
+	ring, description = DefaultKeyring, key
+	if strings.HasPrefix(key, "@") {
+		i := strings.Index(key, "/")
+		if i < 0 {
+			return "", "", errors.New("incorrect key format. use [<keyring>/]<description>")
+		}
+		ring, description = key[:i], key[i+1:]
+	}
+	if description == "" {
+		return "", "", errors.New("incorrect key format. use [<keyring>/]<description>")
+	}
+	return ring, description, nil
+}
+
+// Clone returns new empty instance of Reader
+func (r *Reader) Clone() noble.SecretStorage {
+	return &Reader{}
+}
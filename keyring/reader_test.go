@@ -0,0 +1,52 @@
+package keyring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lancer-kit/noble/nobletest"
+)
+
+func TestParseKey(t *testing.T) {
+	ring, desc, err := ParseKey("@s/db_password")
+	assert.NoError(t, err)
+	assert.Equal(t, Session, ring)
+	assert.Equal(t, "db_password", desc)
+	ring, desc, err = ParseKey("app:db/password")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultKeyring, ring)
+	assert.Equal(t, "app:db/password", desc)
+	_, _, err = ParseKey("@u")
+	assert.Error(t, err)
+	_, _, err = ParseKey("@u/")
+	assert.Error(t, err)
+}
+
+func TestReader_Read(t *testing.T) {
+	if err := Add(Process, "noble-test", "probe", 0); err != nil {
+		t.Skip("kernel keyring is not available: ", err)
+	}
+	assert.NoError(t, Add(Process, "noble-test", "secret", time.Minute))
+	defer func() { _ = Remove(Process, "noble-test") }()
+
+	r := Reader{}
+	v, err := r.Read("@p/noble-test")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+	_, err = r.Read("@p/noble-none")
+	assert.Error(t, err)
+	_, err = r.Read("@x/noble-test")
+	assert.Error(t, err)
+
+	var c nobletest.Config
+	assert.NoError(t, yaml.Unmarshal([]byte(`secret: "keyring:@p/noble-test"`), &c))
+	assert.NoError(t, c.Secret.InternalError())
+	assert.Equal(t, "secret", c.Secret.Get())
+
+	assert.NoError(t, Remove(Process, "noble-test"))
+	_, err = r.Read("@p/noble-test")
+	assert.Error(t, err)
+}
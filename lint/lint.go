@@ -0,0 +1,296 @@
+// Package lint scans YAML/JSON config files for plain-text credentials
+// which are not protected by noble storages.
+package lint
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Finding describes one suspicious config value
+type Finding struct {
+	File   string
+	Line   int
+	Column int
+	Key    string // dotted path of the value inside the document
+	Reason string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", f.File, f.Line, f.Column, f.Key, f.Reason)
+}
+
+// Rules of the scanner
+type Rules struct {
+	// KeyNames names of credential keys (case and separator insensitive). Name matches whole words of the key
+	// split by separators, camelCase and digits, optionally in plural: "pass" matches "db_pass" and "dbPass",
+	// but not "passport"; "apikey" matches "api_key" and "apiKeys"
+	KeyNames []string
+	// Storages noble storage prefixes considered safe. "raw" is never safe.
+	Storages []string
+	// MinEntropy Shannon entropy (bits per char) for the value to be reported regardless of the key name.
+	// Zero disables entropy check
+	MinEntropy float64
+	// MinEntropyLength minimal length of the value for entropy check
+	MinEntropyLength int
+}
+
+// DefaultRules used by Scan and ScanFile
+//
+//nolint:gochecknoglobals
+var DefaultRules = Rules{
+	KeyNames: []string{
+		"password", "passwd", "pwd", "pass", "secret", "token", "apikey", "accesskey", "privatekey",
+		"credential", "dsn", "connectionstring",
+	},
+	Storages: []string{
+		"env",       // noble
+		"dynenv",    // noble
+		"scr",       // simplecrypt
+		"vault",     // vaultx
+		"etcd2",     // etcdr2
+		"etcd3",     // etcdr3
+		"consul",    // consul
+		"k8s",       // k8s
+		"k8ssecret", // k8ssecret
+		"docker",    // docker
+		"creds",     // creds
+		"keyring",   // keyring
+		"dotenv",    // dotenv
+		"file",      // files
+		"jsonfile",  // files
+		"yamlfile",  // files
+		"tomlfile",  // files
+		"awssm",     // awsstore
+		"ssm",       // awsstore
+		"s3",        // awsstore
+		"gcpsm",     // gcpsm
+		"akv",       // akv
+		"redis",     // redis
+		"sql",       // sqlstore
+		"http",      // httpstore
+	},
+	MinEntropy:       4.0,
+	MinEntropyLength: 20,
+}
+
+//nolint:gochecknoglobals
+var (
+	templateRe = regexp.MustCompile(`{{([^{}]*)}}`)
+	storageRe  = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9_-]*):`)
+	userinfoRe = regexp.MustCompile(`://[^/:@\s]*:[^/@\s]+@`)
+	tokenRe    = regexp.MustCompile(`^[A-Za-z0-9+/=_-]+$`)
+)
+
+// Scan data of the config file. File name used in findings only
+func Scan(name string, data []byte) ([]Finding, error) {
+	return DefaultRules.Scan(name, data)
+}
+
+// ScanFile read and scan config file
+func ScanFile(name string) ([]Finding, error) {
+	return DefaultRules.ScanFile(name)
+}
+
+// ScanFile read and scan config file
+func (r Rules) ScanFile(name string) ([]Finding, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return r.Scan(name, data)
+}
+
+// Scan data of the config file. JSON is parsed as YAML subset
+func (r Rules) Scan(name string, data []byte) ([]Finding, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	var res []Finding
+	r.walk(&doc, "", func(n *yaml.Node, key, reason string) {
+		res = append(res, Finding{File: name, Line: n.Line, Column: n.Column, Key: key, Reason: reason})
+	})
+	return res, nil
+}
+
+func (r Rules) walk(n *yaml.Node, path string, report func(*yaml.Node, string, string)) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			r.walk(c, path, report)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			p := k.Value
+			if path != "" {
+				p = path + "." + k.Value
+			}
+			if v.Kind == yaml.ScalarNode {
+				if reason := r.check(k.Value, v); reason != "" {
+					report(v, p, reason)
+				}
+				continue
+			}
+			r.walk(v, p, report)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			if c.Kind == yaml.ScalarNode {
+				if reason := r.check("", c); reason != "" {
+					report(c, p, reason)
+				}
+				continue
+			}
+			r.walk(c, p, report)
+		}
+	}
+}
+
+// check returns reason of the finding or empty string
+func (r Rules) check(key string, v *yaml.Node) string {
+	if v.Tag != "!!str" || v.Value == "" {
+		return ""
+	}
+	val := v.Value
+	if templateRe.MatchString(val) {
+		for _, m := range templateRe.FindAllStringSubmatch(val, -1) {
+			if s := storageOf(m[1]); s == "raw" {
+				return "secret template uses raw storage"
+			}
+		}
+		val = templateRe.ReplaceAllString(val, "")
+		return r.checkPlain(key, val, false)
+	}
+	switch s := storageOf(val); {
+	case s == "raw":
+		return "secret uses raw storage"
+	case r.safeStorage(s):
+		return ""
+	}
+	return r.checkPlain(key, val, true)
+}
+
+func (r Rules) checkPlain(key, val string, whole bool) string {
+	if whole && r.sensitiveKey(key) {
+		return "plain-text value of credential-like key"
+	}
+	if userinfoRe.MatchString(val) {
+		return "URL with plain-text password"
+	}
+	if r.MinEntropy > 0 {
+		for _, w := range strings.Fields(val) {
+			if len(w) >= r.MinEntropyLength && tokenRe.MatchString(w) && Entropy(w) >= r.MinEntropy {
+				return fmt.Sprintf("high-entropy plain-text value (%.2f bits/char)", Entropy(w))
+			}
+		}
+	}
+	return ""
+}
+
+func (r Rules) sensitiveKey(key string) bool {
+	words := keyWords(key)
+	for _, n := range r.KeyNames {
+		if n = normalizeKey(n); n != "" && matchWords(words, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWords reports whether name is equal to the consecutive words or to their plural form
+func matchWords(words []string, name string) bool {
+	for i := range words {
+		var s string
+		for _, w := range words[i:] {
+			if s += w; s == name || s == name+"s" {
+				return true
+			}
+			if len(s) > len(name) {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// keyWords splits key into lower case words by separators, camelCase and digits:
+// "DBPassword2" is "db", "password", "2"
+func keyWords(key string) []string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, strings.ToLower(string(cur)))
+			cur = cur[:0]
+		}
+	}
+	rs := []rune(key)
+	for i, c := range rs {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			flush()
+			continue
+		}
+		if len(cur) > 0 {
+			prev := rs[i-1]
+			lowerNext := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+			if unicode.IsDigit(c) != unicode.IsDigit(prev) ||
+				unicode.IsUpper(c) && (unicode.IsLower(prev) || unicode.IsUpper(prev) && lowerNext) {
+				flush()
+			}
+		}
+		cur = append(cur, c)
+	}
+	flush()
+	return words
+}
+
+func normalizeKey(s string) string {
+	return strings.NewReplacer("_", "", "-", "", ".", "", " ", "").Replace(strings.ToLower(s))
+}
+
+func (r Rules) safeStorage(s string) bool {
+	for _, v := range r.Storages {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func storageOf(s string) string {
+	s = strings.TrimSpace(s)
+	m := storageRe.FindStringSubmatch(s)
+	if m == nil || strings.HasPrefix(s[len(m[0]):], "//") {
+		// URL, e.g. "redis://:pass@host"
+		return ""
+	}
+	return m[1]
+}
+
+// Entropy returns Shannon entropy of the string in bits per character
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	freq := make(map[rune]int)
+	n := 0
+	for _, c := range s {
+		freq[c]++
+		n++
+	}
+	var e float64
+	for _, c := range freq {
+		p := float64(c) / float64(n)
+		e -= p * math.Log2(p)
+	}
+	return e
+}
@@ -0,0 +1,43 @@
+package lint
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Output formats of the report
+const (
+	FormatText   = "text"
+	FormatGitHub = "github"
+)
+
+// Report writes findings in the selected format:
+// "text" - one "file:line:col: key: reason" per line (pre-commit hooks, editors);
+// "github" - GitHub Actions workflow commands, shown as annotations on the pull request
+func Report(w io.Writer, format string, findings []Finding) error {
+	for _, f := range findings {
+		var err error
+		switch format {
+		case FormatText, "":
+			_, err = fmt.Fprintln(w, f.String())
+		case FormatGitHub:
+			_, err = fmt.Fprintf(w, "::error file=%s,line=%d,col=%d,title=noble lint::%s\n",
+				escapeProperty(f.File), f.Line, f.Column, escapeData(f.Key+": "+f.Reason))
+		default:
+			return fmt.Errorf("unknown report format: %s", format)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
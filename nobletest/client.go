@@ -0,0 +1,23 @@
+package nobletest
+
+import (
+	"testing"
+
+	"github.com/lancer-kit/noble"
+)
+
+// Config with one secret field, decoded the way applications load noble.Secret
+type Config struct {
+	Secret noble.Secret `yaml:"secret" json:"secret"`
+}
+
+// InitClient of the storage package for the duration of the test.
+// init is called now and fails the test on error, reset is called on t.Cleanup
+// and must close the client, so the next test (or the first read) initializes new one
+func InitClient(t testing.TB, init func() error, reset func()) {
+	t.Helper()
+	if err := init(); err != nil {
+		t.Fatalf("nobletest: init client: %v", err)
+	}
+	t.Cleanup(reset)
+}
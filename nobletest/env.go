@@ -0,0 +1,27 @@
+package nobletest
+
+import (
+	"os"
+	"testing"
+)
+
+// Setenv sets environment variables for the duration of the test.
+// Previous values are restored (or the variables removed) on t.Cleanup.
+// Tests which use Setenv must not run in parallel with others reading the same variables
+func Setenv(t testing.TB, env map[string]string) {
+	t.Helper()
+	for k, v := range env {
+		prev, ok := os.LookupEnv(k)
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatalf("nobletest: set %s: %v", k, err)
+		}
+		k := k
+		t.Cleanup(func() {
+			if ok {
+				_ = os.Setenv(k, prev)
+				return
+			}
+			_ = os.Unsetenv(k)
+		})
+	}
+}
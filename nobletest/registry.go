@@ -0,0 +1,31 @@
+package nobletest
+
+import (
+	"testing"
+
+	"github.com/lancer-kit/noble"
+)
+
+// Register storage under key for the duration of the test.
+// Previous registration is restored (or the key removed) on t.Cleanup.
+// Tests which use Register must not run in parallel with others reading the same key
+func Register(t testing.TB, key string, impl noble.SecretStorage) {
+	t.Helper()
+	prev, ok := noble.Lookup(key)
+	noble.Register(key, impl)
+	t.Cleanup(func() {
+		if ok {
+			noble.Register(key, prev)
+			return
+		}
+		noble.Unregister(key)
+	})
+}
+
+// Use creates Storage with values and registers it under key for the duration of the test
+func Use(t testing.TB, key string, values map[string]string) *Storage {
+	t.Helper()
+	s := NewStorage(values)
+	Register(t, key, s)
+	return s
+}
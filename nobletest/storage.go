@@ -0,0 +1,165 @@
+// Package nobletest provides in-memory noble.SecretStorage with fault injection and call recording,
+// and helpers to register storages in tests without leaking them to other tests.
+package nobletest
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lancer-kit/noble"
+)
+
+// ErrNotFound returned by Storage for missing paths
+var ErrNotFound = errors.New("nobletest: secret not found") //nolint:gochecknoglobals
+
+// Fault injected into Storage reads
+type Fault struct {
+	// Latency added before every read
+	Latency time.Duration
+	// Err returned instead of the value
+	Err error
+	// Every fail only every N-th read (1-based), other reads succeed. Zero or one fails every read.
+	// Every: 2 makes storage flap: ok, fail, ok, fail...
+	Every int
+	// Times fail only the first N matching reads, then recover. Zero means forever
+	Times int
+
+	calls int
+	fired int
+}
+
+// Call recorded read
+type Call struct {
+	Path  string
+	Value string
+	Err   error
+	Time  time.Time
+}
+
+// Storage in-memory noble.SecretStorage. Clones share values, faults and recorded calls
+type Storage struct {
+	mu     sync.Mutex
+	values map[string]string
+	faults map[string]*Fault
+	calls  []Call
+}
+
+// NewStorage returns storage with initial values
+func NewStorage(values map[string]string) *Storage {
+	s := &Storage{
+		values: make(map[string]string, len(values)),
+		faults: make(map[string]*Fault),
+	}
+	for k, v := range values {
+		s.values[k] = v
+	}
+	return s
+}
+
+// Set value by path
+func (s *Storage) Set(path, value string) *Storage {
+	s.mu.Lock()
+	s.values[path] = value
+	s.mu.Unlock()
+	return s
+}
+
+// Delete value by path
+func (s *Storage) Delete(path string) *Storage {
+	s.mu.Lock()
+	delete(s.values, path)
+	s.mu.Unlock()
+	return s
+}
+
+// Inject copy of the fault for path. Empty path matches all reads without own fault.
+// Pass nil to remove fault
+func (s *Storage) Inject(path string, f *Fault) *Storage {
+	s.mu.Lock()
+	if f == nil {
+		delete(s.faults, path)
+	} else {
+		c := *f
+		c.calls, c.fired = 0, 0
+		s.faults[path] = &c
+	}
+	s.mu.Unlock()
+	return s
+}
+
+// Read implements noble.SecretStorage
+func (s *Storage) Read(path string) (string, error) {
+	s.mu.Lock()
+	latency, err := s.fault(path)
+	s.mu.Unlock()
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var val string
+	if err == nil {
+		var ok bool
+		if val, ok = s.values[path]; !ok {
+			err = ErrNotFound
+		}
+	}
+	s.calls = append(s.calls, Call{Path: path, Value: val, Err: err, Time: time.Now()})
+	return val, err
+}
+
+// fault returns latency and error for the next read. Requires lock
+func (s *Storage) fault(path string) (time.Duration, error) {
+	f, ok := s.faults[path]
+	if !ok {
+		if f, ok = s.faults[""]; !ok {
+			return 0, nil
+		}
+	}
+	f.calls++
+	if f.Err == nil || (f.Times > 0 && f.fired >= f.Times) {
+		return f.Latency, nil
+	}
+	if f.Every > 1 && f.calls%f.Every != 0 {
+		return f.Latency, nil
+	}
+	f.fired++
+	return f.Latency, f.Err
+}
+
+// Clone implements noble.SecretStorage. Returns the same storage
+func (s *Storage) Clone() noble.SecretStorage {
+	return s
+}
+
+// Calls returns copy of the recorded reads
+func (s *Storage) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// CallCount returns count of reads of the path. Empty path counts all reads
+func (s *Storage) CallCount(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if path == "" {
+		return len(s.calls)
+	}
+	n := 0
+	for _, c := range s.calls {
+		if c.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// ResetCalls forget recorded reads
+func (s *Storage) ResetCalls() {
+	s.mu.Lock()
+	s.calls = nil
+	s.mu.Unlock()
+}
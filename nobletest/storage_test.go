@@ -0,0 +1,125 @@
+package nobletest
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lancer-kit/noble"
+)
+
+type testConfig struct {
+	Pass noble.Secret `yaml:"pass"`
+	URL  noble.Secret `yaml:"url"`
+}
+
+func TestUse(t *testing.T) {
+	t.Run("registered", func(t *testing.T) {
+		s := Use(t, "mem", map[string]string{"db/pass": "secret", "db/host": "localhost"})
+		var c testConfig
+		assert.NoError(t, yaml.Unmarshal([]byte("pass: mem:db/pass\nurl: pg://{{mem:db/host}}/db\n"), &c))
+		assert.Equal(t, "secret", c.Pass.Get())
+		assert.Equal(t, "pg://localhost/db", c.URL.Get())
+		assert.Equal(t, 2, s.CallCount("db/pass"))
+		assert.Equal(t, 4, s.CallCount(""))
+
+		s.Set("db/pass", "changed")
+		assert.Equal(t, "changed", c.Pass.Get())
+		s.Delete("db/pass")
+		assert.Equal(t, "", c.Pass.Get())
+		assert.Equal(t, ErrNotFound, c.Pass.InternalError())
+	})
+	_, ok := noble.Lookup("mem")
+	assert.False(t, ok)
+
+	prev, _ := noble.Lookup("raw")
+	t.Run("override", func(t *testing.T) {
+		Use(t, "raw", map[string]string{"x": "y"})
+		s := noble.Secret{}.New("raw:x")
+		assert.Equal(t, "y", s.Get())
+	})
+	cur, _ := noble.Lookup("raw")
+	assert.Equal(t, prev, cur)
+	s := noble.Secret{}.New("raw:x")
+	assert.Equal(t, "x", s.Get())
+}
+
+func TestStorage_Inject(t *testing.T) {
+	errTest := errors.New("unavailable")
+	s := NewStorage(map[string]string{"a": "1", "b": "2"})
+
+	s.Inject("a", &Fault{Err: errTest, Every: 2})
+	var errs []error
+	for i := 0; i < 4; i++ {
+		_, err := s.Read("a")
+		errs = append(errs, err)
+	}
+	assert.Equal(t, []error{nil, errTest, nil, errTest}, errs)
+
+	s.Inject("", &Fault{Err: errTest, Times: 1})
+	_, err := s.Read("b")
+	assert.Equal(t, errTest, err)
+	v, err := s.Read("b")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", v)
+
+	// fault value is copied: reused fault behaves the same
+	once := &Fault{Err: errTest, Times: 1}
+	for i := 0; i < 2; i++ {
+		s.Inject("b", once)
+		_, err = s.Read("b")
+		assert.Equal(t, errTest, err)
+		_, err = s.Read("b")
+		assert.NoError(t, err)
+	}
+	s.Inject("b", nil)
+
+	s.Inject("", &Fault{Latency: 20 * time.Millisecond})
+	start := time.Now()
+	_, err = s.Read("b")
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+
+	s.Inject("a", nil).Inject("", nil)
+	_, err = s.Read("a")
+	assert.NoError(t, err)
+
+	calls := s.Calls()
+	assert.Len(t, calls, 12)
+	assert.Equal(t, "b", calls[4].Path)
+	assert.Equal(t, errTest, calls[4].Err)
+	s.ResetCalls()
+	assert.Empty(t, s.Calls())
+}
+
+func TestSetenv(t *testing.T) {
+	assert.NoError(t, os.Setenv("NOBLETEST_PREV", "prev"))
+	defer func() { _ = os.Unsetenv("NOBLETEST_PREV") }()
+	_ = os.Unsetenv("NOBLETEST_NEW")
+	t.Run("set", func(t *testing.T) {
+		Setenv(t, map[string]string{"NOBLETEST_PREV": "changed", "NOBLETEST_NEW": "new"})
+		s := noble.Secret{}.New("env:NOBLETEST_PREV")
+		assert.Equal(t, "changed", s.Get())
+		assert.Equal(t, "new", os.Getenv("NOBLETEST_NEW"))
+	})
+	assert.Equal(t, "prev", os.Getenv("NOBLETEST_PREV"))
+	_, ok := os.LookupEnv("NOBLETEST_NEW")
+	assert.False(t, ok)
+}
+
+func TestInitClient(t *testing.T) {
+	resets := 0
+	t.Run("init", func(t *testing.T) {
+		InitClient(t, func() error { return nil }, func() { resets++ })
+		assert.Equal(t, 0, resets)
+	})
+	assert.Equal(t, 1, resets)
+
+	var c Config
+	assert.NoError(t, yaml.Unmarshal([]byte(`secret: "raw:value"`), &c))
+	assert.Equal(t, "value", c.Secret.Get())
+}
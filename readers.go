@@ -2,9 +2,14 @@ package noble
 
 import (
 	"errors"
+	"io/ioutil"
 	"os"
+	"strings"
 )
 
+// FileEnvSuffix of the variable with path to the file holding the value, e.g. DB_PASS_FILE for DB_PASS
+const FileEnvSuffix = "_FILE"
+
 type rawReader struct{}
 
 func (rr rawReader) Read(path string) (string, error) {
@@ -24,10 +29,11 @@ type envReader struct {
 // Read env.variable into internal cache
 func (er *envReader) Read(path string) (string, error) {
 	if er.cached == "" {
-		er.cached = os.Getenv(path)
-	}
-	if er.cached == "" {
-		return er.cached, errors.New("unable to read OS environment variable:" + path)
+		val, err := getenv(path)
+		if err != nil {
+			return "", err
+		}
+		er.cached = val
 	}
 	return er.cached, nil
 }
@@ -42,14 +48,31 @@ type dynReader struct{}
 
 // Read env.variable dynamically
 func (d *dynReader) Read(path string) (string, error) {
-	val := os.Getenv(path)
-	if val == "" {
-		return val, errors.New("unable to read OS environment variable:" + path)
-	}
-	return val, nil
+	return getenv(path)
 }
 
 // Clone returns new empty instance of dynReader
 func (d dynReader) Clone() SecretStorage {
 	return &dynReader{}
 }
+
+// getenv returns value of the environment variable. If it is empty, value is read
+// from the file named by the variable with FileEnvSuffix, trailing newlines are trimmed
+func getenv(name string) (string, error) {
+	if val := os.Getenv(name); val != "" {
+		return val, nil
+	}
+	file := os.Getenv(name + FileEnvSuffix)
+	if file == "" {
+		return "", errors.New("unable to read OS environment variable:" + name)
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", errors.New("unable to read " + name + FileEnvSuffix + " file: " + err.Error())
+	}
+	val := strings.TrimRight(string(b), "\r\n")
+	if val == "" {
+		return "", errors.New("empty " + name + FileEnvSuffix + " file: " + file)
+	}
+	return val, nil
+}
@@ -0,0 +1,64 @@
+package redact
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Hook masks tracked values in the message and fields of logrus entries
+type Hook struct {
+	r *Redactor
+}
+
+// NewHook returns logrus.Hook for the Default redactor:
+//
+//	logrus.AddHook(redact.NewHook())
+func NewHook() *Hook {
+	return Default.Hook()
+}
+
+// Hook returns logrus.Hook for the redactor
+func (r *Redactor) Hook() *Hook {
+	return &Hook{r: r}
+}
+
+// Levels implements logrus.Hook
+func (h *Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (h *Hook) Fire(e *logrus.Entry) error {
+	e.Message = h.r.String(e.Message)
+	if len(e.Data) == 0 {
+		return nil
+	}
+	// entry data may be shared with the parent entry, do not modify it in place
+	data := make(logrus.Fields, len(e.Data))
+	for k, v := range e.Data {
+		data[k] = h.value(v)
+	}
+	e.Data = data
+	return nil
+}
+
+func (h *Hook) value(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return h.r.String(val)
+	case []byte:
+		return h.r.Bytes(val)
+	case error:
+		s := val.Error()
+		if m := h.r.String(s); m != s {
+			return m
+		}
+	case fmt.Stringer:
+		s := val.String()
+		if m := h.r.String(s); m != s {
+			return m
+		}
+	}
+	return v
+}
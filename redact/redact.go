@@ -0,0 +1,175 @@
+// Package redact masks secret values resolved by noble in logs and other output streams
+package redact
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lancer-kit/noble"
+)
+
+const (
+	// DefaultMask replacement for secret values
+	DefaultMask = "******"
+	// DefaultMinLength values shorter than this are not tracked
+	DefaultMinLength = 4
+)
+
+// Config of the Redactor
+type Config struct {
+	// Mask replaces every occurrence of the tracked value. DefaultMask if empty
+	Mask string
+	// MaskFunc builds replacement from the value. Overrides Mask
+	MaskFunc func(value string) string
+	// MinLength of the tracked value. Short values produce too many false matches.
+	// DefaultMinLength if zero
+	MinLength int
+}
+
+// Redactor keeps set of secret values and masks them in strings
+type Redactor struct {
+	cfg      Config
+	mu       sync.RWMutex
+	values   map[string]struct{}
+	replacer *strings.Replacer
+	maxLen   int
+}
+
+// Default redactor tracks all values resolved by noble.Secret after the package import
+//
+//nolint:gochecknoglobals
+var Default = New(Config{})
+
+//nolint:gochecknoinits
+func init() {
+	Default.Track()
+}
+
+// New returns Redactor. Call Track to collect values resolved by noble.Secret
+func New(cfg Config) *Redactor {
+	if cfg.Mask == "" {
+		cfg.Mask = DefaultMask
+	}
+	if cfg.MinLength <= 0 {
+		cfg.MinLength = DefaultMinLength
+	}
+	return &Redactor{cfg: cfg, values: make(map[string]struct{})}
+}
+
+// Track subscribe redactor for every value resolved by noble.Secret
+func (r *Redactor) Track() {
+	noble.OnResolve(func(v string) { r.Add(v) })
+}
+
+// Add values to mask. Values shorter than MinLength are ignored
+func (r *Redactor) Add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range values {
+		if len(v) < r.cfg.MinLength {
+			continue
+		}
+		if _, ok := r.values[v]; ok {
+			continue
+		}
+		r.values[v] = struct{}{}
+		r.replacer = nil
+		if len(v) > r.maxLen {
+			r.maxLen = len(v)
+		}
+	}
+}
+
+// Len returns count of tracked values
+func (r *Redactor) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.values)
+}
+
+// Reset forget all tracked values
+func (r *Redactor) Reset() {
+	r.mu.Lock()
+	r.values = make(map[string]struct{})
+	r.replacer = nil
+	r.maxLen = 0
+	r.mu.Unlock()
+}
+
+// String masks all tracked values in s
+func (r *Redactor) String(s string) string {
+	rp := r.getReplacer()
+	if rp == nil {
+		return s
+	}
+	return rp.Replace(s)
+}
+
+// Bytes masks all tracked values in b
+func (r *Redactor) Bytes(b []byte) []byte {
+	rp := r.getReplacer()
+	if rp == nil {
+		return b
+	}
+	return []byte(rp.Replace(string(b)))
+}
+
+func (r *Redactor) getReplacer() *strings.Replacer {
+	r.mu.RLock()
+	rp := r.replacer
+	empty := len(r.values) == 0
+	r.mu.RUnlock()
+	if rp != nil || empty {
+		return rp
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.replacer != nil {
+		return r.replacer
+	}
+	// longest values first: replacer prefers the first matching pair
+	vals := make([]string, 0, len(r.values))
+	for v := range r.values {
+		vals = append(vals, v)
+	}
+	sort.Slice(vals, func(i, j int) bool {
+		if len(vals[i]) != len(vals[j]) {
+			return len(vals[i]) > len(vals[j])
+		}
+		return vals[i] < vals[j]
+	})
+	pairs := make([]string, 0, len(vals)*2)
+	for _, v := range vals {
+		pairs = append(pairs, v, r.mask(v))
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+	return r.replacer
+}
+
+func (r *Redactor) mask(v string) string {
+	if r.cfg.MaskFunc != nil {
+		return r.cfg.MaskFunc(v)
+	}
+	return r.cfg.Mask
+}
+
+// pending returns length of the longest suffix of s which is a proper prefix of a tracked value
+func (r *Redactor) pending(s string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := r.maxLen - 1
+	if n > len(s) {
+		n = len(s)
+	}
+	for ; n > 0; n-- {
+		tail := s[len(s)-n:]
+		for v := range r.values {
+			if len(v) > n && strings.HasPrefix(v, tail) {
+				return n
+			}
+		}
+	}
+	return 0
+}
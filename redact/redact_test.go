@@ -0,0 +1,93 @@
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lancer-kit/noble"
+)
+
+func TestRedactor_String(t *testing.T) {
+	r := New(Config{})
+	r.Add("abc", "secret", "secret-long")
+	assert.Equal(t, 2, r.Len())
+	assert.Equal(t, "abc ****** ******", r.String("abc secret secret-long"))
+
+	r = New(Config{MinLength: 1, MaskFunc: func(v string) string { return strings.Repeat("x", len(v)) }})
+	r.Add("abc")
+	assert.Equal(t, "xxx-d", r.String("abc-d"))
+	r.Reset()
+	assert.Equal(t, "abc-d", r.String("abc-d"))
+}
+
+func TestRedactor_Track(t *testing.T) {
+	assert.NoError(t, os.Setenv("REDACT_TEST_PASS", "P@ssw0rd"))
+	defer func() { _ = os.Unsetenv("REDACT_TEST_PASS") }()
+	s := noble.Secret{}.New("dynenv:REDACT_TEST_PASS")
+	assert.Equal(t, "P@ssw0rd", s.Get())
+	assert.Equal(t, "connect ******", Default.String("connect P@ssw0rd"))
+}
+
+func TestWriter(t *testing.T) {
+	r := New(Config{})
+	r.Add("topsecret")
+	var b bytes.Buffer
+	w := r.Writer(&b)
+	for _, p := range []string{"pass=top", "sec", "ret; top", " end top"} {
+		n, err := w.Write([]byte(p))
+		assert.NoError(t, err)
+		assert.Equal(t, len(p), n)
+	}
+	assert.Equal(t, "pass=******; top end ", b.String())
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "pass=******; top end top", b.String())
+}
+
+// failWriter fails writes while fail is set
+type failWriter struct {
+	buf  bytes.Buffer
+	fail bool
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		return 0, errors.New("write failed")
+	}
+	return w.buf.Write(p)
+}
+
+func TestWriter_Retry(t *testing.T) {
+	r := New(Config{})
+	r.Add("topsecret")
+	var b failWriter
+	w := r.Writer(&b)
+	n, err := w.Write([]byte("pass=top"))
+	assert.NoError(t, err)
+	assert.Equal(t, 8, n)
+	b.fail = true
+	_, err = w.Write([]byte("secret; "))
+	assert.Error(t, err)
+	b.fail = false
+	_, err = w.Write([]byte("secret; "))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "pass=******; ", b.buf.String())
+}
+
+func TestHook(t *testing.T) {
+	r := New(Config{Mask: "<hidden>"})
+	r.Add("topsecret")
+	var b bytes.Buffer
+	l := logrus.New()
+	l.SetOutput(&b)
+	l.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true, DisableQuote: true})
+	l.AddHook(r.Hook())
+	l.WithError(errors.New("auth topsecret failed")).WithField("token", "topsecret").Error("use topsecret")
+	assert.Equal(t, "level=error msg=use <hidden> error=auth <hidden> failed token=<hidden>\n", b.String())
+}
@@ -0,0 +1,88 @@
+//go:build go1.21
+// +build go1.21
+
+package redact
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler masks tracked values in the message and attributes of records
+// before passing them to the next handler
+type SlogHandler struct {
+	r    *Redactor
+	next slog.Handler
+}
+
+// NewSlogHandler wraps next with the Default redactor:
+//
+//	slog.SetDefault(slog.New(redact.NewSlogHandler(slog.Default().Handler())))
+func NewSlogHandler(next slog.Handler) *SlogHandler {
+	return Default.SlogHandler(next)
+}
+
+// SlogHandler wraps next
+func (r *Redactor) SlogHandler(next slog.Handler) *SlogHandler {
+	return &SlogHandler{r: r, next: next}
+}
+
+// Enabled implements slog.Handler
+func (h *SlogHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+// Handle implements slog.Handler
+func (h *SlogHandler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, h.r.String(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.attr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+// WithAttrs implements slog.Handler
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		masked[i] = h.attr(a)
+	}
+	return &SlogHandler{r: h.r, next: h.next.WithAttrs(masked)}
+}
+
+// WithGroup implements slog.Handler
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	return &SlogHandler{r: h.r, next: h.next.WithGroup(name)}
+}
+
+func (h *SlogHandler) attr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.r.String(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		masked := make([]slog.Attr, len(group))
+		for i, g := range group {
+			masked[i] = h.attr(g)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(masked...)}
+	case slog.KindAny:
+		var s string
+		switch val := v.Any().(type) {
+		case error:
+			s = val.Error()
+		case []byte:
+			s = string(val)
+		case interface{ String() string }:
+			s = val.String()
+		default:
+			return slog.Attr{Key: a.Key, Value: v}
+		}
+		if m := h.r.String(s); m != s {
+			return slog.String(a.Key, m)
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
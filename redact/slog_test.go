@@ -0,0 +1,28 @@
+//go:build go1.21
+// +build go1.21
+
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogHandler(t *testing.T) {
+	r := New(Config{})
+	r.Add("topsecret")
+	var b bytes.Buffer
+	h := slog.NewTextHandler(&b, &slog.HandlerOptions{ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}})
+	l := slog.New(r.SlogHandler(h)).With("dsn", "user:topsecret@db")
+	l.WithGroup("req").Info("use topsecret", "err", errors.New("topsecret"), "n", 1)
+	assert.Equal(t, "level=INFO msg=\"use ******\" dsn=user:******@db req.err=****** req.n=1\n", b.String())
+}
@@ -0,0 +1,68 @@
+package redact
+
+import (
+	"io"
+	"sync"
+)
+
+// Writer masks tracked values in the stream written to the underlying writer.
+// Bytes that can start a tracked value are held back until the next Write or Flush,
+// so secrets split between writes are masked as well.
+type Writer struct {
+	r    *Redactor
+	w    io.Writer
+	mu   sync.Mutex
+	tail []byte
+}
+
+// NewWriter wraps w with the Default redactor
+func NewWriter(w io.Writer) *Writer {
+	return Default.Writer(w)
+}
+
+// Writer wraps w
+func (r *Redactor) Writer(w io.Writer) *Writer {
+	return &Writer{r: r, w: w}
+}
+
+// Write implements io.Writer
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	buf := string(append(w.tail, p...))
+	hold := w.r.pending(buf)
+	out := w.r.String(buf[:len(buf)-hold])
+	if _, err := io.WriteString(w.w, out); err != nil {
+		// tail is kept for the retry of p
+		return 0, err
+	}
+	w.tail = append(w.tail[:0], buf[len(buf)-hold:]...)
+	return len(p), nil
+}
+
+// Flush writes held back bytes
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.tail) == 0 {
+		return nil
+	}
+	out := w.r.String(string(w.tail))
+	if _, err := io.WriteString(w.w, out); err != nil {
+		return err
+	}
+	w.tail = w.tail[:0]
+	return nil
+}
+
+// Close flushes held back bytes and closes the underlying writer if it is io.Closer
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package redis
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/lancer-kit/noble/internal/jsonpath"
+	"github.com/lancer-kit/noble/internal/tlsconf"
+	"github.com/pkg/errors"
+)
+
+type redisClient struct {
+	cfg Config
+	tls *tls.Config
+
+	mu   sync.Mutex
+	conn *conn
+
+	wmu     sync.Mutex
+	watched map[string]*entry
+	watch   *subscription
+}
+
+// errNotFound missing key or hash field
+var errNotFound = errors.New("redis key not found")
+
+func newClient(cfg Config) (*redisClient, error) {
+	if cfg.Addr == "" {
+		cfg.Addr = DefaultAddr
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if len(cfg.SentinelAddrs) > 0 && cfg.MasterName == "" {
+		return nil, errors.New("redis sentinel master name is not set")
+	}
+	c := &redisClient{cfg: cfg, watched: make(map[string]*entry)}
+	if cfg.TLS {
+		tlsCfg, err := tlsconf.Files{
+			CAFile:             cfg.CAFile,
+			CertFile:           cfg.CertFile,
+			KeyFile:            cfg.KeyFile,
+			InsecureSkipVerify: cfg.InsecureSkipVerify,
+		}.Load()
+		if err != nil {
+			return nil, err
+		}
+		c.tls = tlsCfg
+	}
+	return c, nil
+}
+
+// masterAddr returns address of the server or of the master discovered by the Sentinels
+func (c *redisClient) masterAddr() (string, error) {
+	if len(c.cfg.SentinelAddrs) == 0 {
+		return c.cfg.Addr, nil
+	}
+	var errs []string
+	for _, addr := range c.cfg.SentinelAddrs {
+		s, err := dial(addr, c.tls, c.cfg.SentinelUsername, c.cfg.SentinelPassword, 0, c.cfg.Timeout)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		reply, err := s.do("SENTINEL", "get-master-addr-by-name", c.cfg.MasterName)
+		_ = s.Close()
+		if hp, ok := reply.([]interface{}); ok && len(hp) == 2 {
+			host, _ := hp[0].(string)
+			port, _ := hp[1].(string)
+			return net.JoinHostPort(host, port), nil
+		}
+		if err == nil {
+			err = errors.New("unknown master " + c.cfg.MasterName)
+		}
+		errs = append(errs, addr+": "+err.Error())
+	}
+	return "", errors.New("redis sentinel: " + strings.Join(errs, "; "))
+}
+
+// connect to the server. Role of the master discovered by the Sentinels is verified
+func (c *redisClient) connect() (*conn, error) {
+	addr, err := c.masterAddr()
+	if err != nil {
+		return nil, err
+	}
+	cn, err := dial(addr, c.tls, c.cfg.Username, c.cfg.Password, c.cfg.DB, c.cfg.Timeout)
+	if err != nil || len(c.cfg.SentinelAddrs) == 0 {
+		return cn, err
+	}
+	role, err := cn.do("ROLE")
+	if r, ok := role.([]interface{}); err == nil && (!ok || len(r) == 0 || r[0] != "master") {
+		err = errors.New("redis " + addr + " is not master")
+	}
+	if err != nil {
+		_ = cn.Close()
+		return nil, err
+	}
+	return cn, nil
+}
+
+// do command on the shared connection. Broken connection is reestablished once
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.conn == nil {
+			if c.conn, err = c.connect(); err != nil {
+				return nil, err
+			}
+		}
+		var reply interface{}
+		reply, err = c.conn.do(args...)
+		if _, ok := err.(redisError); err == nil || ok {
+			return reply, err
+		}
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	return nil, err
+}
+
+// get value of the key or of the hash field. String value with field is decoded as JSON,
+// hash without field is returned as JSON object
+func (c *redisClient) get(key, field string) (string, error) {
+	var (
+		reply interface{}
+		err   error
+	)
+	if field != "" {
+		reply, err = c.do("HGET", key, field)
+	} else {
+		reply, err = c.do("GET", key)
+	}
+	if !isWrongType(err) {
+		if s, ok := reply.(string); ok || err != nil {
+			return s, err
+		}
+		return "", errNotFound
+	}
+	if field != "" {
+		val, err := c.get(key, "")
+		if err != nil {
+			return "", err
+		}
+		return jsonpath.Extract([]byte(val), field)
+	}
+	reply, err = c.do("HGETALL", key)
+	if err != nil {
+		return "", err
+	}
+	list, _ := reply.([]interface{})
+	m := make(map[string]string, len(list)/2)
+	for i := 0; i+1 < len(list); i += 2 {
+		k, _ := list[i].(string)
+		m[k], _ = list[i+1].(string)
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+func isWrongType(err error) bool {
+	e, ok := err.(redisError)
+	return ok && strings.HasPrefix(string(e), "WRONGTYPE")
+}
+
+// Close stops watch and closes connections of the client
+func (c *redisClient) Close() {
+	c.stopWatch()
+	c.mu.Lock()
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	c.mu.Unlock()
+}
@@ -0,0 +1,110 @@
+package redis
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/storeclient"
+	"github.com/pkg/errors"
+)
+
+// Config of the Redis client
+type Config struct {
+	// Addr of the server, host:port. Ignored if SentinelAddrs is set
+	Addr string
+	// Username of the ACL user (Redis 6+), "default" user if empty
+	Username string
+	// Password of the user or of the requirepass
+	Password string
+	// DB index
+	DB int
+	// TLS enables TLS. CAFile, CertFile, KeyFile and InsecureSkipVerify configure it
+	TLS                bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+	// SentinelAddrs of the Sentinels used to discover address of the MasterName master
+	SentinelAddrs []string
+	MasterName    string
+	// SentinelUsername and SentinelPassword of the Sentinels
+	SentinelUsername string
+	SentinelPassword string
+	// Timeout of the dial and of a single command
+	Timeout time.Duration
+	// Watch every key read by KeyReader: values are served from the local cache
+	// which is updated by keyspace notifications. See Watch and Subscribe
+	Watch bool
+}
+
+// Defaults of the config
+const (
+	DefaultAddr    = "127.0.0.1:6379"
+	DefaultTimeout = 5 * time.Second
+)
+
+//nolint:gochecknoglobals
+var clients storeclient.Holder
+
+// Init Redis client. Config from REDIS_URL environment variable used if cfg is nil
+// or Init was not called before the first read
+func Init(cfg *Config) error {
+	if cfg == nil {
+		c, err := ConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		cfg = &c
+	}
+	c, err := newClient(*cfg)
+	if err != nil {
+		return err
+	}
+	clients.Set(c)
+	return nil
+}
+
+func getClient() (*redisClient, error) {
+	c, err := clients.Get(func() error { return Init(nil) })
+	if err != nil {
+		return nil, err
+	}
+	return c.(*redisClient), nil
+}
+
+// ConfigFromEnv returns config from REDIS_URL environment variable, DefaultAddr if not set
+func ConfigFromEnv() (Config, error) {
+	if u := os.Getenv("REDIS_URL"); u != "" {
+		return ParseURL(u)
+	}
+	return Config{Addr: DefaultAddr, Timeout: DefaultTimeout}, nil
+}
+
+// ParseURL returns config of the URL: redis://[[user]:password@]host[:port][/db], rediss:// for TLS
+func ParseURL(s string) (Config, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Config{}, err
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return Config{}, errors.New("invalid redis url scheme: " + u.Scheme)
+	}
+	cfg := Config{Addr: u.Host, TLS: u.Scheme == "rediss", Timeout: DefaultTimeout}
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		cfg.Addr = net.JoinHostPort(strings.Trim(u.Host, "[]"), "6379")
+	}
+	if u.User != nil {
+		cfg.Username = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if cfg.DB, err = strconv.Atoi(db); err != nil {
+			return Config{}, errors.New("invalid redis db: " + db)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,52 @@
+// Package redis Noble Redis reader
+package redis
+
+import (
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("redis", &KeyReader{})
+}
+
+// KeyReader type implements noble.SecretStorage
+//
+// Key format: <key>[#<field>]
+//
+//	redis:app:token - value of the string key
+//	redis:config:db#password - field of the hash
+//	redis:config:json#db.password - field of the JSON value of the string key
+//	redis:config:db - JSON object of all hash fields
+type KeyReader struct {
+}
+
+// Read key value from Redis
+func (r *KeyReader) Read(key string) (string, error) {
+	c, err := getClient()
+	if err != nil {
+		return "", err
+	}
+	k, _ := splitKey(key)
+	if k == "" {
+		return "", errors.New("incorrect key format. use <key>[#<field>]")
+	}
+	val, err := c.read(key)
+	return val, errors.Wrap(err, "redis key "+k)
+}
+
+// splitKey splits key and field
+func splitKey(s string) (key, field string) {
+	if i := strings.Index(s, "#"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// Clone returns new empty instance of KeyReader
+func (r *KeyReader) Clone() noble.SecretStorage {
+	return &KeyReader{}
+}
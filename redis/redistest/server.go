@@ -0,0 +1,464 @@
+// Package redistest provides in-process fake Redis server for tests.
+//
+// Implemented subset of RESP2 commands: AUTH (requirepass and ACL users), PING, SELECT, GET, SET, DEL,
+// HGET, HSET, HGETALL, ROLE, SUBSCRIBE with keyspace notifications, SENTINEL get-master-addr-by-name.
+package redistest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type value struct {
+	str  *string
+	hash map[string]string
+}
+
+// Server fake Redis server
+type Server struct {
+	ln net.Listener
+	// Addr of the server, host:port
+	Addr string
+	// Role returned by ROLE command: "master" by default
+	Role string
+
+	mu       sync.Mutex
+	dbs      map[int]map[string]*value
+	users    map[string]string
+	masters  map[string]string
+	clients  map[*client]bool
+	commands int
+	delay    time.Duration
+	ca       []byte
+}
+
+type client struct {
+	net.Conn
+	wmu    sync.Mutex
+	w      *bufio.Writer
+	db     int
+	authed bool
+	subs   map[string]bool
+}
+
+// NewServer starts fake server on the loopback interface. Caller must Close it
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("redistest: " + err.Error())
+	}
+	return newServer(ln, nil)
+}
+
+// NewTLSServer starts fake server with TLS certificate for 127.0.0.1, see CA
+func NewTLSServer() *Server {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		panic("redistest: " + err.Error())
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		panic("redistest: " + err.Error())
+	}
+	return newServer(ln, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// Start fake server closed on test cleanup
+func Start(t testing.TB) *Server {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newServer(ln net.Listener, ca []byte) *Server {
+	s := &Server{
+		ln:      ln,
+		Addr:    ln.Addr().String(),
+		Role:    "master",
+		dbs:     make(map[int]map[string]*value),
+		users:   make(map[string]string),
+		masters: make(map[string]string),
+		clients: make(map[*client]bool),
+		ca:      ca,
+	}
+	go s.serve()
+	return s
+}
+
+// CA returns PEM certificate of the TLS server
+func (s *Server) CA() []byte {
+	return s.ca
+}
+
+// Close listener and all connections
+func (s *Server) Close() {
+	_ = s.ln.Close()
+	s.DropConnections()
+}
+
+// DropConnections closes connections of all clients
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		_ = c.Close()
+		delete(s.clients, c)
+	}
+}
+
+// SetPassword of the default user (requirepass). Enables authentication
+func (s *Server) SetPassword(password string) *Server {
+	return s.AddUser("default", password)
+}
+
+// AddUser of the ACL. Enables authentication
+func (s *Server) AddUser(username, password string) *Server {
+	s.mu.Lock()
+	s.users[username] = password
+	s.mu.Unlock()
+	return s
+}
+
+// AddMaster makes server answer SENTINEL get-master-addr-by-name of the master
+func (s *Server) AddMaster(name, addr string) *Server {
+	s.mu.Lock()
+	s.masters[name] = addr
+	s.mu.Unlock()
+	return s
+}
+
+// SetDelay of the replies to the read commands (GET, HGET, HGETALL), simulates slow server
+func (s *Server) SetDelay(d time.Duration) *Server {
+	s.mu.Lock()
+	s.delay = d
+	s.mu.Unlock()
+	return s
+}
+
+// Commands returns count of handled commands
+func (s *Server) Commands() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands
+}
+
+// Set string key of db 0
+func (s *Server) Set(key, val string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db(0)[key] = &value{str: &val}
+	s.publish(0, key, "set")
+}
+
+// HSet hash field of db 0
+func (s *Server) HSet(key, field, val string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hset(0, key, field, val)
+}
+
+// Del key of db 0
+func (s *Server) Del(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.db(0)[key]; ok {
+		delete(s.db(0), key)
+		s.publish(0, key, "del")
+	}
+}
+
+// db returns keys of the database. Requires lock
+func (s *Server) db(n int) map[string]*value {
+	if s.dbs[n] == nil {
+		s.dbs[n] = make(map[string]*value)
+	}
+	return s.dbs[n]
+}
+
+// hset requires lock. Returns false for key of other type
+func (s *Server) hset(db int, key, field, val string) bool {
+	v, ok := s.db(db)[key]
+	if !ok {
+		v = &value{hash: make(map[string]string)}
+		s.db(db)[key] = v
+	}
+	if v.hash == nil {
+		return false
+	}
+	v.hash[field] = val
+	s.publish(db, key, "hset")
+	return true
+}
+
+// publish keyspace notification. Requires lock
+func (s *Server) publish(db int, key, event string) {
+	ch := "__keyspace@" + strconv.Itoa(db) + "__:" + key
+	for c := range s.clients {
+		if c.subs[ch] {
+			c.write([]interface{}{"message", ch, event})
+		}
+	}
+}
+
+func (s *Server) serve() {
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &client{Conn: nc, w: bufio.NewWriter(nc), subs: make(map[string]bool)}
+		s.mu.Lock()
+		s.clients[c] = true
+		s.mu.Unlock()
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c *client) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+		_ = c.Close()
+	}()
+	r := bufio.NewReader(c)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands++
+		reply := s.exec(c, args)
+		delay := s.delay
+		s.mu.Unlock()
+		if cmd := strings.ToUpper(args[0]); delay > 0 && (cmd == "GET" || cmd == "HGET" || cmd == "HGETALL") {
+			time.Sleep(delay)
+		}
+		if reply != nil {
+			c.write(reply)
+		}
+	}
+}
+
+type errReply string
+
+// exec command and returns reply, nil if the reply is already sent. Requires lock
+func (s *Server) exec(c *client, args []string) interface{} {
+	if len(args) == 0 {
+		return errReply("ERR empty command")
+	}
+	cmd := strings.ToUpper(args[0])
+	if cmd == "AUTH" {
+		user, pass := "default", ""
+		switch len(args) {
+		case 2:
+			pass = args[1]
+		case 3:
+			user, pass = args[1], args[2]
+		default:
+			return errReply("ERR wrong number of arguments for 'auth' command")
+		}
+		if p, ok := s.users[user]; !ok || p != pass {
+			return errReply("WRONGPASS invalid username-password pair or user is disabled.")
+		}
+		c.authed = true
+		return "OK"
+	}
+	if len(s.users) > 0 && !c.authed {
+		return errReply("NOAUTH Authentication required.")
+	}
+	if len(c.subs) > 0 && cmd != "SUBSCRIBE" && cmd != "PING" {
+		return errReply("ERR only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context")
+	}
+	argc := map[string]int{"PING": 1, "SELECT": 2, "GET": 2, "SET": 3, "DEL": 2, "HGET": 3, "HSET": 4,
+		"HGETALL": 2, "ROLE": 1, "SENTINEL": 3}
+	if n, ok := argc[cmd]; ok && len(args) != n {
+		return errReply("ERR wrong number of arguments for '" + strings.ToLower(cmd) + "' command")
+	}
+	db := s.db(c.db)
+	switch cmd {
+	case "PING":
+		return "PONG"
+	case "SELECT":
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 || n > 15 {
+			return errReply("ERR DB index is out of range")
+		}
+		c.db = n
+		return "OK"
+	case "GET":
+		v, ok := db[args[1]]
+		if !ok {
+			return nilBulk{}
+		}
+		if v.str == nil {
+			return wrongType
+		}
+		return bulk(*v.str)
+	case "SET":
+		val := args[2]
+		db[args[1]] = &value{str: &val}
+		s.publish(c.db, args[1], "set")
+		return "OK"
+	case "DEL":
+		if _, ok := db[args[1]]; !ok {
+			return int64(0)
+		}
+		delete(db, args[1])
+		s.publish(c.db, args[1], "del")
+		return int64(1)
+	case "HGET":
+		v, ok := db[args[1]]
+		if !ok {
+			return nilBulk{}
+		}
+		if v.hash == nil {
+			return wrongType
+		}
+		f, ok := v.hash[args[2]]
+		if !ok {
+			return nilBulk{}
+		}
+		return bulk(f)
+	case "HSET":
+		if !s.hset(c.db, args[1], args[2], args[3]) {
+			return wrongType
+		}
+		return int64(1)
+	case "HGETALL":
+		v, ok := db[args[1]]
+		if ok && v.hash == nil {
+			return wrongType
+		}
+		res := []interface{}{}
+		if ok {
+			for k, f := range v.hash {
+				res = append(res, bulk(k), bulk(f))
+			}
+		}
+		return res
+	case "ROLE":
+		if s.Role == "sentinel" {
+			return []interface{}{bulk("sentinel"), []interface{}{}}
+		}
+		return []interface{}{bulk(s.Role), int64(0), []interface{}{}}
+	case "SENTINEL":
+		if strings.ToLower(args[1]) != "get-master-addr-by-name" {
+			return errReply("ERR unknown sentinel subcommand")
+		}
+		addr, ok := s.masters[args[2]]
+		if !ok {
+			return nilArray{}
+		}
+		host, port, _ := net.SplitHostPort(addr)
+		return []interface{}{bulk(host), bulk(port)}
+	case "SUBSCRIBE":
+		for _, ch := range args[1:] {
+			c.subs[ch] = true
+			c.write([]interface{}{"subscribe", ch, int64(len(c.subs))})
+		}
+		return nil
+	}
+	return errReply("ERR unknown command '" + args[0] + "'")
+}
+
+const wrongType = errReply("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+type bulk string
+
+type nilBulk struct{}
+
+type nilArray struct{}
+
+// write reply to the client
+func (c *client) write(v interface{}) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	writeReply(c.w, v)
+	_ = c.w.Flush()
+}
+
+func writeReply(w *bufio.Writer, v interface{}) {
+	switch v := v.(type) {
+	case nilBulk:
+		_, _ = w.WriteString("$-1\r\n")
+	case nilArray:
+		_, _ = w.WriteString("*-1\r\n")
+	case string:
+		_, _ = w.WriteString("+" + v + "\r\n")
+	case bulk:
+		_, _ = w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + string(v) + "\r\n")
+	case errReply:
+		_, _ = w.WriteString("-" + string(v) + "\r\n")
+	case int64:
+		_, _ = w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+	case []interface{}:
+		_, _ = w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				e = bulk(s)
+			}
+			writeReply(w, e)
+		}
+	}
+}
+
+// readCommand reads RESP array of bulk strings or inline command
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimRight(line, "\r\n")[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
@@ -0,0 +1,253 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lancer-kit/noble/internal/watch"
+)
+
+// Delay bounds between failed subscriptions
+//
+//nolint:gochecknoglobals
+var (
+	WatchRetryMin = 500 * time.Millisecond
+	WatchRetryMax = 30 * time.Second
+)
+
+// Subscriber receives watched key (with "#field") and its new value, empty string if deleted
+type Subscriber = watch.Subscriber
+
+//nolint:gochecknoglobals
+var subs watch.Registry
+
+// Subscribe fn to changes of the watched keys. Returns function to unsubscribe
+func Subscribe(fn Subscriber) func() {
+	return subs.Subscribe(fn)
+}
+
+// Watch reads key (with optional "#field") into the local cache and keeps it current using
+// keyspace notifications. The server must publish them, e.g. "notify-keyspace-events Kgh$x".
+// KeyReader serves watched keys from memory
+func Watch(key string) error {
+	c, err := getClient()
+	if err != nil {
+		return err
+	}
+	_, err = c.watchKey(key)
+	return err
+}
+
+// Close stops watch of the current client. Keys are read directly after Close
+func Close() {
+	if c := clients.Current(); c != nil {
+		c.(*redisClient).stopWatch()
+	}
+}
+
+// entry of the watched key
+type entry struct {
+	key   string
+	field string
+	value string
+	err   error
+}
+
+// subscription to the keyspace notifications
+type subscription struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu   sync.Mutex
+	conn *conn
+}
+
+// read returns value from the watch cache or from the server
+func (c *redisClient) read(name string) (string, error) {
+	c.wmu.Lock()
+	e, ok := c.watched[name]
+	c.wmu.Unlock()
+	if !ok && !c.cfg.Watch {
+		return c.get(splitKey(name))
+	}
+	if !ok {
+		var err error
+		if e, err = c.watchKey(name); err != nil {
+			return "", err
+		}
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return e.value, e.err
+}
+
+// watchKey returns entry of the watched key or reads new one and subscribes to its notifications.
+// Missing key is cached as error
+func (c *redisClient) watchKey(name string) (*entry, error) {
+	c.wmu.Lock()
+	e, ok := c.watched[name]
+	c.wmu.Unlock()
+	if ok {
+		return e, nil
+	}
+	// initial read without the lock: slow server must not block reads of the other watched keys
+	key, field := splitKey(name)
+	val, err := c.get(key, field)
+	if err != nil && err != errNotFound {
+		return nil, err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if e, ok := c.watched[name]; ok {
+		// read concurrently
+		return e, nil
+	}
+	subscribed := false
+	for _, e := range c.watched {
+		subscribed = subscribed || e.key == key
+	}
+	e = &entry{key: key, field: field, value: val, err: err}
+	c.watched[name] = e
+	if c.watch == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.watch = &subscription{cancel: cancel, done: make(chan struct{})}
+		go c.runWatch(ctx, c.watch)
+	} else if !subscribed {
+		c.watch.mu.Lock()
+		if c.watch.conn != nil {
+			_ = c.watch.conn.send("SUBSCRIBE", c.channel(key))
+		}
+		c.watch.mu.Unlock()
+	}
+	return e, nil
+}
+
+// channel of the keyspace notifications of the key
+func (c *redisClient) channel(key string) string {
+	return "__keyspace@" + strconv.Itoa(c.cfg.DB) + "__:" + key
+}
+
+func (c *redisClient) stopWatch() {
+	c.wmu.Lock()
+	sub := c.watch
+	c.watch = nil
+	c.watched = make(map[string]*entry)
+	c.wmu.Unlock()
+	if sub == nil {
+		return
+	}
+	sub.cancel()
+	sub.mu.Lock()
+	if sub.conn != nil {
+		_ = sub.conn.Close()
+	}
+	sub.mu.Unlock()
+	<-sub.done
+}
+
+// runWatch keeps subscription to the keyspace notifications, reconnecting with backoff
+func (c *redisClient) runWatch(ctx context.Context, sub *subscription) {
+	defer close(sub.done)
+	b := watch.Backoff{Min: &WatchRetryMin, Max: &WatchRetryMax}
+	for ctx.Err() == nil {
+		if c.listen(ctx, sub) {
+			b.Reset()
+		}
+		if !b.Wait(ctx) {
+			return
+		}
+	}
+}
+
+// listen subscribes to the notifications of the watched keys, refreshes them and handles notifications
+// until the connection breaks. Returns true if subscription was established
+func (c *redisClient) listen(ctx context.Context, sub *subscription) bool {
+	cn, err := c.connect()
+	if err != nil {
+		return false
+	}
+	cn.timeout = 0
+	_ = cn.SetDeadline(time.Time{})
+	sub.mu.Lock()
+	if ctx.Err() != nil {
+		sub.mu.Unlock()
+		_ = cn.Close()
+		return false
+	}
+	sub.conn = cn
+	sub.mu.Unlock()
+	defer func() {
+		sub.mu.Lock()
+		sub.conn = nil
+		sub.mu.Unlock()
+		_ = cn.Close()
+	}()
+
+	c.wmu.Lock()
+	keys := make(map[string]bool)
+	for _, e := range c.watched {
+		keys[e.key] = true
+	}
+	c.wmu.Unlock()
+	args := []string{"SUBSCRIBE"}
+	for k := range keys {
+		args = append(args, c.channel(k))
+	}
+	sub.mu.Lock()
+	err = cn.send(args...)
+	sub.mu.Unlock()
+	if err != nil {
+		return false
+	}
+	// changes missed while disconnected
+	for k := range keys {
+		c.refresh(k)
+	}
+	prefix := c.channel("")
+	for {
+		reply, err := cn.read()
+		if err != nil {
+			return true
+		}
+		msg, ok := reply.([]interface{})
+		if !ok || len(msg) != 3 || msg[0] != "message" {
+			continue
+		}
+		if ch, ok := msg[1].(string); ok && strings.HasPrefix(ch, prefix) {
+			c.refresh(strings.TrimPrefix(ch, prefix))
+		}
+	}
+}
+
+// refresh watched entries of the key and notify subscribers about changes.
+// Entries are kept on connection errors
+func (c *redisClient) refresh(key string) {
+	c.wmu.Lock()
+	var names []string
+	for name, e := range c.watched {
+		if e.key == key {
+			names = append(names, name)
+		}
+	}
+	c.wmu.Unlock()
+	for _, name := range names {
+		k, field := splitKey(name)
+		val, err := c.get(k, field)
+		if err != nil && err != errNotFound {
+			continue
+		}
+		c.wmu.Lock()
+		e, ok := c.watched[name]
+		changed := ok && (e.value != val || (e.err == nil) != (err == nil))
+		if ok {
+			e.value, e.err = val, err
+		}
+		c.wmu.Unlock()
+		if changed {
+			subs.Notify(name, val)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"strings"
+	"sync"
 )
 
 // SecretStorage reader interface
@@ -13,10 +14,41 @@ type SecretStorage interface {
 }
 
 //nolint:gochecknoglobals
-var registered = map[string]SecretStorage{
-	"raw":    &rawReader{},
-	"env":    &envReader{},
-	"dynenv": &dynReader{},
+var (
+	registryMu sync.RWMutex
+	registered = map[string]SecretStorage{
+		"raw":    &rawReader{},
+		"env":    &envReader{},
+		"dynenv": &dynReader{},
+	}
+)
+
+// ResolveHook receives every non-empty value resolved by secret storages
+type ResolveHook func(value string)
+
+//nolint:gochecknoglobals
+var (
+	hooksMu sync.RWMutex
+	hooks   []ResolveHook
+)
+
+// OnResolve add hook called for every value read by registered storages.
+// Used to track secret values, e.g. for masking in logs
+func OnResolve(h ResolveHook) {
+	hooksMu.Lock()
+	hooks = append(hooks, h)
+	hooksMu.Unlock()
+}
+
+func resolved(val string) {
+	if val == "" {
+		return
+	}
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	for _, h := range hooks {
+		h(val)
+	}
 }
 
 type Secret struct {
@@ -78,7 +110,24 @@ func (ss *Secret) ParseError() error {
 
 // Register new SecretStorage reader interface
 func Register(key string, impl SecretStorage) {
+	registryMu.Lock()
 	registered[key] = impl
+	registryMu.Unlock()
+}
+
+// Unregister remove SecretStorage reader registered by key
+func Unregister(key string) {
+	registryMu.Lock()
+	delete(registered, key)
+	registryMu.Unlock()
+}
+
+// Lookup returns SecretStorage reader registered by key
+func Lookup(key string) (SecretStorage, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	impl, ok := registered[key]
+	return impl, ok
 }
 
 // UnmarshalYAML read secrets from yaml
@@ -153,14 +202,16 @@ func (sw *secret) read(s string) error {
 	key := parts[0]
 	sw.path = parts[1]
 
-	reader, ok := registered[key]
+	reader, ok := Lookup(key)
 	if !ok {
 		sw.parseError = errors.New("unregistered storage: " + key)
 		return sw.parseError
 	}
 
 	sw.reader = reader.Clone()
-	_, sw.internal = sw.reader.Read(sw.path)
+	var val string
+	val, sw.internal = sw.reader.Read(sw.path)
+	resolved(val)
 	return sw.internal
 }
 
@@ -185,6 +236,7 @@ func (sw *secret) Get() string {
 	}
 	val, err := sw.reader.Read(sw.path)
 	sw.internal = err
+	resolved(val)
 	return val
 }
 
@@ -207,6 +259,20 @@ func (ss *Secret) Get() string {
 	return s
 }
 
+// Map decodes value of the secret as JSON object.
+// Used with storages which read groups of values, e.g. directories of key/value storages
+func (ss *Secret) Map() (map[string]interface{}, error) {
+	val := ss.Get()
+	if err := ss.InternalError(); err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(val), &m); err != nil {
+		return nil, errors.New("secret value is not a JSON object: " + err.Error())
+	}
+	return m, nil
+}
+
 type requiredSecretRule struct {
 	message string
 	skipNil bool
@@ -2,6 +2,7 @@ package noble
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"os"
 	"testing"
 
@@ -125,6 +126,45 @@ func TestSecret_Error(t *testing.T) {
 	assert.Error(t, s.InternalError())
 }
 
+func TestSecret_Map(t *testing.T) {
+	s := Secret{}.New(`raw:{"user":"admin","port":5432}`)
+	m, err := s.Map()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"user": "admin", "port": 5432.0}, m)
+	s = Secret{}.New("raw:admin")
+	_, err = s.Map()
+	assert.Error(t, err)
+	s = Secret{}.New("env:SOME_NOT_EXISTING")
+	_, err = s.Map()
+	assert.Error(t, err)
+}
+
+func TestSecret_EnvFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "noble")
+	assert.NoError(t, err)
+	defer func() { _ = os.Remove(f.Name()) }()
+	_, err = f.WriteString(envPass + "\n")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	assert.NoError(t, os.Setenv("DB_PASS_FILE", f.Name()))
+	defer func() { _ = os.Unsetenv("DB_PASS_FILE") }()
+	s := Secret{}.New("env:DB_PASS")
+	assert.NoError(t, s.InternalError())
+	assert.Equal(t, envPass, s.Get())
+	s = Secret{}.New("dynenv:DB_PASS")
+	assert.Equal(t, envPass, s.Get())
+
+	// variable has priority over the file
+	assert.NoError(t, os.Setenv("DB_PASS", "from-env"))
+	assert.Equal(t, "from-env", s.Get())
+	assert.NoError(t, os.Unsetenv("DB_PASS"))
+
+	assert.NoError(t, os.Setenv("DB_PASS_FILE", f.Name()+".none"))
+	s = Secret{}.New("env:DB_PASS")
+	assert.Error(t, s.InternalError())
+}
+
 func TestSecret_NoError(t *testing.T) {
 
 }
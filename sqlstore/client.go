@@ -0,0 +1,168 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lancer-kit/noble/simplecrypt"
+	"github.com/pkg/errors"
+)
+
+type sqlClient struct {
+	cfg Config
+	db  *sql.DB
+	// own db opened by the client
+	own bool
+	scr *simplecrypt.Reader
+
+	mu    sync.Mutex
+	cache map[string]cached
+	// reads in flight and closed flag: replaced client closes own db after the last read
+	reads  int
+	closed bool
+}
+
+type cached struct {
+	value   string
+	expires time.Time
+}
+
+var (
+	// errNotFound query returned no rows
+	errNotFound = errors.New("sql secret not found")
+	// errClosed client was replaced by Init, read is retried with the current client
+	errClosed = errors.New("sql client is closed")
+)
+
+func newClient(cfg Config) (*sqlClient, error) {
+	if cfg.Query == "" {
+		cfg.Query = DefaultQuery
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+	c := &sqlClient{cfg: cfg, db: cfg.DB, cache: make(map[string]cached)}
+	if cfg.Decrypt {
+		key := cfg.DecryptKey
+		if key == "" {
+			key = os.Getenv(simplecrypt.EnvVarName)
+		}
+		if key == "" {
+			return nil, errors.New("simplecrypt key is not set: DecryptKey or " + simplecrypt.EnvVarName)
+		}
+		bin, err := base64.RawStdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid simplecrypt key")
+		}
+		if n := len(bin); n != 16 && n != 24 && n != 32 {
+			return nil, errors.Errorf("invalid simplecrypt key length %d, AES key is 16, 24 or 32 bytes", n)
+		}
+		c.scr = (&simplecrypt.Reader{}).SetKey(key)
+	}
+	if c.db != nil {
+		return c, nil
+	}
+	if cfg.Driver == "" {
+		return nil, errors.New("sql driver is not set")
+	}
+	dsn := cfg.DSN.Get()
+	if err := cfg.DSN.Error(); err != "" {
+		return nil, errors.New("sql dsn: " + err)
+	}
+	if dsn == "" {
+		return nil, errors.New("sql dsn is not set")
+	}
+	db, err := sql.Open(cfg.Driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	c.db, c.own = db, true
+	return c, nil
+}
+
+// get value of the secret from the cache or from the database
+func (c *sqlClient) get(name string) (string, error) {
+	if c.cfg.CacheTTL > 0 {
+		c.mu.Lock()
+		e, ok := c.cache[name]
+		c.mu.Unlock()
+		if ok && time.Now().Before(e.expires) {
+			return e.value, nil
+		}
+	}
+	if !c.acquire() {
+		return "", errClosed
+	}
+	defer c.release()
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+	var val sql.NullString
+	err := c.db.QueryRowContext(ctx, c.cfg.Query, name).Scan(&val)
+	if err == sql.ErrNoRows {
+		return "", errNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if !val.Valid {
+		return "", errors.New("sql secret is null")
+	}
+	value := val.String
+	if c.scr != nil {
+		if value, err = c.scr.Read(value); err != nil {
+			return "", errors.Wrap(err, "simplecrypt")
+		}
+	}
+	if c.cfg.CacheTTL > 0 {
+		c.mu.Lock()
+		c.cache[name] = cached{value: value, expires: time.Now().Add(c.cfg.CacheTTL)}
+		c.mu.Unlock()
+	}
+	return value, nil
+}
+
+// acquire registers the read, false if the client is closed
+func (c *sqlClient) acquire() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.reads++
+	return true
+}
+
+// release the read, closing database of the closed client after the last one
+func (c *sqlClient) release() {
+	c.mu.Lock()
+	c.reads--
+	last := c.closed && c.reads == 0
+	c.mu.Unlock()
+	if last {
+		c.closeDB()
+	}
+}
+
+// Close closes database opened by the client when reads in flight are finished
+func (c *sqlClient) Close() {
+	c.mu.Lock()
+	c.closed = true
+	idle := c.reads == 0
+	c.mu.Unlock()
+	if idle {
+		c.closeDB()
+	}
+}
+
+func (c *sqlClient) closeDB() {
+	if c.own {
+		_ = c.db.Close()
+	}
+}
@@ -0,0 +1,85 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"os"
+	"time"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/storeclient"
+)
+
+// Config of the SQL client
+type Config struct {
+	// Driver name of the database/sql driver, e.g. "postgres". Application imports the driver package
+	Driver string
+	// DSN of the database, e.g. "env:SECRETS_DB_DSN" or "vault:secret/data/app#dsn"
+	DSN noble.Secret
+	// DB opened by the application. Used instead of Driver and DSN, not closed by Init
+	DB *sql.DB
+	// Query selects value of the secret by its name passed as the only argument
+	Query string
+	// Timeout of a single query
+	Timeout time.Duration
+	// CacheTTL of the read values, negative disables cache
+	CacheTTL time.Duration
+	// Decrypt values with simplecrypt. DecryptKey is the key in SCR_PASS format (base64 AES-128, 192 or 256 key),
+	// $SCR_PASS if empty. Init fails if both are empty
+	Decrypt    bool
+	DecryptKey string
+}
+
+// Defaults of the config
+const (
+	DefaultQuery    = "SELECT value FROM secrets WHERE name = $1"
+	DefaultTimeout  = 5 * time.Second
+	DefaultCacheTTL = time.Minute
+)
+
+//nolint:gochecknoglobals
+var clients storeclient.Holder
+
+// Init SQL client. Config from NOBLE_SQL_* environment variables used if cfg is nil
+// or Init was not called before the first read
+func Init(cfg *Config) error {
+	if cfg == nil {
+		c := ConfigFromEnv()
+		cfg = &c
+	}
+	c, err := newClient(*cfg)
+	if err != nil {
+		return err
+	}
+	clients.Set(c)
+	return nil
+}
+
+func getClient() (*sqlClient, error) {
+	c, err := clients.Get(func() error { return Init(nil) })
+	if err != nil {
+		return nil, err
+	}
+	return c.(*sqlClient), nil
+}
+
+// ConfigFromEnv returns config from the environment variables:
+//
+//	NOBLE_SQL_DRIVER - driver name
+//	NOBLE_SQL_DSN - DSN as noble secret, e.g. "env:DATABASE_URL" or "raw:postgres://..."
+//	NOBLE_SQL_QUERY - query, DefaultQuery if empty
+//	NOBLE_SQL_DECRYPT - "true" to decrypt values with simplecrypt key of SCR_PASS
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Driver:  os.Getenv("NOBLE_SQL_DRIVER"),
+		Query:   DefaultQuery,
+		Timeout: DefaultTimeout,
+	}
+	if dsn := os.Getenv("NOBLE_SQL_DSN"); dsn != "" {
+		cfg.DSN = noble.Secret{}.New(dsn)
+	}
+	if q := os.Getenv("NOBLE_SQL_QUERY"); q != "" {
+		cfg.Query = q
+	}
+	cfg.Decrypt = os.Getenv("NOBLE_SQL_DECRYPT") == "true"
+	return cfg
+}
@@ -0,0 +1,55 @@
+// Package sqlstore Noble SQL database reader
+package sqlstore
+
+import (
+	"strings"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/internal/jsonpath"
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoinits
+func init() {
+	noble.Register("sql", &KeyReader{})
+}
+
+// KeyReader type implements noble.SecretStorage
+//
+// Key format: <name>[#<field>]
+//
+//	sql:tenant-a/db-password - value selected by Config.Query with the name
+//	sql:tenant-a/db#password - field of the JSON value
+type KeyReader struct {
+}
+
+// Read secret value from the database
+func (r *KeyReader) Read(key string) (string, error) {
+	name, field := key, ""
+	if i := strings.Index(key, "#"); i >= 0 {
+		name, field = key[:i], key[i+1:]
+	}
+	if name == "" {
+		return "", errors.New("incorrect key format. use <name>[#<field>]")
+	}
+	var val string
+	err := errClosed
+	// client replaced by Init after getClient is closed, read again with the new one
+	for err == errClosed {
+		var c *sqlClient
+		if c, err = getClient(); err != nil {
+			return "", err
+		}
+		val, err = c.get(name)
+	}
+	if err != nil || field == "" {
+		return val, errors.Wrap(err, "sql secret "+name)
+	}
+	val, err = jsonpath.Extract([]byte(val), field)
+	return val, errors.Wrap(err, "sql secret "+name)
+}
+
+// Clone returns new empty instance of KeyReader
+func (r *KeyReader) Clone() noble.SecretStorage {
+	return &KeyReader{}
+}
@@ -0,0 +1,187 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/nobletest"
+	"github.com/lancer-kit/noble/simplecrypt"
+	"github.com/lancer-kit/noble/sqlstore/sqltest"
+)
+
+func initClient(t *testing.T, cfg *Config) {
+	nobletest.InitClient(t, func() error { return Init(cfg) }, clients.Reset)
+}
+
+func TestKeyReader_Read(t *testing.T) {
+	db := sqltest.Start(t)
+	db.Set("tenant-a/db-password", "secret")
+	db.Set("tenant-a/db", `{"user":"admin","password":"p1"}`)
+	db.SetNull("tenant-a/null")
+	nobletest.Setenv(t, map[string]string{"SECRETS_DB_DSN": db.DSN})
+	initClient(t, &Config{Driver: sqltest.DriverName, DSN: noble.Secret{}.New("env:SECRETS_DB_DSN"),
+		Query: "SELECT value FROM tenant_secrets WHERE name = ?"})
+
+	r := KeyReader{}
+	v, err := r.Read("tenant-a/db-password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+	v, err = r.Read("tenant-a/db#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "p1", v)
+	assert.Equal(t, "SELECT value FROM tenant_secrets WHERE name = ?", db.Queries()[0])
+
+	for _, key := range []string{"tenant-a/none", "tenant-a/null", "tenant-a/db#none", "#password"} {
+		_, err = r.Read(key)
+		assert.Error(t, err, key)
+	}
+
+	var c nobletest.Config
+	assert.NoError(t, yaml.Unmarshal([]byte(`secret: "sql:tenant-a/db#user"`), &c))
+	assert.NoError(t, c.Secret.InternalError())
+	assert.Equal(t, "admin", c.Secret.Get())
+}
+
+func TestClient_Cache(t *testing.T) {
+	db := sqltest.Start(t)
+	db.Set("key", "1")
+	initClient(t, &Config{Driver: sqltest.DriverName, DSN: noble.Secret{}.New("raw:" + db.DSN),
+		CacheTTL: 100 * time.Millisecond})
+
+	r := KeyReader{}
+	for i := 0; i < 3; i++ {
+		v, err := r.Read("key")
+		assert.NoError(t, err)
+		assert.Equal(t, "1", v)
+	}
+	assert.Len(t, db.Queries(), 1)
+
+	db.Set("key", "2")
+	db.Fail(errors.New("connection refused"))
+	v, err := r.Read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", v, "cached")
+	time.Sleep(150 * time.Millisecond)
+	_, err = r.Read("key")
+	assert.Error(t, err, "errors are not cached")
+	db.Fail(nil)
+	v, err = r.Read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", v)
+
+	initClient(t, &Config{DB: openDB(t, db), CacheTTL: -1})
+	for i := 0; i < 3; i++ {
+		_, _ = r.Read("key")
+	}
+	assert.Len(t, db.Queries(), 6)
+}
+
+func TestClient_Decrypt(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	enc, err := simplecrypt.Encrypt("secret", key)
+	assert.NoError(t, err)
+	db := sqltest.Start(t)
+	db.Set("key", enc)
+	db.Set("plain", "secret")
+
+	initClient(t, &Config{DB: openDB(t, db), Decrypt: true, DecryptKey: base64.RawStdEncoding.EncodeToString(key)})
+	v, err := (&KeyReader{}).Read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+	_, err = (&KeyReader{}).Read("plain")
+	assert.Error(t, err)
+
+	assert.Error(t, Init(&Config{DB: openDB(t, db), Decrypt: true, DecryptKey: "not base64!"}))
+	assert.Error(t, Init(&Config{DB: openDB(t, db), Decrypt: true, DecryptKey: "c2hvcnQ"}), "key length")
+
+	nobletest.Setenv(t, map[string]string{simplecrypt.EnvVarName: ""})
+	assert.Error(t, Init(&Config{DB: openDB(t, db), Decrypt: true}), "no key")
+	nobletest.Setenv(t, map[string]string{simplecrypt.EnvVarName: base64.RawStdEncoding.EncodeToString(key)})
+	initClient(t, &Config{DB: openDB(t, db), Decrypt: true})
+	v, err = (&KeyReader{}).Read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+}
+
+func TestClient_Close(t *testing.T) {
+	db := sqltest.Start(t)
+	db.Set("key", "value")
+	cfg := &Config{Driver: sqltest.DriverName, DSN: noble.Secret{}.New("raw:" + db.DSN), CacheTTL: -1}
+	initClient(t, cfg)
+	prev, err := getClient()
+	assert.NoError(t, err)
+
+	// database of the replaced client is closed after the read in flight
+	assert.True(t, prev.acquire())
+	assert.NoError(t, Init(cfg))
+	assert.NoError(t, prev.db.Ping())
+	prev.release()
+	assert.Error(t, prev.db.Ping())
+
+	_, err = prev.get("key")
+	assert.Equal(t, errClosed, err)
+	v, err := (&KeyReader{}).Read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", v)
+
+	// database of the application is not closed
+	d := openDB(t, db)
+	initClient(t, &Config{DB: d})
+	clients.Reset()
+	assert.NoError(t, d.Ping())
+}
+
+func TestInit(t *testing.T) {
+	assert.Error(t, Init(&Config{DSN: noble.Secret{}.New("raw:dsn")}), "driver")
+	assert.Error(t, Init(&Config{Driver: sqltest.DriverName}), "dsn")
+	assert.Error(t, Init(&Config{Driver: sqltest.DriverName, DSN: noble.Secret{}.New("env:SQLX_TEST_NONE")}))
+	assert.Error(t, Init(&Config{Driver: "none", DSN: noble.Secret{}.New("raw:dsn")}))
+
+	initClient(t, &Config{Driver: sqltest.DriverName, DSN: noble.Secret{}.New("raw:none")})
+	_, err := (&KeyReader{}).Read("key")
+	assert.Error(t, err, "unknown database")
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	db := sqltest.Start(t)
+	db.Set("key", "value")
+	nobletest.Setenv(t, map[string]string{
+		"NOBLE_SQL_DRIVER":  sqltest.DriverName,
+		"NOBLE_SQL_DSN":     "raw:" + db.DSN,
+		"NOBLE_SQL_QUERY":   "SELECT v FROM s WHERE k = $1",
+		"NOBLE_SQL_DECRYPT": "true",
+	})
+	cfg := ConfigFromEnv()
+	assert.Equal(t, sqltest.DriverName, cfg.Driver)
+	assert.Equal(t, db.DSN, cfg.DSN.Get())
+	assert.Equal(t, "SELECT v FROM s WHERE k = $1", cfg.Query)
+	assert.True(t, cfg.Decrypt)
+	assert.Equal(t, DefaultTimeout, cfg.Timeout)
+
+	// first read initializes client from the environment
+	nobletest.Setenv(t, map[string]string{"NOBLE_SQL_DECRYPT": ""})
+	clients.Reset()
+	t.Cleanup(clients.Reset)
+	v, err := (&KeyReader{}).Read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", v)
+}
+
+func openDB(t *testing.T, db *sqltest.DB) *sql.DB {
+	d, err := sql.Open(sqltest.DriverName, db.DSN)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { _ = d.Close() })
+	return d
+}
@@ -0,0 +1,187 @@
+// Package sqltest provides in-process fake database/sql driver for tests.
+//
+// Every query of the driver ignores its SQL text and selects value column of the secret
+// named by the only argument.
+package sqltest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// DriverName of the fake driver
+const DriverName = "sqltest"
+
+//nolint:gochecknoglobals
+var (
+	dbsMu sync.Mutex
+	dbs   = make(map[string]*DB)
+	dbSeq int
+)
+
+//nolint:gochecknoinits
+func init() {
+	sql.Register(DriverName, fakeDriver{})
+}
+
+// DB fake database
+type DB struct {
+	// DSN of the database for the DriverName driver
+	DSN string
+
+	mu      sync.Mutex
+	values  map[string]*string
+	queries []string
+	err     error
+}
+
+// New creates fake database. Caller must Close it
+func New() *DB {
+	dbsMu.Lock()
+	defer dbsMu.Unlock()
+	dbSeq++
+	db := &DB{DSN: "sqltest-" + strconv.Itoa(dbSeq), values: make(map[string]*string)}
+	dbs[db.DSN] = db
+	return db
+}
+
+// Start fake database closed on test cleanup
+func Start(t testing.TB) *DB {
+	db := New()
+	t.Cleanup(db.Close)
+	return db
+}
+
+// Close database, new connections fail
+func (db *DB) Close() {
+	dbsMu.Lock()
+	delete(dbs, db.DSN)
+	dbsMu.Unlock()
+}
+
+// Set value of the secret
+func (db *DB) Set(name, value string) {
+	db.mu.Lock()
+	db.values[name] = &value
+	db.mu.Unlock()
+}
+
+// SetNull value of the secret
+func (db *DB) SetNull(name string) {
+	db.mu.Lock()
+	db.values[name] = nil
+	db.mu.Unlock()
+}
+
+// Del secret
+func (db *DB) Del(name string) {
+	db.mu.Lock()
+	delete(db.values, name)
+	db.mu.Unlock()
+}
+
+// Fail all queries with err, nil to recover
+func (db *DB) Fail(err error) {
+	db.mu.Lock()
+	db.err = err
+	db.mu.Unlock()
+}
+
+// Queries returns SQL text of the executed queries
+func (db *DB) Queries() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]string(nil), db.queries...)
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	dbsMu.Lock()
+	db, ok := dbs[dsn]
+	dbsMu.Unlock()
+	if !ok {
+		return nil, errors.New("sqltest: unknown database " + dsn)
+	}
+	return &conn{db: db}, nil
+}
+
+type conn struct {
+	db *DB
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{db: c.db, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("sqltest: transactions are not supported")
+}
+
+type stmt struct {
+	db    *DB
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return 1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("sqltest: exec is not supported")
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.queries = append(s.db.queries, s.query)
+	if s.db.err != nil {
+		return nil, s.db.err
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return nil, errors.New("sqltest: name argument must be string")
+	}
+	r := &rows{}
+	if v, ok := s.db.values[name]; ok {
+		if v == nil {
+			r.values = []driver.Value{nil}
+		} else {
+			r.values = []driver.Value{*v}
+		}
+	}
+	return r, nil
+}
+
+type rows struct {
+	values []driver.Value
+}
+
+func (r *rows) Columns() []string {
+	return []string{"value"}
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
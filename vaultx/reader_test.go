@@ -1,20 +1,14 @@
 package vaultx
 
-// WARNING: non-standard port used in tests (1234 instead 8000)
-// For use this test execute `vault kv put  -address="http://127.0.0.1:1234" secret/data pass="my long password"\
-// test="passed"`
-
 import (
 	"encoding/json"
-	"fmt"
 	"os"
 	"testing"
 
 	"github.com/sirupsen/logrus"
 
-	"github.com/lancer-kit/armory/api/httpx"
-
 	"github.com/lancer-kit/noble"
+	"github.com/lancer-kit/noble/vaultx/vaulttest"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v2"
 )
@@ -35,74 +29,49 @@ type testConfig struct {
 	Secret noble.Secret `yaml:"secret"`
 }
 
-//go:generate curl http://127.0.0.1:1234/v1/sys/health
-func installedVault() bool {
-	resp, err := httpx.NewXClient().Get("http://127.0.0.1:1234/v1/sys/health")
-	if err != nil {
-		return false
-	}
-	if resp.StatusCode != 200 {
-		return false
-	}
-	_ = resp.Body.Close()
-	return true
-}
-
-func initStorage(t *testing.T) {
-	if vs != nil {
-		return
-	}
-	SetServerAddress("http://127.0.0.1:1234")
-	if !SetTokenEnv("VAULT_TOKEN") {
-		SetToken("myroot") //YOUR TOKEN HERE
-	}
+func initStorage(t *testing.T) *vaulttest.Server {
+	srv := vaulttest.Start(t)
+	srv.Put("secret/data", map[string]interface{}{"pass": "my long password", "test": "passed"})
+	SetServerAddress(srv.URL)
+	SetSecretPath("secret/data")
+	SetToken(srv.RootToken)
 	err := InitVault(nil)
 	assert.NoError(t, err)
 	assert.Equal(t, false, SetLogger(nil))
+	return srv
 }
 
 func TestKeyReader_Read(t *testing.T) {
-	if !installedVault() {
-		println("no vault installed and run on port 1234")
-		return
-	}
 	initStorage(t)
 	r := KeyReader{}
 	v, e := r.Read("/data?test")
 	assert.NoError(t, e)
 	assert.Equal(t, "passed", v)
+	v, e = r.Read("/missing?test")
+	assert.NoError(t, e)
+	assert.Equal(t, "", v)
 }
 
 func TestKeyReader_YAML(t *testing.T) {
-	if !installedVault() {
-		println("no vault installed and run on port 1234")
-		return
-	}
-
 	initStorage(t)
 	var c testConfig
 	e := yaml.Unmarshal([]byte(testYaml), &c)
 	assert.NoError(t, e)
 	assert.NoError(t, c.Secret.InternalError())
 	assert.NoError(t, c.Secret.ParseError())
-	x := c.Secret.Get()
-	fmt.Printf("%+v\n", x)
+	assert.Equal(t, "my long password", c.Secret.Get())
 }
 
 func TestKeyReader_JSON(t *testing.T) {
-	if !installedVault() {
-		println("no vault installed and run on port 1234")
-		return
-	}
-
-	initStorage(t)
+	srv := initStorage(t)
 	var c testConfig
 	e := json.Unmarshal([]byte(testJSON), &c)
 	assert.NoError(t, e)
 	assert.NoError(t, c.Secret.InternalError())
 	assert.NoError(t, c.Secret.ParseError())
-	x := c.Secret.Get()
-	fmt.Printf("%+v\n", x)
+	assert.Equal(t, "my long password", c.Secret.Get())
+	srv.Put("secret/data", map[string]interface{}{"pass": "changed"})
+	assert.Equal(t, "changed", c.Secret.Get())
 }
 
 func TestSetSecretPath(t *testing.T) {
@@ -111,11 +80,14 @@ func TestSetSecretPath(t *testing.T) {
 }
 
 func TestInitVault(t *testing.T) {
-	if !installedVault() {
-		assert.Error(t, InitVault(nil))
-	} else {
-		assert.NoError(t, InitVault(nil))
-	}
+	srv := vaulttest.Start(t)
+	SetServerAddress(srv.URL)
+	SetToken("invalid")
+	assert.Error(t, InitVault(nil))
+	SetToken(srv.RootToken)
+	assert.NoError(t, InitVault(nil))
+	srv.Seal(true)
+	assert.Error(t, InitVault(nil))
 }
 
 func TestKeyReader_Clone(t *testing.T) {
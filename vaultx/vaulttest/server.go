@@ -0,0 +1,438 @@
+// Package vaulttest provides in-process fake of the Hashicorp Vault HTTP API for tests.
+//
+// Implemented subset: token create, sys/health, KV v1 and KV v2 (data, metadata, versions, list).
+// Secret engine "secret/" is mounted as KV v2 and "kv/" as KV v1 by default.
+package vaulttest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// RootToken default token accepted by Server
+	RootToken = "vaulttest-root" // #nosec
+
+	tokenHeader = "X-Vault-Token" // #nosec
+)
+
+type version struct {
+	data    map[string]interface{}
+	created time.Time
+	deleted time.Time
+}
+
+type kvSecret struct {
+	versions []*version
+	created  time.Time
+	updated  time.Time
+}
+
+type mount struct {
+	version int
+	secrets map[string]*kvSecret
+}
+
+// Server fake Vault server
+type Server struct {
+	*httptest.Server
+	// RootToken accepted by server. Tokens created by auth/token/create are accepted too
+	RootToken string
+
+	mu     sync.RWMutex
+	now    func() time.Time
+	tokens map[string]bool
+	mounts map[string]*mount
+	sealed bool
+}
+
+// NewServer starts fake Vault server. Caller must Close it
+func NewServer() *Server {
+	s := &Server{
+		RootToken: RootToken,
+		now:       time.Now,
+		tokens:    make(map[string]bool),
+		mounts:    make(map[string]*mount),
+	}
+	s.Mount("secret", 2)
+	s.Mount("kv", 1)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Start fake Vault server closed on test cleanup
+func Start(t testing.TB) *Server {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Mount KV secret engine with version 1 or 2 at path
+func (s *Server) Mount(path string, kvVersion int) *Server {
+	s.mu.Lock()
+	s.mounts[strings.Trim(path, "/")] = &mount{version: kvVersion, secrets: make(map[string]*kvSecret)}
+	s.mu.Unlock()
+	return s
+}
+
+// Seal makes health check report sealed vault and all other requests fail with 503
+func (s *Server) Seal(sealed bool) *Server {
+	s.mu.Lock()
+	s.sealed = sealed
+	s.mu.Unlock()
+	return s
+}
+
+// Put writes secret data. Path includes mount and excludes "data/" of KV v2, e.g. "secret/app/db".
+// For KV v2 new version is created. Returns version number
+func (s *Server) Put(path string, data map[string]interface{}) int {
+	m, p := s.resolve(path)
+	if m == nil {
+		panic("vaulttest: no mount for path " + path)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(m, p, data)
+}
+
+// Versions returns count of versions of the KV v2 secret
+func (s *Server) Versions(path string) int {
+	m, p := s.resolve(path)
+	if m == nil {
+		return 0
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if sec, ok := m.secrets[p]; ok {
+		return len(sec.versions)
+	}
+	return 0
+}
+
+func (s *Server) write(m *mount, path string, data map[string]interface{}) int {
+	now := s.now().UTC()
+	cp := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		cp[k] = v
+	}
+	sec, ok := m.secrets[path]
+	if !ok || m.version == 1 {
+		sec = &kvSecret{created: now}
+		m.secrets[path] = sec
+	}
+	sec.versions = append(sec.versions, &version{data: cp, created: now})
+	sec.updated = now
+	return len(sec.versions)
+}
+
+// resolve returns mount and path inside it
+func (s *Server) resolve(path string) (*mount, string) {
+	path = strings.Trim(path, "/")
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	best := ""
+	for k := range s.mounts {
+		if (path == k || strings.HasPrefix(path, k+"/")) && len(k) > len(best) {
+			best = k
+		}
+	}
+	if best == "" {
+		return nil, ""
+	}
+	return s.mounts[best], strings.TrimPrefix(strings.TrimPrefix(path, best), "/")
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	if path == r.URL.Path {
+		writeErrors(w, http.StatusNotFound)
+		return
+	}
+	if path == "sys/health" {
+		s.health(w, r)
+		return
+	}
+	s.mu.RLock()
+	sealed := s.sealed
+	s.mu.RUnlock()
+	if sealed {
+		writeErrors(w, http.StatusServiceUnavailable, "Vault is sealed")
+		return
+	}
+	if !s.validToken(r.Header.Get(tokenHeader)) {
+		writeErrors(w, http.StatusForbidden, "permission denied")
+		return
+	}
+	if path == "auth/token/create" && r.Method == http.MethodPost {
+		s.createToken(w)
+		return
+	}
+	m, p := s.resolve(path)
+	if m == nil {
+		writeErrors(w, http.StatusNotFound, "no handler for route '"+path+"'")
+		return
+	}
+	method := r.Method
+	if method == http.MethodGet && r.URL.Query().Get("list") == "true" {
+		method = "LIST"
+	}
+	if m.version == 1 {
+		s.kv1(w, r, method, m, p)
+		return
+	}
+	s.kv2(w, r, method, m, p)
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	sealed := s.sealed
+	s.mu.RUnlock()
+	code := http.StatusOK
+	if sealed {
+		code = http.StatusServiceUnavailable
+		if c, err := strconv.Atoi(r.URL.Query().Get("sealedcode")); err == nil {
+			code = c
+		}
+	}
+	writeJSON(w, code, map[string]interface{}{
+		"initialized":     true,
+		"sealed":          sealed,
+		"standby":         false,
+		"server_time_utc": s.now().Unix(),
+		"version":         "1.7.0",
+		"cluster_name":    "vaulttest",
+		"cluster_id":      "00000000-0000-0000-0000-000000000000",
+	})
+}
+
+func (s *Server) validToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	if token == s.RootToken {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tokens[token]
+}
+
+func (s *Server) createToken(w http.ResponseWriter) {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	token := "s." + hex.EncodeToString(b)
+	s.mu.Lock()
+	s.tokens[token] = true
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"auth": map[string]interface{}{
+			"client_token":   token,
+			"accessor":       "",
+			"policies":       []string{"root"},
+			"lease_duration": 0,
+			"renewable":      true,
+		},
+	})
+}
+
+func (s *Server) kv1(w http.ResponseWriter, r *http.Request, method string, m *mount, p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch method {
+	case http.MethodGet:
+		sec, ok := m.secrets[p]
+		if !ok {
+			writeErrors(w, http.StatusNotFound)
+			return
+		}
+		writeSecret(w, sec.versions[len(sec.versions)-1].data)
+	case http.MethodPost, http.MethodPut:
+		var data map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			writeErrors(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.write(m, p, data)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		delete(m.secrets, p)
+		w.WriteHeader(http.StatusNoContent)
+	case "LIST":
+		list(w, m, p)
+	default:
+		writeErrors(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) kv2(w http.ResponseWriter, r *http.Request, method string, m *mount, p string) {
+	parts := strings.SplitN(p, "/", 2)
+	if len(parts) != 2 && !(len(parts) == 1 && method == "LIST") {
+		writeErrors(w, http.StatusNotFound)
+		return
+	}
+	kind, name := parts[0], ""
+	if len(parts) == 2 {
+		name = parts[1]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case kind == "data" && method == http.MethodGet:
+		s.kv2Read(w, r, m, name)
+	case kind == "data" && (method == http.MethodPost || method == http.MethodPut):
+		var req struct {
+			Data    map[string]interface{} `json:"data"`
+			Options struct {
+				CAS *int `json:"cas"`
+			} `json:"options"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeErrors(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Options.CAS != nil {
+			current := 0
+			if sec, ok := m.secrets[name]; ok {
+				current = len(sec.versions)
+			}
+			if *req.Options.CAS != current {
+				writeErrors(w, http.StatusBadRequest, "check-and-set parameter did not match the current version")
+				return
+			}
+		}
+		v := s.write(m, name, req.Data)
+		writeSecret(w, versionMeta(m.secrets[name].versions[v-1], v))
+	case kind == "data" && method == http.MethodDelete:
+		if sec, ok := m.secrets[name]; ok {
+			sec.versions[len(sec.versions)-1].deleted = s.now().UTC()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case kind == "metadata" && method == http.MethodGet:
+		sec, ok := m.secrets[name]
+		if !ok {
+			writeErrors(w, http.StatusNotFound)
+			return
+		}
+		versions := make(map[string]interface{}, len(sec.versions))
+		for i, v := range sec.versions {
+			versions[strconv.Itoa(i+1)] = versionMeta(v, i+1)
+		}
+		writeSecret(w, map[string]interface{}{
+			"current_version": len(sec.versions),
+			"oldest_version":  1,
+			"max_versions":    0,
+			"cas_required":    false,
+			"created_time":    sec.created.Format(time.RFC3339Nano),
+			"updated_time":    sec.updated.Format(time.RFC3339Nano),
+			"versions":        versions,
+		})
+	case kind == "metadata" && method == http.MethodDelete:
+		delete(m.secrets, name)
+		w.WriteHeader(http.StatusNoContent)
+	case kind == "metadata" && method == "LIST":
+		list(w, m, name)
+	default:
+		writeErrors(w, http.StatusNotFound)
+	}
+}
+
+func (s *Server) kv2Read(w http.ResponseWriter, r *http.Request, m *mount, name string) {
+	sec, ok := m.secrets[name]
+	if !ok {
+		writeErrors(w, http.StatusNotFound)
+		return
+	}
+	n := len(sec.versions)
+	if q := r.URL.Query().Get("version"); q != "" && q != "0" {
+		var err error
+		if n, err = strconv.Atoi(q); err != nil || n < 1 || n > len(sec.versions) {
+			writeErrors(w, http.StatusNotFound)
+			return
+		}
+	}
+	v := sec.versions[n-1]
+	var data interface{} = v.data
+	if !v.deleted.IsZero() {
+		data = nil
+	}
+	writeSecret(w, map[string]interface{}{
+		"data":     data,
+		"metadata": versionMeta(v, n),
+	})
+}
+
+func versionMeta(v *version, n int) map[string]interface{} {
+	deleted := ""
+	if !v.deleted.IsZero() {
+		deleted = v.deleted.Format(time.RFC3339Nano)
+	}
+	return map[string]interface{}{
+		"created_time":  v.created.Format(time.RFC3339Nano),
+		"deletion_time": deleted,
+		"destroyed":     false,
+		"version":       n,
+	}
+}
+
+func list(w http.ResponseWriter, m *mount, prefix string) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	seen := make(map[string]bool)
+	var keys []string
+	for name := range m.secrets {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		k := strings.TrimPrefix(name, prefix)
+		if i := strings.Index(k, "/"); i >= 0 {
+			k = k[:i+1]
+		}
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		writeErrors(w, http.StatusNotFound)
+		return
+	}
+	sort.Strings(keys)
+	writeSecret(w, map[string]interface{}{"keys": keys})
+}
+
+func writeSecret(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"request_id":     "",
+		"lease_id":       "",
+		"renewable":      false,
+		"lease_duration": 0,
+		"data":           data,
+		"wrap_info":      nil,
+		"warnings":       nil,
+		"auth":           nil,
+	})
+}
+
+func writeErrors(w http.ResponseWriter, code int, errs ...string) {
+	if errs == nil {
+		errs = []string{}
+	}
+	writeJSON(w, code, map[string]interface{}{"errors": errs})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
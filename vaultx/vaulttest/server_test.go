@@ -0,0 +1,97 @@
+package vaulttest
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func newClient(t *testing.T, s *Server) *api.Client {
+	cfg := api.DefaultConfig()
+	cfg.Address = s.URL
+	c, err := api.NewClient(cfg)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	c.SetToken(s.RootToken)
+	return c
+}
+
+func TestServer_KV2(t *testing.T) {
+	s := Start(t)
+	c := newClient(t, s)
+
+	_, err := c.Logical().Write("secret/data/app/db", map[string]interface{}{
+		"data": map[string]interface{}{"pass": "one"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, s.Put("secret/app/db", map[string]interface{}{"pass": "two"}))
+	assert.Equal(t, 2, s.Versions("secret/app/db"))
+
+	sec, err := c.Logical().Read("secret/data/app/db")
+	assert.NoError(t, err)
+	assert.Equal(t, "two", sec.Data["data"].(map[string]interface{})["pass"])
+
+	sec, err = c.Logical().ReadWithData("secret/data/app/db", map[string][]string{"version": {"1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "one", sec.Data["data"].(map[string]interface{})["pass"])
+
+	_, err = c.Logical().Write("secret/data/app/db", map[string]interface{}{
+		"data": map[string]interface{}{"pass": "three"}, "options": map[string]interface{}{"cas": 1},
+	})
+	assert.Error(t, err)
+
+	sec, err = c.Logical().Read("secret/metadata/app/db")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "2", sec.Data["current_version"].(interface{ String() string }).String())
+	assert.Len(t, sec.Data["versions"], 2)
+
+	sec, err = c.Logical().List("secret/metadata/app")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"db"}, sec.Data["keys"])
+
+	_, err = c.Logical().Delete("secret/metadata/app/db")
+	assert.NoError(t, err)
+	sec, err = c.Logical().Read("secret/data/app/db")
+	assert.NoError(t, err)
+	assert.Nil(t, sec)
+}
+
+func TestServer_KV1(t *testing.T) {
+	s := Start(t)
+	c := newClient(t, s)
+
+	_, err := c.Logical().Write("kv/app", map[string]interface{}{"token": "abc"})
+	assert.NoError(t, err)
+	sec, err := c.Logical().Read("kv/app")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", sec.Data["token"])
+
+	sec, err = c.Logical().Read("unknown/app")
+	assert.NoError(t, err)
+	assert.Nil(t, sec)
+}
+
+func TestServer_Auth(t *testing.T) {
+	s := Start(t)
+	c := newClient(t, s)
+
+	sec, err := c.Auth().Token().Create(&api.TokenCreateRequest{TTL: "1h"})
+	assert.NoError(t, err)
+	c.SetToken(sec.Auth.ClientToken)
+	_, err = c.Logical().Read("kv/app")
+	assert.NoError(t, err)
+
+	c.SetToken("wrong")
+	_, err = c.Logical().Read("kv/app")
+	assert.Error(t, err)
+
+	h, err := c.Sys().Health()
+	assert.NoError(t, err)
+	assert.False(t, h.Sealed)
+	s.Seal(true)
+	h, err = c.Sys().Health()
+	assert.NoError(t, err)
+	assert.True(t, h.Sealed)
+}